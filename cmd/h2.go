@@ -0,0 +1,68 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// h2Cmd represents the h2 command
+var h2Cmd = &cobra.Command{
+	Use:   "h2",
+	Short: "Estimate SNP heritability with LD score regression",
+	Long: `Estimate SNP heritability by regressing chi^2 from munged summary
+statistics on reference LD scores. With several LD score columns the
+heritability is partitioned across annotations, and --overlap-annot writes
+per-annotation enrichment to <out>.results. --h2-cts runs one regression per
+cell type listed in an .ldcts file, conditioned on the --ref-ld-chr model.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Estimate_h2(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	h2sumstats   string
+	refldchr     string
+	wldchr       string
+	overlapannot bool
+	frqfilechr   string
+	nblocks      string
+	chisqmax     string
+	twostep      string
+	h2cts        string
+	threads      string
+	sampprev     string
+	popprev      string
+	intercepth2  string
+	nointercept  bool
+)
+
+func init() {
+	runCmd.AddCommand(h2Cmd)
+
+	h2Cmd.Flags().StringVarP(&h2sumstats, "sumstats", "s", "", "Munged sumstats file")
+	h2Cmd.Flags().StringVarP(&refldchr, "ref-ld-chr", "r", "", "Comma separated prefixes of reference LD score files split by chromosome")
+	h2Cmd.Flags().StringVarP(&wldchr, "w-ld-chr", "w", "", "Prefix of regression weight LD score files split by chromosome (a single prefix; no M files needed)")
+	h2Cmd.Flags().BoolVarP(&overlapannot, "overlap-annot", "", false, "Annotations overlap; compute enrichment from the .annot files")
+	h2Cmd.Flags().StringVarP(&frqfilechr, "frqfile-chr", "", "", "Prefix of PLINK .frq files split by chromosome")
+	h2Cmd.Flags().StringVarP(&nblocks, "n-blocks", "", "200", "Number of jackknife blocks")
+	h2Cmd.Flags().StringVarP(&chisqmax, "chisq-max", "", "", "Maximum chi^2 of regression SNPs")
+	h2Cmd.Flags().StringVarP(&h2cts, "h2-cts", "", "", "File of cell type names and LD score prefixes (.ldcts)")
+	h2Cmd.Flags().StringVarP(&threads, "threads", "t", "0", "Number of cell type regressions to run in parallel (0 uses all CPUs)")
+	h2Cmd.Flags().StringVarP(&sampprev, "samp-prev", "", "", "Sample prevalence of a case/control trait, for liability scale h2")
+	h2Cmd.Flags().StringVarP(&popprev, "pop-prev", "", "", "Population prevalence of a case/control trait, for liability scale h2")
+	h2Cmd.Flags().StringVarP(&intercepth2, "intercept-h2", "", "", "Fix the h2 regression intercept to this value")
+	h2Cmd.Flags().BoolVarP(&nointercept, "no-intercept", "", false, "Fix the regression intercept to 1")
+	h2Cmd.Flags().StringVarP(&twostep, "two-step", "", "", "Chi^2 cutoff for the two-step estimator")
+}
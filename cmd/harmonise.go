@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// harmoniseCmd represents the harmonise command
+var harmoniseCmd = &cobra.Command{
+	Use:   "harmonise",
+	Short: "Align sumstats alleles to the REF/ALT of a reference VCF",
+	Long: `Align A1 (effect) and A2 of each variant to the ALT and REF of a reference
+VCF, matched by CHR and BP or, without positions, by SNP ID. Swapped variants
+have Z, BETA, LOG_ODDS and SIGNED_SUMSTAT negated, OR inverted and FRQ
+replaced by 1 - FRQ; strand flipped variants are relabelled. The ACTION column
+records aligned, swapped, flipped, flipped_swapped, ambiguous, mismatch or
+missing for each variant.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Harmonise(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	harmsumstats  string
+	harmref       string
+	dropunaligned bool
+	harmsample    string
+	harmoutformat string
+	harmtrait     string
+	harmstudyid   string
+	harmassembly  string
+	harmsource    string
+	harmmafmin    string
+	harminfomin   string
+)
+
+func init() {
+	runCmd.AddCommand(harmoniseCmd)
+
+	harmoniseCmd.Flags().StringVarP(&harmsumstats, "sumstats", "s", "", "Sumstats file")
+	harmoniseCmd.Flags().StringVarP(&harmref, "ref", "r", "", "Reference panel VCF")
+	harmoniseCmd.Flags().BoolVarP(&dropunaligned, "drop-unaligned", "", false, "Drop ambiguous, mismatched and missing variants")
+	harmoniseCmd.Flags().StringVarP(&harmsample, "sample", "", "", "Sample to read from a multi-sample GWAS-VCF")
+	harmoniseCmd.Flags().StringVarP(&harmoutformat, "out-format", "", "tsv", "Output format: tsv, gwas-vcf or gwas-ssf")
+	harmoniseCmd.Flags().StringVarP(&harmtrait, "trait", "", "", "Trait name for the GWAS-VCF header or GWAS-SSF metadata")
+	harmoniseCmd.Flags().StringVarP(&harmstudyid, "study-id", "", "", "Study ID for the GWAS-VCF sample name or GWAS-SSF gwas_id")
+	harmoniseCmd.Flags().StringVarP(&harmassembly, "genome-assembly", "", "", "Genome assembly for the GWAS-SSF metadata, e.g. GRCh37")
+	harmoniseCmd.Flags().StringVarP(&harmsource, "source", "", "auto", "GWAS tool that wrote the sumstats: auto, none, plink2, regenie, saige or bolt")
+	harmoniseCmd.Flags().StringVarP(&harmmafmin, "mafmin", "", "0.01", "Minimum MAF; SNPs with MAF at or below it are dropped")
+	harmoniseCmd.Flags().StringVarP(&harminfomin, "info-min", "", "0.9", "Minimum INFO score")
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Describe the columns of a summary statistics file",
+	Long: `Print the columns of a summary statistics file and how munging will
+interpret them. --infer-build samples rsIDs with their positions and compares
+them to position tables of each genome build given with --build-ref, e.g.
+--build-ref GRCh37=pos37.tsv.gz,GRCh38=pos38.tsv.gz, reporting the best
+matching build and its match rate.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Inspect(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	inspectsumstats string
+	inferbuild      bool
+	buildref        string
+	nsample         string
+)
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().StringVarP(&inspectsumstats, "sumstats", "s", "", "Sumstats file")
+	inspectCmd.Flags().BoolVarP(&inferbuild, "infer-build", "", false, "Infer the genome build from SNP positions")
+	inspectCmd.Flags().StringVarP(&buildref, "build-ref", "", "", "Comma separated BUILD=FILE position tables with SNP, CHR and BP columns")
+	inspectCmd.Flags().StringVarP(&nsample, "n-sample", "", "10000", "Number of rsIDs to sample for --infer-build")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// liftoverCmd represents the liftover command
+var liftoverCmd = &cobra.Command{
+	Use:   "liftover",
+	Short: "Convert CHR/BP positions between genome builds",
+	Long: `Convert the CHR and BP columns of a summary statistics file to another
+genome build with a UCSC chain file, e.g. hg19ToHg38.over.chain.gz. Positions
+that are unmapped, map to several places or move to another chromosome are
+dropped. Positions lifted onto the reverse strand, as in inverted regions,
+have A1 and A2 reverse complemented. Other columns are copied unchanged to
+<out>.sumstats.gz.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Liftover(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	liftsumstats string
+	chain        string
+)
+
+func init() {
+	runCmd.AddCommand(liftoverCmd)
+
+	liftoverCmd.Flags().StringVarP(&liftsumstats, "sumstats", "s", "", "Sumstats file with CHR and BP columns")
+	liftoverCmd.Flags().StringVarP(&chain, "chain", "c", "", "UCSC chain file")
+}
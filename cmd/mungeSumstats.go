@@ -49,13 +49,42 @@ var (
 	a1inc         string
 	ignore        string
 	mafmin        string
+	keepcols      string
+	derivebetase  bool
+	snpmap        string
+	liftoverchain string
+	mergealleles  string
+	palindromic   bool
+	palindromemaf string
+	sample        string
+	outformat     string
+	trait         string
+	studyid       string
+	assembly      string
+	source        string
+	nfixed        string
+	ncas          string
+	ncon          string
+	nmin          string
+	nstudymin     string
+	daner         bool
+	danern        bool
+	htmlreport    bool
+	writedropped  bool
+	duplicates    string
+	dupalleles    bool
+	infomin       string
+	filters       string
+	excluderegs   string
+	extract       string
+	exclude       string
 )
 
 func init() {
 	runCmd.AddCommand(mungeSumstatsCmd)
 
 	mungeSumstatsCmd.Flags().StringVarP(&sumstats, "sumstats", "s", "", "Sumstats file")
-	mungeSumstatsCmd.Flags().StringVarP(&signedsumstat, "signedsumstat", "S", "", "Signed sumstat file")
+	mungeSumstatsCmd.Flags().StringVarP(&signedsumstat, "signed-sumstats", "S", "", "Name and null value of a signed summary statistic column, e.g. Z,0 or OR,1")
 	mungeSumstatsCmd.Flags().StringVarP(&ncol, "ncol", "n", "N", "Ncol")
 	mungeSumstatsCmd.Flags().StringVarP(&nstudy, "nstudy", "N", "", "Nstudy")
 	mungeSumstatsCmd.Flags().StringVarP(&snp, "snp", "p", "SNP", "Snp")
@@ -69,7 +98,36 @@ func init() {
 	mungeSumstatsCmd.Flags().StringVarP(&infolist, "infolist", "i", "", "Info list")
 	mungeSumstatsCmd.Flags().StringVarP(&a1inc, "a1inc", "A", "false", "A1inc")
 	mungeSumstatsCmd.Flags().StringVarP(&ignore, "ignore", "", "", "Ignore")
-	mungeSumstatsCmd.Flags().StringVarP(&mafmin, "mafmin", "M", "0.0", "Mafmin")
+	mungeSumstatsCmd.Flags().StringVarP(&mafmin, "mafmin", "M", "0.01", "Minimum MAF; SNPs with MAF at or below it are dropped")
+	mungeSumstatsCmd.Flags().StringVarP(&infomin, "info-min", "", "0.9", "Minimum INFO score")
+	mungeSumstatsCmd.Flags().StringVarP(&filters, "filters", "", "", "Extra QC filters, e.g. se-max=10,beta-max=5")
+	mungeSumstatsCmd.Flags().StringVarP(&excluderegs, "exclude-regions", "", "", "Drop SNPs in these BED files or presets (mhc), in the --genome-assembly build (default GRCh37)")
+	mungeSumstatsCmd.Flags().StringVarP(&extract, "extract", "", "", "Keep only the SNPs listed in this file")
+	mungeSumstatsCmd.Flags().StringVarP(&exclude, "exclude", "", "", "Drop the SNPs listed in this file")
+	mungeSumstatsCmd.Flags().BoolVarP(&derivebetase, "derive-beta-se", "", false, "Derive standardised BETA and SE from Z, FRQ and N when missing")
+	mungeSumstatsCmd.Flags().StringVarP(&keepcols, "keep-cols", "", "", "Extra columns to write: chr,bp,se,beta,frq,info; BETA, OR, FRQ and INFO are used for QC and Z but only written if named")
+	mungeSumstatsCmd.Flags().StringVarP(&mergealleles, "merge-alleles", "", "", "Keep only SNPs in this SNP, A1, A2 list with matching alleles")
+	mungeSumstatsCmd.Flags().BoolVarP(&palindromic, "palindromic-by-frq", "", false, "Keep strand ambiguous SNPs in --merge-alleles, inferring the strand from FRQ")
+	mungeSumstatsCmd.Flags().StringVarP(&palindromemaf, "palindromic-maf", "", "0.4", "MAF below which --palindromic-by-frq resolves strand ambiguous SNPs")
+	mungeSumstatsCmd.Flags().StringVarP(&liftoverchain, "liftover-chain", "", "", "Lift CHR/BP over to another build with a UCSC chain file")
+	mungeSumstatsCmd.Flags().StringVarP(&snpmap, "snp-map", "", "", "Map CHR/BP to rsIDs with a chr, pos, rsid, ref, alt reference file")
+	mungeSumstatsCmd.Flags().StringVarP(&sample, "sample", "", "", "Sample to read from a multi-sample GWAS-VCF")
+	mungeSumstatsCmd.Flags().StringVarP(&outformat, "out-format", "", "tsv", "Output format: tsv, gwas-vcf or gwas-ssf")
+	mungeSumstatsCmd.Flags().StringVarP(&trait, "trait", "", "", "Trait name for the GWAS-VCF header or GWAS-SSF metadata")
+	mungeSumstatsCmd.Flags().StringVarP(&studyid, "study-id", "", "", "Study ID for the GWAS-VCF sample name or GWAS-SSF gwas_id")
+	mungeSumstatsCmd.Flags().StringVarP(&assembly, "genome-assembly", "", "", "Genome assembly for the GWAS-SSF metadata, e.g. GRCh37")
+	mungeSumstatsCmd.Flags().StringVarP(&source, "source", "", "auto", "GWAS tool that wrote the sumstats: auto, none, plink2, regenie, saige or bolt")
+	mungeSumstatsCmd.Flags().StringVarP(&nfixed, "n", "", "0", "Sample size, if there is no N column")
+	mungeSumstatsCmd.Flags().StringVarP(&ncas, "ncas", "", "0", "Number of cases, if there are no N or N_CAS/N_CON columns")
+	mungeSumstatsCmd.Flags().StringVarP(&ncon, "ncon", "", "0", "Number of controls, if there are no N or N_CAS/N_CON columns")
+	mungeSumstatsCmd.Flags().StringVarP(&nmin, "n-min", "", "0", "Minimum N; defaults to the 90th percentile of N / 1.5")
+	mungeSumstatsCmd.Flags().StringVarP(&nstudymin, "nstudy-min", "", "0", "Minimum NSTUDY when there is no N; defaults to the largest NSTUDY")
+	mungeSumstatsCmd.Flags().BoolVarP(&daner, "daner", "", false, "Read N_cas and N_con from the FRQ_A_<ncas> and FRQ_U_<ncon> columns of a daner file")
+	mungeSumstatsCmd.Flags().BoolVarP(&danern, "daner-n", "", false, "Read N_cas and N_con from the Nca and Nco columns of a daner file")
+	mungeSumstatsCmd.Flags().BoolVarP(&htmlreport, "html-report", "", false, "Also write <out>.report.html with QC plots")
+	mungeSumstatsCmd.Flags().BoolVarP(&writedropped, "write-dropped", "", false, "Write the dropped rows and why to <out>.dropped.tsv.gz")
+	mungeSumstatsCmd.Flags().StringVarP(&duplicates, "duplicates", "", "first", "Duplicate SNP policy: first, drop-all, max-n, min-p, max-info or none")
+	mungeSumstatsCmd.Flags().BoolVarP(&dupalleles, "duplicates-by-alleles", "", false, "Treat rows of a SNP with different alleles as distinct variants")
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
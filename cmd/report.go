@@ -0,0 +1,49 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Write an HTML QC report of munged summary statistics",
+	Long: `Write <out>.report.html with Manhattan and QQ plots, P-value and N
+histograms and, given --merge-alleles with a FRQ column, allele frequencies
+against the reference. --qc takes the <out>.report.json written by munge for
+the input rows and the rows dropped by each filter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Report(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	reportsumstats string
+	reportqc       string
+	reportmerge    string
+	reportsample   string
+	reportout      string
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVarP(&reportsumstats, "sumstats", "s", "", "Munged sumstats file")
+	reportCmd.Flags().StringVarP(&reportqc, "qc", "", "", "JSON QC report written by munge")
+	reportCmd.Flags().StringVarP(&reportmerge, "merge-alleles", "", "", "SNP, A1, A2, FRQ list to compare allele frequencies with")
+	reportCmd.Flags().StringVarP(&reportsample, "sample", "", "", "Sample to read from a multi-sample GWAS-VCF")
+	reportCmd.Flags().StringVarP(&reportout, "out", "o", "", "Output prefix; defaults to the sumstats file")
+}
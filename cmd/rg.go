@@ -0,0 +1,60 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/awilliamson10/golink/scripts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// rgCmd represents the rg command
+var rgCmd = &cobra.Command{
+	Use:   "rg",
+	Short: "Estimate genetic correlation with LD score regression",
+	Long: `Estimate the genetic correlation of the first of a comma separated list
+of munged summary statistics with each of the others, from the heritability
+of each trait and their genetic covariance. Intercepts are given per file in
+the --sumstats order; the first --intercept-gencov value is ignored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := make(map[string]string)
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			opts[f.Name] = f.Value.String()
+		})
+		scripts.Estimate_rg(opts)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{
+		UnknownFlags: true,
+	},
+}
+
+var (
+	rgsumstats      string
+	rgrefldchr      string
+	rgwldchr        string
+	rgnblocks       string
+	rgchisqmax      string
+	rgtwostep       string
+	rgintercepth2   string
+	rginterceptgcov string
+	rgnointercept   bool
+	rgsampprev      string
+	rgpopprev       string
+)
+
+func init() {
+	runCmd.AddCommand(rgCmd)
+
+	rgCmd.Flags().StringVarP(&rgsumstats, "sumstats", "s", "", "Comma separated munged sumstats files")
+	rgCmd.Flags().StringVarP(&rgrefldchr, "ref-ld-chr", "r", "", "Comma separated prefixes of reference LD score files split by chromosome")
+	rgCmd.Flags().StringVarP(&rgwldchr, "w-ld-chr", "w", "", "Prefix of regression weight LD score files split by chromosome")
+	rgCmd.Flags().StringVarP(&rgnblocks, "n-blocks", "", "200", "Number of jackknife blocks")
+	rgCmd.Flags().StringVarP(&rgchisqmax, "chisq-max", "", "", "Maximum chi^2 of regression SNPs")
+	rgCmd.Flags().StringVarP(&rgtwostep, "two-step", "", "", "Chi^2 cutoff for the two-step estimator")
+	rgCmd.Flags().StringVarP(&rgintercepth2, "intercept-h2", "", "", "Comma separated h2 intercepts to fix, one per --sumstats file")
+	rgCmd.Flags().StringVarP(&rginterceptgcov, "intercept-gencov", "", "", "Comma separated genetic covariance intercepts to fix, one per --sumstats file")
+	rgCmd.Flags().StringVarP(&rgsampprev, "samp-prev", "", "", "Comma separated sample prevalences, one per --sumstats file (nan for a quantitative trait), for liability scale h2 and gencov")
+	rgCmd.Flags().StringVarP(&rgpopprev, "pop-prev", "", "", "Comma separated population prevalences, one per --sumstats file (nan for a quantitative trait)")
+	rgCmd.Flags().BoolVarP(&rgnointercept, "no-intercept", "", false, "Fix the h2 intercepts to 1 and the genetic covariance intercepts to 0")
+}
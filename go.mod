@@ -8,6 +8,7 @@ require (
 	github.com/kostya-sh/parquet-go v0.0.0-20180827163605-06b7130dc45c
 	github.com/spf13/cobra v1.4.0
 	github.com/spf13/pflag v1.0.5
+	gonum.org/v1/gonum v0.9.3
 )
 
 require (
@@ -16,5 +17,4 @@ require (
 	golang.org/x/exp v0.0.0-20200224162631-6cc2880d07d6 // indirect
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	gonum.org/v1/gonum v0.9.3 // indirect
 )
@@ -0,0 +1,95 @@
+package constants
+
+// Source describes the output of a GWAS tool for --source: the columns that
+// identify it and how its columns map to canonical names, on top of
+// Default_cnames and Extended_cnames.
+type Source struct {
+	Detect   []string
+	Cnames   map[string]string
+	Extended map[string]string
+	// P are the candidate P-value columns; the first in the file is used.
+	P []string
+}
+
+// Source_names is the order in which --source auto tries the sources.
+var Source_names = []string{"plink2", "regenie", "saige", "bolt"}
+
+var Sources = map[string]Source{
+	// PLINK 2 --glm. A1 is the tested allele; the other allele is OMITTED or
+	// AX, or else whichever of REF and ALT is not A1. With covariates there
+	// is a row per term, and TEST marks the additive test.
+	"plink2": {
+		Detect: []string{"#CHROM", "ID", "A1", "OBS_CT"},
+		Cnames: map[string]string{
+			"ID":      "SNP",
+			"A1":      "A1",
+			"OMITTED": "A2",
+			"AX":      "A2",
+			"REF":     "REF",
+			"ALT":     "ALT",
+			"A1_FREQ": "FRQ",
+			"MACH_R2": "INFO",
+			"OBS_CT":  "N",
+			"Z_STAT":  "Z",
+			"TEST":    "TEST",
+		},
+		Extended: map[string]string{
+			"#CHROM":     "CHR",
+			"POS":        "BP",
+			"LOG(OR)_SE": "SE",
+		},
+	},
+	// REGENIE step 2. ALLELE1 is the effect allele and P is given only as
+	// LOG10P.
+	"regenie": {
+		Detect: []string{"GENPOS", "ALLELE0", "ALLELE1", "A1FREQ", "LOG10P"},
+		Cnames: map[string]string{
+			"ID":      "SNP",
+			"ALLELE1": "A1",
+			"ALLELE0": "A2",
+			"A1FREQ":  "FRQ",
+			"TEST":    "TEST",
+		},
+		Extended: map[string]string{
+			"CHROM":  "CHR",
+			"GENPOS": "BP",
+		},
+	},
+	// SAIGE step 2. BETA and AF_Allele2 refer to Allele2, so Allele2 is the
+	// effect allele; p.value is the SPA corrected P-value.
+	"saige": {
+		Detect: []string{"ALLELE1", "ALLELE2", "AF_ALLELE2"},
+		Cnames: map[string]string{
+			"MARKERID":       "SNP",
+			"SNPID":          "SNP",
+			"ALLELE2":        "A1",
+			"ALLELE1":        "A2",
+			"AF_ALLELE2":     "FRQ",
+			"IMPUTATIONINFO": "INFO",
+			"N_CASE":         "N_CAS",
+			"N_CTRL":         "N_CON",
+		},
+		Extended: map[string]string{
+			"CHR": "CHR",
+			"POS": "BP",
+		},
+		P: []string{"P_VALUE"},
+	},
+	// BOLT-LMM. ALLELE1 is the effect allele and GENPOS is in cM, not bp.
+	// P_BOLT_LMM, from the non-infinitesimal model, is only written when it
+	// is computed; P_BOLT_LMM_INF always is.
+	"bolt": {
+		Detect: []string{"ALLELE1", "ALLELE0", "P_BOLT_LMM_INF"},
+		Cnames: map[string]string{
+			"SNP":     "SNP",
+			"ALLELE1": "A1",
+			"ALLELE0": "A2",
+			"A1FREQ":  "FRQ",
+		},
+		Extended: map[string]string{
+			"CHR": "CHR",
+			"BP":  "BP",
+		},
+		P: []string{"P_BOLT_LMM", "P_BOLT_LMM_INF"},
+	},
+}
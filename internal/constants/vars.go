@@ -28,6 +28,13 @@ var Default_cnames = map[string]string{
 	"P_VAL":     "P",
 	"GC_PVALUE": "P",
 
+	"LOG10P":      "LOG10P",
+	"LOG10_P":     "LOG10P",
+	"MLOG10P":     "LOG10P",
+	"NEGLOG10P":   "LOG10P",
+	"NEG_LOG10_P": "LOG10P",
+	"LOG10PVAL":   "LOG10P",
+
 	"A1":               "A1",
 	"ALLELE1":          "A1",
 	"ALLELE_1":         "A1",
@@ -63,6 +70,8 @@ var Default_cnames = map[string]string{
 	"GC_SCORE":       "Z",
 	"Z":              "Z",
 	"OR":             "OR",
+	"ODDS_RATIO":     "OR",
+	"HAZARD_RATIO":   "OR",
 	"B":              "BETA",
 	"BETA":           "BETA",
 	"LOG_ODDS":       "LOG_ODDS",
@@ -77,11 +86,48 @@ var Default_cnames = map[string]string{
 	"MAF":   "FRQ",
 	"FRQ_U": "FRQ",
 	"F_U":   "FRQ",
+
+	"EFFECT_ALLELE_FREQUENCY": "FRQ",
+	"NEG_LOG_10_P_VALUE":      "LOG10P",
+}
+
+// Extended_cnames are only used for the columns requested with --keep-cols.
+var Extended_cnames = map[string]string{
+	"CHR":        "CHR",
+	"CHROM":      "CHR",
+	"#CHROM":     "CHR",
+	"CHROMOSOME": "CHR",
+
+	"BP":       "BP",
+	"POS":      "BP",
+	"POSITION": "BP",
+
+	"BASE_PAIR_LOCATION": "BP",
+
+	"SE":             "SE",
+	"STDERR":         "SE",
+	"STANDARD_ERROR": "SE",
+	"SE_BETA":        "SE",
 }
 
+// Keep_cols maps the names accepted by --keep-cols to the columns they keep.
+var Keep_cols = map[string][]string{
+	"chr":  {"CHR"},
+	"bp":   {"BP"},
+	"se":   {"SE"},
+	"beta": {"BETA", "OR", "LOG_ODDS"},
+	"frq":  {"FRQ"},
+	"info": {"INFO"},
+}
+
+// Written_by_keep_cols are the --keep-cols names whose columns are read by
+// default, for QC and to derive Z, but only written when they are named.
+var Written_by_keep_cols = []string{"beta", "frq", "info"}
+
 var Describe_cname = map[string]string{
 	"SNP":            "Variant ID (e.g., rs number)",
 	"P":              "p-Value",
+	"LOG10P":         "-log10(p-Value)",
 	"A1":             "Allele 1, interpreted as ref allele for signed sumstat.",
 	"A2":             "Allele 2, interpreted as non-ref allele for signed sumstat.",
 	"N":              "Sample size",
@@ -95,10 +141,17 @@ var Describe_cname = map[string]string{
 	"FRQ":            "Allele frequency",
 	"SIGNED_SUMSTAT": "Directional summary statistic as specified by --signed-sumstats.",
 	"NSTUDY":         "Number of studies in which the SNP was genotyped.",
+	"CHR":            "Chromosome",
+	"BP":             "Base pair position",
+	"SE":             "Standard error of the effect size",
+	"TEST":           "Test name; only ADD rows are kept",
+	"REF":            "Reference allele, used to find A2",
+	"ALT":            "Alternate allele, used to find A2",
 }
 
 var Numeric_cols = []string{
 	"P",
+	"LOG10P",
 	"N",
 	"N_CAS",
 	"N_CON",
@@ -110,4 +163,25 @@ var Numeric_cols = []string{
 	"FRQ",
 	"SIGNED_SUMSTAT",
 	"NSTUDY",
+	"BP",
+	"SE",
+}
+
+var Null_strings = []string{"", "NA", "NaN", "nan", ".", "#NA"}
+
+// Gwas_ssf_cnames are the mandatory GWAS-SSF columns with a fixed name, by
+// which the format is recognised.
+var Gwas_ssf_cnames = []string{
+	"CHROMOSOME",
+	"BASE_PAIR_LOCATION",
+	"EFFECT_ALLELE",
+	"OTHER_ALLELE",
+	"STANDARD_ERROR",
+	"EFFECT_ALLELE_FREQUENCY",
+}
+
+var Chromosomes = []string{
+	"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11",
+	"12", "13", "14", "15", "16", "17", "18", "19", "20", "21", "22",
+	"X", "Y", "XY", "MT",
 }
@@ -0,0 +1,222 @@
+package ldsc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Gencov is an LD score regression estimate of the genetic covariance of
+// two traits, partitioned over the columns of the LD score matrix.
+type Gencov struct {
+	NAnnot      int
+	M           []float64
+	Cat         []float64
+	CatSE       []float64
+	Tot         float64
+	TotSE       float64
+	Z           float64
+	P           float64
+	Intercept   float64
+	InterceptSE float64
+	MeanZ1Z2    float64
+	// TotDeleteValues is the total genetic covariance of each jackknife
+	// block.
+	TotDeleteValues []float64
+	// ConstrainIntercept is set when the intercept was fixed rather than
+	// estimated, in which case InterceptSE is NaN.
+	ConstrainIntercept bool
+}
+
+// NewGencov regresses z1*z2 on the LD scores in x, with regression LD
+// scores w, per-SNP sample sizes N1 and N2 and per-annotation SNP counts M.
+// The weights use the total h2 and intercepts of the two traits. An
+// opts.TwoStep cutoff applies to both chi^2 statistics.
+func NewGencov(z1 []float64, z2 []float64, x *mat.Dense, w []float64, N1 []float64, N2 []float64, M []float64, hsq1 float64, hsq2 float64, intercept1 float64, intercept2 float64, opts HsqOptions) (*Gencov, error) {
+	n, k := x.Dims()
+	y := make([]float64, n)
+	N := make([]float64, n)
+	for i := range y {
+		y[i] = z1[i] * z2[i]
+		N[i] = math.Sqrt(N1[i] * N2[i])
+	}
+	var step1 []bool
+	if opts.TwoStep > 0 {
+		step1 = make([]bool, n)
+		for i := range y {
+			step1[i] = z1[i]*z1[i] < opts.TwoStep && z2[i]*z2[i] < opts.TwoStep
+		}
+	}
+	mTot := floats.Sum(M)
+	weights := func(ld []float64, idx []int, rho float64, intercept float64) []float64 {
+		return gencovWeights(ld, subset(w, idx), subset(N1, idx), subset(N2, idx), mTot, hsq1, hsq2, rho, intercept, intercept1, intercept2)
+	}
+	r, err := regress(y, x, N, M, opts, 0, step1, weights)
+	if err != nil {
+		return nil, err
+	}
+	g := &Gencov{
+		NAnnot:             k,
+		M:                  M,
+		Cat:                r.cat,
+		CatSE:              r.catSE,
+		Tot:                r.tot,
+		TotSE:              r.totSE,
+		Intercept:          r.intercept,
+		InterceptSE:        r.interceptSE,
+		MeanZ1Z2:           floats.Sum(y) / float64(n),
+		TotDeleteValues:    r.totDeleteValues(M),
+		ConstrainIntercept: r.constrain,
+	}
+	g.P, g.Z = pZNorm(g.Tot, g.TotSE)
+	return g, nil
+}
+
+// Summary formats the regression results in the layout of the LDSC log.
+// P and K hold the sample and population prevalences of the two traits,
+// NaN for a trait that stays on the observed scale.
+func (g *Gencov) Summary(names []string, P [2]float64, K [2]float64) (string, error) {
+	c, err := GencovObsToLiab(1, P[0], P[1], K[0], K[1])
+	if err != nil {
+		return "", err
+	}
+	scale := "Observed"
+	if c != 1 {
+		scale = "Liability"
+	}
+	out := []string{fmt.Sprintf("Total %s scale gencov: %s (%s)", scale, fmtFloat(c*g.Tot), fmtFloat(c*g.TotSE))}
+	if g.NAnnot > 1 {
+		cat := make([]float64, g.NAnnot)
+		catSE := make([]float64, g.NAnnot)
+		for j := range cat {
+			cat[j], catSE[j] = c*g.Cat[j], c*g.CatSE[j]
+		}
+		out = append(out,
+			"Categories: "+strings.Join(names, " "),
+			scale+" scale gencov: "+fmtFloats(cat),
+			scale+" scale gencov SE: "+fmtFloats(catSE),
+		)
+	}
+	out = append(out, "Mean z1*z2: "+fmtFloat(g.MeanZ1Z2))
+	if g.ConstrainIntercept {
+		out = append(out, "Intercept: constrained to "+fmtFloat(g.Intercept))
+	} else {
+		out = append(out, fmt.Sprintf("Intercept: %s (%s)", fmtFloat(g.Intercept), fmtFloat(g.InterceptSE)))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// RG is an LD score regression estimate of the genetic correlation of two
+// traits, from the h2 of each and their genetic covariance.
+type RG struct {
+	Hsq1   *Hsq
+	Hsq2   *Hsq
+	Gencov *Gencov
+	// RG, SE, Z and P are NaN when either h2 is not positive.
+	RG float64
+	SE float64
+	Z  float64
+	P  float64
+}
+
+// RGOptions are the regression options of an RG fit. Intercept1,
+// Intercept2 and InterceptGencov fix the intercepts of the two h2 and the
+// genetic covariance regressions when set.
+type RGOptions struct {
+	NBlocks         int
+	TwoStep         float64
+	Intercept1      *float64
+	Intercept2      *float64
+	InterceptGencov *float64
+}
+
+// NewRG estimates the genetic correlation of the traits with signed
+// statistics z1 and z2, as LDSC's RG class does.
+func NewRG(z1 []float64, z2 []float64, x *mat.Dense, w []float64, N1 []float64, N2 []float64, M []float64, opts RGOptions) (*RG, error) {
+	chisq1 := make([]float64, len(z1))
+	chisq2 := make([]float64, len(z2))
+	for i := range z1 {
+		chisq1[i], chisq2[i] = z1[i]*z1[i], z2[i]*z2[i]
+	}
+	hsq1, err := NewHsq(chisq1, x, w, N1, M, HsqOptions{NBlocks: opts.NBlocks, TwoStep: opts.TwoStep, Intercept: opts.Intercept1})
+	if err != nil {
+		return nil, err
+	}
+	hsq2, err := NewHsq(chisq2, x, w, N2, M, HsqOptions{NBlocks: opts.NBlocks, TwoStep: opts.TwoStep, Intercept: opts.Intercept2})
+	if err != nil {
+		return nil, err
+	}
+	gencov, err := NewGencov(z1, z2, x, w, N1, N2, M, hsq1.Tot, hsq2.Tot, hsq1.Intercept, hsq2.Intercept, HsqOptions{NBlocks: opts.NBlocks, TwoStep: opts.TwoStep, Intercept: opts.InterceptGencov})
+	if err != nil {
+		return nil, err
+	}
+	rg := &RG{Hsq1: hsq1, Hsq2: hsq2, Gencov: gencov, RG: math.NaN(), SE: math.NaN(), Z: math.NaN(), P: math.NaN()}
+	if hsq1.Tot <= 0 || hsq2.Tot <= 0 {
+		return rg, nil
+	}
+	nBlocks := len(gencov.TotDeleteValues)
+	numer := mat.NewDense(nBlocks, 1, gencov.TotDeleteValues)
+	denom := mat.NewDense(nBlocks, 1, nil)
+	for b := 0; b < nBlocks; b++ {
+		denom.Set(b, 0, math.Sqrt(hsq1.TotDeleteValues[b]*hsq2.TotDeleteValues[b]))
+	}
+	rg.RG = gencov.Tot / math.Sqrt(hsq1.Tot*hsq2.Tot)
+	rg.SE = RatioJackknife([]float64{rg.RG}, numer, denom).JknifeSE[0]
+	rg.P, rg.Z = pZNorm(rg.RG, rg.SE)
+	return rg, nil
+}
+
+// Summary formats the genetic correlation in the layout of the LDSC log.
+func (rg *RG) Summary() string {
+	out := []string{}
+	switch {
+	case rg.Hsq1.Tot <= 0 || rg.Hsq2.Tot <= 0:
+		out = append(out,
+			"Genetic Correlation: nan (nan) (h2  out of bounds)",
+			"Z-score: nan (nan) (h2  out of bounds)",
+			"P: nan (nan) (h2  out of bounds)",
+			"WARNING: One of the h2's was out of bounds.",
+			"This usually indicates a data-munging error or that h2 or N is low.",
+		)
+	case rg.RG > 1.2 || rg.RG < -1.2:
+		out = append(out,
+			"Genetic Correlation: nan (nan) (rg out of bounds)",
+			"Z-score: nan (nan) (rg out of bounds)",
+			"P: nan (nan) (rg out of bounds)",
+			"WARNING: rg was out of bounds.",
+			"This usually means that h2 is not significantly different from zero.",
+		)
+	default:
+		out = append(out,
+			fmt.Sprintf("Genetic Correlation: %s (%s)", fmtFloat(rg.RG), fmtFloat(rg.SE)),
+			"Z-score: "+fmtFloat(rg.Z),
+			"P: "+fmtFloat(rg.P),
+		)
+	}
+	return strings.Join(out, "\n")
+}
+
+func gencovWeights(ld []float64, wld []float64, N1 []float64, N2 []float64, M float64, h1 float64, h2 float64, rho float64, intercept float64, intercept1 float64, intercept2 float64) []float64 {
+	h1 = math.Min(math.Max(h1, 0), 1)
+	h2 = math.Min(math.Max(h2, 0), 1)
+	rho = math.Min(math.Max(rho, -1), 1)
+	w := make([]float64, len(ld))
+	for i := range ld {
+		l := math.Max(ld[i], 1)
+		a := N1[i]*h1*l/M + intercept1
+		b := N2[i]*h2*l/M + intercept2
+		c := math.Sqrt(N1[i]*N2[i])*rho*l/M + intercept
+		w[i] = 1 / (a*b + c*c) / math.Max(wld[i], 1)
+	}
+	return w
+}
+
+// pZNorm returns the two-sided normal p-value and z-score of est.
+func pZNorm(est float64, se float64) (p float64, z float64) {
+	z = est / se
+	return 2 * distuv.UnitNormal.Survival(math.Abs(z)), z
+}
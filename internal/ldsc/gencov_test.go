@@ -0,0 +1,82 @@
+package ldsc
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestGencovWeights(t *testing.T) {
+	ld := []float64{2, 0.5}
+	wld := []float64{3, 0.5}
+	N1 := []float64{9, 9}
+	N2 := []float64{4, 4}
+	w := gencovWeights(ld, wld, N1, N2, 7, 0.5, 0.3, 0.2, 0.1, 1.1, 0.9)
+	a := 9*0.5*2/7.0 + 1.1
+	b := 4*0.3*2/7.0 + 0.9
+	c := 6*0.2*2/7.0 + 0.1
+	if want := 1 / (a*b + c*c) / 3; !near(w[0], want) {
+		t.Errorf("weight %g, want %g", w[0], want)
+	}
+	// LD scores and weights below 1 are raised to 1
+	if a, b := gencovWeights(ld[1:], wld[1:], N1, N2, 7, 0.5, 0.3, 0.2, 0.1, 1.1, 0.9), gencovWeights([]float64{1}, []float64{1}, N1, N2, 7, 0.5, 0.3, 0.2, 0.1, 1.1, 0.9); !near(a[0], b[0]) {
+		t.Errorf("weights %g and %g differ", a[0], b[0])
+	}
+}
+
+// A trait paired with itself has a genetic covariance equal to its h2 and
+// a genetic correlation of 1.
+func TestRGSameTrait(t *testing.T) {
+	_, x2, w, N, _ := coefData(1.05)
+	n, _ := x2.Dims()
+	x := mat.NewDense(n, 1, nil)
+	z := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, x2.At(i, 0)+x2.At(i, 1))
+		z[i] = math.Sqrt(1.05 + N[i]*x.At(i, 0)*0.9/1e7)
+	}
+	fixed := 1.05
+	for name, opts := range map[string]RGOptions{
+		"free intercepts": {NBlocks: 20},
+		"two-step":        {NBlocks: 20, TwoStep: 1.1},
+		"fixed gencov intercept": {
+			NBlocks: 20, Intercept1: &fixed, Intercept2: &fixed, InterceptGencov: &fixed,
+		},
+	} {
+		rg, err := NewRG(z, z, x, w, N, N, []float64{1e7}, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !near(rg.Gencov.Tot, 0.9) || !near(rg.Gencov.Intercept, 1.05) {
+			t.Errorf("%s: gencov %g and intercept %g, want 0.9 and 1.05", name, rg.Gencov.Tot, rg.Gencov.Intercept)
+		}
+		if !near(rg.RG, 1) {
+			t.Errorf("%s: rg %g, want 1", name, rg.RG)
+		}
+		if rg.Gencov.ConstrainIntercept != (opts.InterceptGencov != nil) {
+			t.Errorf("%s: gencov intercept constrained is %v", name, rg.Gencov.ConstrainIntercept)
+		}
+	}
+}
+
+func TestRGNegativeH2(t *testing.T) {
+	_, x2, w, N, _ := coefData(1)
+	n, _ := x2.Dims()
+	x := mat.NewDense(n, 1, nil)
+	z1 := make([]float64, n)
+	z2 := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, x2.At(i, 0)+x2.At(i, 1))
+		z1[i] = math.Sqrt(1 + N[i]*x.At(i, 0)*0.5/1e7)
+		// chi^2 falling with LD score gives a negative h2
+		z2[i] = math.Sqrt(2 - N[i]*x.At(i, 0)*0.1/1e7)
+	}
+	rg, err := NewRG(z1, z2, x, w, N, N, []float64{1e7}, RGOptions{NBlocks: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.Hsq2.Tot >= 0 || !math.IsNaN(rg.RG) {
+		t.Errorf("h2 %g and rg %g, want a negative h2 and NaN rg", rg.Hsq2.Tot, rg.RG)
+	}
+}
@@ -0,0 +1,288 @@
+package ldsc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+type HsqOptions struct {
+	NBlocks int
+	// TwoStep is the chi^2 cutoff for the first step of the two-step
+	// estimator, 0 disables it.
+	TwoStep float64
+	// OldWeights weights the regression once with the initial weights
+	// instead of iterating, as LDSC does for partitioned LD scores.
+	OldWeights bool
+	// Intercept fixes the regression intercept instead of estimating it.
+	Intercept *float64
+}
+
+// Hsq is an LD score regression estimate of SNP heritability, partitioned
+// over the columns of the LD score matrix.
+type Hsq struct {
+	NAnnot          int
+	NBlocks         int
+	M               []float64
+	Coef            []float64
+	CoefCov         *mat.Dense
+	CoefSE          []float64
+	Cat             []float64
+	CatCov          *mat.Dense
+	CatSE           []float64
+	Tot             float64
+	TotSE           float64
+	Prop            []float64
+	PropCov         *mat.Dense
+	PropSE          []float64
+	Enrichment      []float64
+	MProp           []float64
+	Intercept       float64
+	InterceptSE     float64
+	MeanChisq       float64
+	LambdaGC        float64
+	TwostepFiltered int
+	Jknife          *Jackknife
+	// TotDeleteValues is the total h2 of each jackknife block.
+	TotDeleteValues []float64
+	// ConstrainIntercept is set when the intercept was fixed rather than
+	// estimated, in which case InterceptSE is NaN.
+	ConstrainIntercept bool
+}
+
+type OverlapResult struct {
+	Category      string
+	PropSNPs      float64
+	PropH2        float64
+	PropH2SE      float64
+	Enrichment    float64
+	EnrichmentSE  float64
+	EnrichmentP   float64
+	Coefficient   float64
+	CoefficientSE float64
+	CoefficientZ  float64
+}
+
+// NewHsq regresses chi^2 (y) on the LD scores in x, one column per
+// annotation, with regression LD scores w, per-SNP sample sizes N and
+// per-annotation SNP counts M.
+func NewHsq(y []float64, x *mat.Dense, w []float64, N []float64, M []float64, opts HsqOptions) (*Hsq, error) {
+	n, k := x.Dims()
+	var step1 []bool
+	if opts.TwoStep > 0 {
+		step1 = make([]bool, n)
+		for i, v := range y {
+			step1[i] = v < opts.TwoStep
+		}
+	}
+	mTot := floats.Sum(M)
+	weights := func(ld []float64, idx []int, hsq float64, intercept float64) []float64 {
+		return hsqWeights(ld, subset(w, idx), subset(N, idx), mTot, hsq, intercept)
+	}
+	r, err := regress(y, x, N, M, opts, 1, step1, weights)
+	if err != nil {
+		return nil, err
+	}
+	jknife, nbar := r.jknife, r.nbar
+	h := &Hsq{
+		NAnnot:             k,
+		NBlocks:            opts.NBlocks,
+		M:                  M,
+		Coef:               r.coef,
+		CoefCov:            r.coefCov,
+		CoefSE:             r.coefSE,
+		Cat:                r.cat,
+		CatCov:             r.catCov,
+		CatSE:              r.catSE,
+		Tot:                r.tot,
+		TotSE:              r.totSE,
+		Intercept:          r.intercept,
+		InterceptSE:        r.interceptSE,
+		TwostepFiltered:    r.twostepFiltered,
+		Jknife:             jknife,
+		ConstrainIntercept: r.constrain,
+		TotDeleteValues:    r.totDeleteValues(M),
+	}
+
+	nBlocks, _ := jknife.DeleteValues.Dims()
+	numer := mat.NewDense(nBlocks, k, nil)
+	denom := mat.NewDense(nBlocks, k, nil)
+	for b := 0; b < nBlocks; b++ {
+		rowSum := 0.0
+		for j := 0; j < k; j++ {
+			numer.Set(b, j, M[j]*jknife.DeleteValues.At(b, j)/nbar)
+			rowSum += numer.At(b, j)
+		}
+		for j := 0; j < k; j++ {
+			denom.Set(b, j, rowSum)
+		}
+	}
+	est := make([]float64, k)
+	for j := range est {
+		est[j] = h.Cat[j] / h.Tot
+	}
+	prop := RatioJackknife(est, numer, denom)
+	h.Prop, h.PropCov, h.PropSE = prop.Est, prop.JknifeCov, prop.JknifeSE
+
+	h.MProp = make([]float64, k)
+	h.Enrichment = make([]float64, k)
+	for j := 0; j < k; j++ {
+		h.MProp[j] = M[j] / mTot
+		h.Enrichment[j] = (h.Cat[j] / M[j]) / (h.Tot / mTot)
+	}
+	h.MeanChisq = floats.Sum(y) / float64(n)
+	h.LambdaGC = median(y) / 0.4549
+	return h, nil
+}
+
+// OverlapResults computes per-category heritability, enrichment and
+// coefficient statistics allowing for annotations that overlap. overlap is
+// the annotation cross-product matrix over the mTot SNPs used for M.
+func (h *Hsq) OverlapResults(names []string, overlap *mat.Dense, mAnnot []float64, mTot float64) []OverlapResult {
+	k := h.NAnnot
+	overlapProp := mat.NewDense(k, k, nil)
+	diff := mat.NewDense(k, k, nil)
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			overlapProp.Set(i, j, overlap.At(i, j)/mAnnot[j])
+			if mTot != mAnnot[i] {
+				diff.Set(i, j, overlap.At(i, j)/mAnnot[i]-(mAnnot[j]-overlap.At(i, j))/(mTot-mAnnot[i]))
+			}
+		}
+	}
+	propH2 := mat.NewVecDense(k, nil)
+	propH2.MulVec(overlapProp, mat.NewVecDense(k, h.Prop))
+	var propVar mat.Dense
+	propVar.Product(overlapProp, h.PropCov, overlapProp.T())
+	diffEst := mat.NewVecDense(k, nil)
+	diffEst.MulVec(diff, mat.NewVecDense(k, h.Coef))
+	var diffCov mat.Dense
+	diffCov.Product(diff, h.CoefCov, diff.T())
+
+	t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(h.NBlocks)}
+	results := make([]OverlapResult, k)
+	for i := 0; i < k; i++ {
+		propM := mAnnot[i] / mTot
+		propSE := math.Sqrt(math.Max(0, propVar.At(i, i)))
+		diffSE := math.Sqrt(diffCov.At(i, i))
+		p := math.NaN()
+		if diffSE != 0 {
+			p = 2 * t.Survival(math.Abs(diffEst.AtVec(i)/diffSE))
+		}
+		results[i] = OverlapResult{
+			Category:      names[i],
+			PropSNPs:      propM,
+			PropH2:        propH2.AtVec(i),
+			PropH2SE:      propSE,
+			Enrichment:    propH2.AtVec(i) / propM,
+			EnrichmentSE:  propSE / propM,
+			EnrichmentP:   p,
+			Coefficient:   h.Coef[i],
+			CoefficientSE: h.CoefSE[i],
+			CoefficientZ:  h.Coef[i] / h.CoefSE[i],
+		}
+	}
+	return results
+}
+
+// Summary formats the regression results in the layout of the LDSC log. When
+// the sample and population prevalences P and K are not NaN the liability
+// scale estimates are reported after the observed scale ones.
+func (h *Hsq) Summary(names []string, P float64, K float64) (string, error) {
+	c, err := H2ObsToLiab(1, P, K)
+	if err != nil {
+		return "", err
+	}
+	liab := !math.IsNaN(P) || !math.IsNaN(K)
+	out := []string{fmt.Sprintf("Total Observed scale h2: %s (%s)", fmtFloat(h.Tot), fmtFloat(h.TotSE))}
+	if liab {
+		out = append(out, fmt.Sprintf("Total Liability scale h2: %s (%s)", fmtFloat(c*h.Tot), fmtFloat(c*h.TotSE)))
+	}
+	if h.NAnnot > 1 {
+		out = append(out,
+			"Categories: "+strings.Join(names, " "),
+			"Observed scale h2: "+fmtFloats(h.Cat),
+			"Observed scale h2 SE: "+fmtFloats(h.CatSE),
+		)
+		if liab {
+			cat := make([]float64, h.NAnnot)
+			catSE := make([]float64, h.NAnnot)
+			for j := range cat {
+				cat[j], catSE[j] = c*h.Cat[j], c*h.CatSE[j]
+			}
+			out = append(out,
+				"Liability scale h2: "+fmtFloats(cat),
+				"Liability scale h2 SE: "+fmtFloats(catSE),
+			)
+		}
+		out = append(out,
+			"Proportion of SNPs: "+fmtFloats(h.MProp),
+			"Proportion of h2g: "+fmtFloats(h.Prop),
+			"Enrichment: "+fmtFloats(h.Enrichment),
+			"Coefficients: "+fmtFloats(h.Coef),
+			"Coefficient SE: "+fmtFloats(h.CoefSE),
+		)
+	}
+	out = append(out,
+		"Lambda GC: "+fmtFloat(h.LambdaGC),
+		"Mean Chi^2: "+fmtFloat(h.MeanChisq),
+	)
+	if h.ConstrainIntercept {
+		out = append(out, "Intercept: constrained to "+fmtFloat(h.Intercept))
+		return strings.Join(out, "\n"), nil
+	}
+	out = append(out, fmt.Sprintf("Intercept: %s (%s)", fmtFloat(h.Intercept), fmtFloat(h.InterceptSE)))
+	if h.MeanChisq > 1 {
+		ratio := (h.Intercept - 1) / (h.MeanChisq - 1)
+		if ratio < 0 {
+			out = append(out, "Ratio < 0 (usually indicates GC correction).")
+		} else {
+			out = append(out, fmt.Sprintf("Ratio: %s (%s)", fmtFloat(ratio), fmtFloat(h.InterceptSE/(h.MeanChisq-1))))
+		}
+	} else {
+		out = append(out, "Ratio: NA (mean chi^2 < 1)")
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+func hsqWeights(ld []float64, wld []float64, N []float64, M float64, hsq float64, intercept float64) []float64 {
+	hsq = math.Min(math.Max(hsq, 0), 1)
+	w := make([]float64, len(ld))
+	for i := range ld {
+		l := math.Max(ld[i], 1)
+		c := hsq * N[i] / M
+		het := 1 / (2 * math.Pow(intercept+c*l, 2))
+		w[i] = het / math.Max(wld[i], 1)
+	}
+	return w
+}
+
+func median(x []float64) float64 {
+	s := append([]float64(nil), x...)
+	sort.Float64s(s)
+	n := len(s)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func fmtFloat(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
+
+func fmtFloats(v []float64) string {
+	s := make([]string, len(v))
+	for i := range v {
+		s[i] = fmtFloat(v[i])
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,137 @@
+package ldsc
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The tests follow LDSC's test_regressions.py.
+
+func TestAggregate(t *testing.T) {
+	y := make([]float64, 10)
+	ld := make([]float64, 10)
+	N := make([]float64, 10)
+	for i := range y {
+		y[i], ld[i], N[i] = 1.5, 100, 1e5
+	}
+	if agg := aggregate(y, ld, N, 1e7, 1); !near(agg, 0.5) {
+		t.Errorf("aggregate = %g, want 0.5", agg)
+	}
+	if agg := aggregate(y, ld, N, 1e7, 1.5); !near(agg, 0) {
+		t.Errorf("aggregate with intercept 1.5 = %g, want 0", agg)
+	}
+}
+
+func TestHsqWeights(t *testing.T) {
+	ld := []float64{1, 1, 1, 1}
+	N := []float64{9, 9, 9, 9}
+	w := hsqWeights(ld, ld, N, 7, 0.5, 1)
+	if want := 0.5 / math.Pow(1+0.5*9/7, 2); !near(w[0], want) {
+		t.Errorf("weight %g, want %g", w[0], want)
+	}
+	// h2 is clipped to [0, 1]
+	for _, c := range [][2]float64{{1, 2}, {0, -1}} {
+		if a, b := hsqWeights(ld, ld, N, 7, c[0], 1), hsqWeights(ld, ld, N, 7, c[1], 1); !near(a[0], b[0]) {
+			t.Errorf("weights of h2 %g and %g differ: %g, %g", c[0], c[1], a[0], b[0])
+		}
+	}
+}
+
+// coefData is chi^2 without noise for two annotations with h2 0.2 and 0.7
+// over 5e6 SNPs each, so that every estimator recovers them exactly.
+func coefData(intercept float64) (y []float64, x *mat.Dense, w []float64, N []float64, M []float64) {
+	const n = 400
+	hsq := []float64{0.2, 0.7}
+	M = []float64{5e6, 5e6}
+	x = mat.NewDense(n, 2, nil)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, 1+float64(i*37%101)/25)
+		x.Set(i, 1, 1+float64(i*53%89)/30)
+		N = append(N, 1e5+float64(i%7)*1e4)
+		w = append(w, 1+float64(i%5))
+		y = append(y, intercept+N[i]*(x.At(i, 0)*hsq[0]/M[0]+x.At(i, 1)*hsq[1]/M[1]))
+	}
+	return
+}
+
+func TestHsqCoef(t *testing.T) {
+	y, x, w, N, M := coefData(1.05)
+	fixed := 1.05
+	for name, opts := range map[string]HsqOptions{
+		"free intercept":  {NBlocks: 20},
+		"fixed intercept": {NBlocks: 20, Intercept: &fixed},
+		"old weights":     {NBlocks: 20, OldWeights: true},
+	} {
+		h, err := NewHsq(y, x, w, N, M, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for j, want := range []float64{0.2 / 5e6, 0.7 / 5e6} {
+			if !near(h.Coef[j]*5e6, want*5e6) {
+				t.Errorf("%s: coefficient %d is %g, want %g", name, j, h.Coef[j], want)
+			}
+		}
+		checks := []struct {
+			what      string
+			got, want float64
+		}{
+			{"h2 of annotation 0", h.Cat[0], 0.2},
+			{"h2 of annotation 1", h.Cat[1], 0.7},
+			{"total h2", h.Tot, 0.9},
+			{"proportion of h2 0", h.Prop[0], 0.2 / 0.9},
+			{"proportion of h2 1", h.Prop[1], 0.7 / 0.9},
+			{"enrichment 0", h.Enrichment[0], (0.2 / 5e6) / (0.9 / 1e7)},
+			{"enrichment 1", h.Enrichment[1], (0.7 / 5e6) / (0.9 / 1e7)},
+			{"intercept", h.Intercept, 1.05},
+		}
+		for _, c := range checks {
+			if !near(c.got, c.want) {
+				t.Errorf("%s: %s is %g, want %g", name, c.what, c.got, c.want)
+			}
+		}
+		if h.TotSE > 1e-8 {
+			t.Errorf("%s: total h2 SE of noiseless data is %g", name, h.TotSE)
+		}
+		if opts.Intercept != nil && (!h.ConstrainIntercept || !math.IsNaN(h.InterceptSE)) {
+			t.Errorf("%s: intercept not reported as constrained", name)
+		}
+	}
+}
+
+func TestHsqTwoStep(t *testing.T) {
+	// the two annotations pooled into one with h2 0.9 over 1e7 SNPs
+	_, x2, w, N, _ := coefData(1.05)
+	n, _ := x2.Dims()
+	x := mat.NewDense(n, 1, nil)
+	y := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, x2.At(i, 0)+x2.At(i, 1))
+		y[i] = 1.05 + N[i]*x.At(i, 0)*0.9/1e7
+	}
+	h, err := NewHsq(y, x, w, N, []float64{1e7}, HsqOptions{NBlocks: 20, TwoStep: 1.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.TwostepFiltered == 0 || h.TwostepFiltered == n {
+		t.Fatalf("two-step cutoff filtered %d of %d SNPs", h.TwostepFiltered, n)
+	}
+	if !near(h.Tot, 0.9) || !near(h.Intercept, 1.05) {
+		t.Errorf("two-step h2 %g and intercept %g, want 0.9 and 1.05", h.Tot, h.Intercept)
+	}
+}
+
+func TestHsqSummarizeChisq(t *testing.T) {
+	y := []float64{4, 4, 4, 4}
+	x := mat.NewDense(4, 1, []float64{1, 2, 3, 4})
+	ones := []float64{1, 1, 1, 1}
+	N := []float64{9, 9, 9, 9}
+	h, err := NewHsq(y, x, ones, N, []float64{7}, HsqOptions{NBlocks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.MeanChisq != 4 || !near(h.LambdaGC, 4/0.4549) {
+		t.Errorf("mean chi^2 %g and lambda GC %g, want 4 and %g", h.MeanChisq, h.LambdaGC, 4/0.4549)
+	}
+}
@@ -0,0 +1,84 @@
+package ldsc
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// IRWLS runs iteratively re-weighted least squares. update receives the
+// current coefficients and returns new (unsquared) regression weights. The
+// final weighted fit is block jackknifed.
+func IRWLS(x *mat.Dense, y []float64, update func(coef []float64) ([]float64, error), nBlocks int, w []float64, separators []int) (*Jackknife, error) {
+	n, _ := x.Dims()
+	if w == nil {
+		w = make([]float64, n)
+		for i := range w {
+			w[i] = 1
+		}
+	}
+	sw := make([]float64, n)
+	for i := range w {
+		sw[i] = math.Sqrt(w[i])
+	}
+	for i := 0; i < 2; i++ {
+		coef, err := WLS(x, y, sw)
+		if err != nil {
+			return nil, err
+		}
+		nw, err := update(coef)
+		if err != nil {
+			return nil, err
+		}
+		for j := range nw {
+			sw[j] = math.Sqrt(nw[j])
+		}
+	}
+	xw, err := Weight(x, sw)
+	if err != nil {
+		return nil, err
+	}
+	yw, err := Weight(mat.NewDense(n, 1, append([]float64(nil), y...)), sw)
+	if err != nil {
+		return nil, err
+	}
+	return LstsqJackknifeFast(xw, mat.Col(nil, 0, yw), nBlocks, separators)
+}
+
+// WLS solves the least squares problem for x and y weighted by w.
+func WLS(x *mat.Dense, y []float64, w []float64) ([]float64, error) {
+	n, _ := x.Dims()
+	xw, err := Weight(x, w)
+	if err != nil {
+		return nil, err
+	}
+	yw, err := Weight(mat.NewDense(n, 1, append([]float64(nil), y...)), w)
+	if err != nil {
+		return nil, err
+	}
+	var coef mat.Dense
+	if err := coef.Solve(xw, yw); err != nil {
+		return nil, err
+	}
+	return mat.Col(nil, 0, &coef), nil
+}
+
+// Weight multiplies each row of x by w, normalised to sum to one.
+func Weight(x *mat.Dense, w []float64) (*mat.Dense, error) {
+	n, p := x.Dims()
+	total := 0.0
+	for _, v := range w {
+		if v <= 0 {
+			return nil, errors.New("weights must be > 0")
+		}
+		total += v
+	}
+	xw := mat.NewDense(n, p, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < p; j++ {
+			xw.Set(i, j, x.At(i, j)*w[i]/total)
+		}
+	}
+	return xw, nil
+}
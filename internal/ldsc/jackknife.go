@@ -0,0 +1,146 @@
+package ldsc
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Jackknife holds a block jackknife fit. Est is the full-data estimate, the
+// Jknife fields are derived from the pseudovalues.
+type Jackknife struct {
+	Est          []float64
+	JknifeEst    []float64
+	JknifeVar    []float64
+	JknifeSE     []float64
+	JknifeCov    *mat.Dense
+	DeleteValues *mat.Dense
+	Separators   []int
+}
+
+// Separators splits n rows into nBlocks contiguous blocks, matching
+// np.floor(np.linspace(0, n, nBlocks+1)).
+func Separators(n int, nBlocks int) []int {
+	s := make([]int, nBlocks+1)
+	for i := range s {
+		s[i] = int(math.Floor(float64(n) * float64(i) / float64(nBlocks)))
+	}
+	return s
+}
+
+// LstsqJackknifeFast fits y ~ x by least squares and computes the delete
+// values of each block from the block-wise X'X and X'y sums.
+func LstsqJackknifeFast(x *mat.Dense, y []float64, nBlocks int, separators []int) (*Jackknife, error) {
+	n, p := x.Dims()
+	if len(y) != n {
+		return nil, errors.New("x and y must have the same number of rows")
+	}
+	if separators == nil {
+		if nBlocks > n {
+			return nil, errors.New("more blocks than data points")
+		}
+		separators = Separators(n, nBlocks)
+	}
+	nBlocks = len(separators) - 1
+	yv := mat.NewDense(n, 1, y)
+
+	xtx := make([]*mat.Dense, nBlocks)
+	xty := make([]*mat.Dense, nBlocks)
+	xtxTot := mat.NewDense(p, p, nil)
+	xtyTot := mat.NewDense(p, 1, nil)
+	for i := 0; i < nBlocks; i++ {
+		xtx[i] = mat.NewDense(p, p, nil)
+		xty[i] = mat.NewDense(p, 1, nil)
+		if separators[i+1] > separators[i] {
+			xb := x.Slice(separators[i], separators[i+1], 0, p)
+			yb := yv.Slice(separators[i], separators[i+1], 0, 1)
+			xtx[i].Mul(xb.T(), xb)
+			xty[i].Mul(xb.T(), yb)
+		}
+		xtxTot.Add(xtxTot, xtx[i])
+		xtyTot.Add(xtyTot, xty[i])
+	}
+
+	var est mat.Dense
+	if err := est.Solve(xtxTot, xtyTot); err != nil {
+		return nil, err
+	}
+	deleteValues := mat.NewDense(nBlocks, p, nil)
+	var a, b, d mat.Dense
+	for i := 0; i < nBlocks; i++ {
+		a.Sub(xtxTot, xtx[i])
+		b.Sub(xtyTot, xty[i])
+		if err := d.Solve(&a, &b); err != nil {
+			return nil, err
+		}
+		deleteValues.SetRow(i, mat.Col(nil, 0, &d))
+	}
+
+	j := newJackknife(mat.Col(nil, 0, &est), deleteValues, DeleteValuesToPseudovalues(deleteValues, mat.Col(nil, 0, &est)))
+	j.Separators = separators
+	return j, nil
+}
+
+// RatioJackknife estimates the standard error of est = numer / denom from the
+// block delete values of the numerator and denominator.
+func RatioJackknife(est []float64, numer *mat.Dense, denom *mat.Dense) *Jackknife {
+	nBlocks, p := numer.Dims()
+	pseudovalues := mat.NewDense(nBlocks, p, nil)
+	for i := 0; i < nBlocks; i++ {
+		for j := 0; j < p; j++ {
+			pseudovalues.Set(i, j, float64(nBlocks)*est[j]-float64(nBlocks-1)*numer.At(i, j)/denom.At(i, j))
+		}
+	}
+	return newJackknife(est, nil, pseudovalues)
+}
+
+// DeleteValuesToPseudovalues converts block delete values to jackknife
+// pseudovalues.
+func DeleteValuesToPseudovalues(deleteValues *mat.Dense, est []float64) *mat.Dense {
+	nBlocks, p := deleteValues.Dims()
+	pseudovalues := mat.NewDense(nBlocks, p, nil)
+	for i := 0; i < nBlocks; i++ {
+		for j := 0; j < p; j++ {
+			pseudovalues.Set(i, j, float64(nBlocks)*est[j]-float64(nBlocks-1)*deleteValues.At(i, j))
+		}
+	}
+	return pseudovalues
+}
+
+func newJackknife(est []float64, deleteValues *mat.Dense, pseudovalues *mat.Dense) *Jackknife {
+	nBlocks, p := pseudovalues.Dims()
+	mean := make([]float64, p)
+	for j := 0; j < p; j++ {
+		for i := 0; i < nBlocks; i++ {
+			mean[j] += pseudovalues.At(i, j)
+		}
+		mean[j] /= float64(nBlocks)
+	}
+	cov := mat.NewDense(p, p, nil)
+	for j := 0; j < p; j++ {
+		for k := j; k < p; k++ {
+			s := 0.0
+			for i := 0; i < nBlocks; i++ {
+				s += (pseudovalues.At(i, j) - mean[j]) * (pseudovalues.At(i, k) - mean[k])
+			}
+			s /= float64(nBlocks-1) * float64(nBlocks)
+			cov.Set(j, k, s)
+			cov.Set(k, j, s)
+		}
+	}
+	variance := make([]float64, p)
+	se := make([]float64, p)
+	for j := 0; j < p; j++ {
+		variance[j] = cov.At(j, j)
+		se[j] = math.Sqrt(variance[j])
+	}
+	return &Jackknife{
+		Est:          est,
+		JknifeEst:    mean,
+		JknifeVar:    variance,
+		JknifeSE:     se,
+		JknifeCov:    cov,
+		DeleteValues: deleteValues,
+	}
+}
@@ -0,0 +1,138 @@
+package ldsc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The tests follow LDSC's test_jackknife.py.
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-8*math.Max(1, math.Abs(b))
+}
+
+func TestSeparators(t *testing.T) {
+	for nBlocks := 2; nBlocks < 10; nBlocks++ {
+		s := Separators(20, nBlocks)
+		if s[0] != 0 || s[nBlocks] != 20 {
+			t.Errorf("%d blocks: separators %v do not span 0 to 20", nBlocks, s)
+		}
+		min, max := 20, 0
+		for j := 0; j < nBlocks; j++ {
+			n := s[j+1] - s[j]
+			if n < min {
+				min = n
+			}
+			if n > max {
+				max = n
+			}
+		}
+		if max-min > 1 {
+			t.Errorf("%d blocks: block sizes from %d to %d", nBlocks, min, max)
+		}
+	}
+}
+
+func TestJackknife1D(t *testing.T) {
+	pseudovalues := mat.NewDense(10, 1, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	j := newJackknife([]float64{4.5}, nil, pseudovalues)
+	if !near(j.JknifeEst[0], 4.5) || !near(j.JknifeVar[0], 0.91666666666666667) ||
+		!near(j.JknifeCov.At(0, 0), j.JknifeVar[0]) || !near(j.JknifeSE[0]*j.JknifeSE[0], j.JknifeVar[0]) {
+		t.Errorf("got est %v, var %v, se %v", j.JknifeEst, j.JknifeVar, j.JknifeSE)
+	}
+}
+
+func TestDeleteValuesToPseudovalues(t *testing.T) {
+	for _, p := range []int{1, 2} {
+		est := make([]float64, p)
+		deleteValues := mat.NewDense(20, p, nil)
+		for i := range est {
+			est[i] = 1
+			for b := 0; b < 20; b++ {
+				deleteValues.Set(b, i, 1)
+			}
+		}
+		pv := DeleteValuesToPseudovalues(deleteValues, est)
+		if !mat.EqualApprox(pv, deleteValues, 1e-12) {
+			t.Errorf("pseudovalues of constant delete values are %v", mat.Formatted(pv))
+		}
+	}
+}
+
+func TestLstsqJackknife(t *testing.T) {
+	x := mat.NewDense(10, 1, nil)
+	y := make([]float64, 10)
+	for i := range y {
+		x.Set(i, 0, float64(i))
+		y[i] = 2 * float64(i)
+	}
+	j, err := LstsqJackknifeFast(x, y, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(j.Est[0], 2) || !near(j.JknifeEst[0], 2) || j.JknifeSE[0] > 1e-8 {
+		t.Errorf("y = 2x: est %v, jackknife est %v, se %v", j.Est, j.JknifeEst, j.JknifeSE)
+	}
+}
+
+// TestLstsqJackknifeFastEqSlow checks the delete values against refitting
+// with each block left out.
+func TestLstsqJackknifeFastEqSlow(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 100
+	x := mat.NewDense(n, 2, nil)
+	y := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, rng.NormFloat64())
+		x.Set(i, 1, rng.NormFloat64())
+		y[i] = rng.NormFloat64()
+	}
+	for nBlocks := 2; nBlocks < 49; nBlocks++ {
+		fast, err := LstsqJackknifeFast(x, y, nBlocks, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := Separators(n, nBlocks)
+		for b := 0; b < nBlocks; b++ {
+			rows := n - (s[b+1] - s[b])
+			xd := mat.NewDense(rows, 2, nil)
+			yd := mat.NewDense(rows, 1, nil)
+			r := 0
+			for i := 0; i < n; i++ {
+				if i >= s[b] && i < s[b+1] {
+					continue
+				}
+				xd.SetRow(r, x.RawRowView(i))
+				yd.Set(r, 0, y[i])
+				r++
+			}
+			var slow mat.Dense
+			if err := slow.Solve(xd, yd); err != nil {
+				t.Fatal(err)
+			}
+			for k := 0; k < 2; k++ {
+				if !near(fast.DeleteValues.At(b, k), slow.At(k, 0)) {
+					t.Fatalf("%d blocks, block %d: delete value %g, refit gives %g", nBlocks, b, fast.DeleteValues.At(b, k), slow.At(k, 0))
+				}
+			}
+		}
+	}
+}
+
+func TestRatioJackknife(t *testing.T) {
+	numer := mat.NewDense(10, 1, nil)
+	denom := mat.NewDense(10, 1, nil)
+	for i := 0; i < 10; i++ {
+		numer.Set(i, 0, float64(i+1))
+		denom.Set(i, 0, -float64(i+1))
+	}
+	denom.Set(9, 0, -9)
+	j := RatioJackknife([]float64{-1}, numer, denom)
+	if j.Est[0] != -1 || !near(j.JknifeEst[0], -0.9) || !near(j.JknifeSE[0], 0.1) ||
+		!near(j.JknifeVar[0], 0.01) || !near(j.JknifeCov.At(0, 0), 0.01) {
+		t.Errorf("got est %v, jackknife est %v, se %v, var %v", j.Est, j.JknifeEst, j.JknifeSE, j.JknifeVar)
+	}
+}
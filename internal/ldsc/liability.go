@@ -0,0 +1,42 @@
+package ldsc
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// H2ObsToLiab converts observed scale heritability of a case/control trait
+// with sample prevalence P to the liability scale for population prevalence
+// K. If both P and K are NaN h2obs is returned unchanged.
+func H2ObsToLiab(h2obs float64, P float64, K float64) (float64, error) {
+	if math.IsNaN(P) && math.IsNaN(K) {
+		return h2obs, nil
+	}
+	if !(K > 0 && K < 1) {
+		return 0, errors.New("population prevalence must be in the range (0,1)")
+	}
+	if !(P > 0 && P < 1) {
+		return 0, errors.New("sample prevalence must be in the range (0,1)")
+	}
+	thresh := distuv.UnitNormal.Quantile(1 - K)
+	z := distuv.UnitNormal.Prob(thresh)
+	conversion := K * K * (1 - K) * (1 - K) / (P * (1 - P) * z * z)
+	return h2obs * conversion, nil
+}
+
+// GencovObsToLiab converts observed scale genetic covariance to the liability
+// scale using the square roots of each trait's heritability conversion factor.
+// A trait whose prevalences are both NaN is left on the observed scale.
+func GencovObsToLiab(gencov float64, P1 float64, P2 float64, K1 float64, K2 float64) (float64, error) {
+	c1, err := H2ObsToLiab(1, P1, K1)
+	if err != nil {
+		return 0, err
+	}
+	c2, err := H2ObsToLiab(1, P2, K2)
+	if err != nil {
+		return 0, err
+	}
+	return gencov * math.Sqrt(c1) * math.Sqrt(c2), nil
+}
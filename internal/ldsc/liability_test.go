@@ -0,0 +1,35 @@
+package ldsc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestH2ObsToLiab(t *testing.T) {
+	// with K = P = 0.5 the threshold is 0 and the factor is
+	// 0.5^4 / (0.25 * phi(0)^2) = pi / 2
+	if h2, err := H2ObsToLiab(1, 0.5, 0.5); err != nil || !near(h2, math.Pi/2) {
+		t.Errorf("H2ObsToLiab(1, 0.5, 0.5) = %g, %v, want %g", h2, err, math.Pi/2)
+	}
+	if h2, err := H2ObsToLiab(0.3, math.NaN(), math.NaN()); err != nil || h2 != 0.3 {
+		t.Errorf("H2ObsToLiab without prevalences = %g, %v, want 0.3", h2, err)
+	}
+	for _, c := range [][2]float64{{0.5, 0}, {0.5, 1}, {0, 0.5}, {1.5, 0.5}} {
+		if _, err := H2ObsToLiab(1, c[0], c[1]); err == nil {
+			t.Errorf("H2ObsToLiab(1, %g, %g) gave no error", c[0], c[1])
+		}
+	}
+}
+
+func TestGencovObsToLiab(t *testing.T) {
+	// each trait contributes the square root of its h2 factor
+	if gc, err := GencovObsToLiab(1, 0.5, 0.5, 0.5, 0.5); err != nil || !near(gc, math.Pi/2) {
+		t.Errorf("GencovObsToLiab of two traits = %g, %v, want %g", gc, err, math.Pi/2)
+	}
+	if gc, err := GencovObsToLiab(1, 0.5, math.NaN(), 0.5, math.NaN()); err != nil || !near(gc, math.Sqrt(math.Pi/2)) {
+		t.Errorf("GencovObsToLiab of one trait = %g, %v, want %g", gc, err, math.Sqrt(math.Pi/2))
+	}
+	if _, err := GencovObsToLiab(1, 0.5, 0.5, 0.5, 0); err == nil {
+		t.Error("GencovObsToLiab with a population prevalence of 0 gave no error")
+	}
+}
@@ -0,0 +1,261 @@
+package ldsc
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// weightFunc returns the regression weights of the rows idx, or of every
+// row if idx is nil, given their total LD scores ld, the current estimate
+// tot of the total h2 or genetic covariance, and the intercept.
+type weightFunc func(ld []float64, idx []int, tot float64, intercept float64) []float64
+
+// regression is the fit shared by Hsq and Gencov, following
+// LD_Score_Regression in LDSC's regressions.py.
+type regression struct {
+	jknife          *Jackknife
+	nbar            float64
+	constrain       bool
+	intercept       float64
+	interceptSE     float64
+	coef            []float64
+	coefCov         *mat.Dense
+	coefSE          []float64
+	cat             []float64
+	catCov          *mat.Dense
+	catSE           []float64
+	tot             float64
+	totSE           float64
+	twostepFiltered int
+}
+
+// regress regresses y on the LD scores in x scaled by N / mean(N). The
+// intercept is opts.Intercept if set and estimated otherwise, starting from
+// null. step1 marks the rows of the first step of the two-step estimator,
+// and is nil unless opts.TwoStep is set.
+func regress(y []float64, x *mat.Dense, N []float64, M []float64, opts HsqOptions, null float64, step1 []bool, weights weightFunc) (*regression, error) {
+	n, k := x.Dims()
+	if len(M) != k {
+		return nil, errors.New("number of M values does not match number of LD score columns")
+	}
+	if opts.TwoStep > 0 && k > 1 {
+		return nil, errors.New("two-step estimator is not compatible with partitioned LD scores")
+	}
+	constrain := opts.Intercept != nil
+	intercept := null
+	if constrain {
+		if opts.TwoStep > 0 {
+			return nil, errors.New("two-step estimator is not compatible with a constrained intercept")
+		}
+		intercept = *opts.Intercept
+	}
+	mTot := floats.Sum(M)
+	xTot := make([]float64, n)
+	for i := 0; i < n; i++ {
+		xTot[i] = floats.Sum(x.RawRowView(i))
+	}
+	totAgg := aggregate(y, xTot, N, mTot, intercept)
+	initialW := weights(xTot, nil, totAgg, intercept)
+	nbar := floats.Sum(N) / float64(n)
+
+	// With a free intercept the design matrix gets a column of ones, with a
+	// fixed one it is subtracted from y instead.
+	p := k + 1
+	yp := y
+	if constrain {
+		p = k
+		yp = make([]float64, n)
+		for i := range y {
+			yp[i] = y[i] - intercept
+		}
+	}
+	xs := mat.NewDense(n, p, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < k; j++ {
+			xs.Set(i, j, x.At(i, j)*N[i]/nbar)
+		}
+		if !constrain {
+			xs.Set(i, k, 1)
+		}
+	}
+
+	r := &regression{nbar: nbar, constrain: constrain}
+	var jknife *Jackknife
+	var err error
+	switch {
+	case opts.TwoStep > 0:
+		idx := []int{}
+		for i, keep := range step1 {
+			if keep {
+				idx = append(idx, i)
+			}
+		}
+		n1 := len(idx)
+		r.twostepFiltered = n - n1
+		x1 := mat.NewDense(n1, k+1, nil)
+		y1 := make([]float64, n1)
+		initialW1 := make([]float64, n1)
+		for r, i := range idx {
+			x1.SetRow(r, xs.RawRowView(i))
+			y1[r], initialW1[r] = y[i], initialW[i]
+		}
+		ld1 := mat.Col(nil, 0, x1)
+		update1 := func(coef []float64) ([]float64, error) {
+			return weights(ld1, idx, mTot*coef[0]/nbar, coef[k]), nil
+		}
+		step1Fit, err := IRWLS(x1, y1, update1, opts.NBlocks, initialW1, nil)
+		if err != nil {
+			return nil, err
+		}
+		step1Int := step1Fit.Est[k]
+		yp := make([]float64, n)
+		for i := range y {
+			yp[i] = y[i] - step1Int
+		}
+		x2 := mat.NewDense(n, 1, mat.Col(nil, 0, xs))
+		update2 := func(coef []float64) ([]float64, error) {
+			return weights(xTot, nil, mTot*coef[0]/nbar, step1Int), nil
+		}
+		step2, err := IRWLS(x2, yp, update2, opts.NBlocks, initialW, updateSeparators(step1Fit.Separators, step1))
+		if err != nil {
+			return nil, err
+		}
+		num, den := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			num += initialW[i] * x2.At(i, 0)
+			den += initialW[i] * x2.At(i, 0) * x2.At(i, 0)
+		}
+		jknife = combineTwostep(step1Fit, step2, num/den)
+	case opts.OldWeights:
+		sw := make([]float64, n)
+		for i := range initialW {
+			sw[i] = math.Sqrt(initialW[i])
+		}
+		xw, err := Weight(xs, sw)
+		if err != nil {
+			return nil, err
+		}
+		yw, err := Weight(mat.NewDense(n, 1, append([]float64(nil), yp...)), sw)
+		if err != nil {
+			return nil, err
+		}
+		jknife, err = LstsqJackknifeFast(xw, mat.Col(nil, 0, yw), opts.NBlocks, nil)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		update := func(coef []float64) ([]float64, error) {
+			tot := 0.0
+			for j := 0; j < k; j++ {
+				tot += M[j] * coef[j] / nbar
+			}
+			if constrain {
+				return weights(xTot, nil, tot, intercept), nil
+			}
+			return weights(xTot, nil, tot, coef[k]), nil
+		}
+		jknife, err = IRWLS(xs, yp, update, opts.NBlocks, initialW, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.jknife = jknife
+
+	r.coef = make([]float64, k)
+	r.coefSE = make([]float64, k)
+	r.coefCov = mat.NewDense(k, k, nil)
+	r.cat = make([]float64, k)
+	r.catSE = make([]float64, k)
+	r.catCov = mat.NewDense(k, k, nil)
+	for i := 0; i < k; i++ {
+		r.coef[i] = jknife.Est[i] / nbar
+		r.cat[i] = M[i] * r.coef[i]
+		for j := 0; j < k; j++ {
+			r.coefCov.Set(i, j, jknife.JknifeCov.At(i, j)/(nbar*nbar))
+			r.catCov.Set(i, j, M[i]*M[j]*r.coefCov.At(i, j))
+		}
+		r.coefSE[i] = math.Sqrt(r.coefCov.At(i, i))
+		r.catSE[i] = math.Sqrt(r.catCov.At(i, i))
+	}
+	r.tot = floats.Sum(r.cat)
+	r.totSE = math.Sqrt(mat.Sum(r.catCov))
+	if constrain {
+		r.intercept = intercept
+		r.interceptSE = math.NaN()
+	} else {
+		r.intercept = jknife.Est[k]
+		r.interceptSE = jknife.JknifeSE[k]
+	}
+	return r, nil
+}
+
+// totDeleteValues returns the total h2 or genetic covariance of each
+// jackknife block.
+func (r *regression) totDeleteValues(M []float64) []float64 {
+	nBlocks, _ := r.jknife.DeleteValues.Dims()
+	tot := make([]float64, nBlocks)
+	for b := range tot {
+		for j := range M {
+			tot[b] += M[j] * r.jknife.DeleteValues.At(b, j) / r.nbar
+		}
+	}
+	return tot
+}
+
+func aggregate(y []float64, x []float64, N []float64, M float64, intercept float64) float64 {
+	num := M * (floats.Sum(y)/float64(len(y)) - intercept)
+	denom := 0.0
+	for i := range x {
+		denom += x[i] * N[i]
+	}
+	return num / (denom / float64(len(x)))
+}
+
+// updateSeparators maps block separators computed on the rows kept by mask
+// back onto the full set of rows.
+func updateSeparators(s []int, mask []bool) []int {
+	maplist := []int{}
+	for i, keep := range mask {
+		if keep {
+			maplist = append(maplist, i)
+		}
+	}
+	t := []int{0}
+	for _, v := range s[1 : len(s)-1] {
+		t = append(t, maplist[v])
+	}
+	return append(t, len(mask))
+}
+
+// combineTwostep merges the intercept from the first step with the slope
+// from the second, propagating the step one intercept uncertainty.
+func combineTwostep(step1 *Jackknife, step2 *Jackknife, c float64) *Jackknife {
+	nBlocks, p := step1.DeleteValues.Dims()
+	nAnnot := p - 1
+	step1Int := step1.Est[nAnnot]
+	est := append(append([]float64(nil), step2.Est...), step1Int)
+	deleteValues := mat.NewDense(nBlocks, p, nil)
+	for b := 0; b < nBlocks; b++ {
+		d1 := step1.DeleteValues.At(b, nAnnot)
+		deleteValues.Set(b, nAnnot, d1)
+		for j := 0; j < nAnnot; j++ {
+			deleteValues.Set(b, j, step2.DeleteValues.At(b, j)-c*(d1-step1Int))
+		}
+	}
+	return newJackknife(est, deleteValues, DeleteValuesToPseudovalues(deleteValues, est))
+}
+
+// subset returns the values of x at idx, or x itself if idx is nil.
+func subset(x []float64, idx []int) []float64 {
+	if idx == nil {
+		return x
+	}
+	s := make([]float64, len(idx))
+	for r, i := range idx {
+		s[r] = x[i]
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+package liftover
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// Block is an ungapped alignment of [TStart, TEnd) on the source chromosome
+// TName to QStart onwards on QName. Coordinates are 0-based and, for the
+// '-' strand, count from the end of QName.
+type Block struct {
+	TName   string
+	TStart  int
+	TEnd    int
+	QName   string
+	QStart  int
+	QSize   int
+	QStrand byte
+}
+
+// Hit is a lifted position. Pos is 0-based on the forward strand of Chr.
+type Hit struct {
+	Chr    string
+	Pos    int
+	Strand byte
+}
+
+// Chain holds the blocks of a UCSC chain file indexed by source chromosome.
+// Chromosome names are normalised with parse.NormalizeCHR.
+type Chain struct {
+	blocks []Block
+	trees  map[string]*IntervalTree
+}
+
+// ReadChain parses a UCSC chain file, plain or compressed.
+func ReadChain(file string) (*Chain, error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Chain{trees: map[string]*IntervalTree{}}
+	scanner := bufio.NewScanner(f)
+	var header *Block
+	var t, q int
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "chain" {
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("%s line %d: malformed chain header", file, line)
+			}
+			// chain score tName tSize tStrand tStart tEnd qName qSize qStrand qStart qEnd id
+			v, err := atois(fields[5], fields[8], fields[10])
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", file, line, err)
+			}
+			header = &Block{
+				TName:   parse.NormalizeCHR(fields[2]),
+				QName:   parse.NormalizeCHR(fields[7]),
+				QSize:   v[1],
+				QStrand: fields[9][0],
+			}
+			t, q = v[0], v[2]
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("%s line %d: alignment data before a chain header", file, line)
+		}
+		v, err := atois(fields...)
+		if err != nil || (len(v) != 1 && len(v) != 3) {
+			return nil, fmt.Errorf("%s line %d: malformed alignment data", file, line)
+		}
+		b := *header
+		b.TStart, b.TEnd, b.QStart = t, t+v[0], q
+		c.blocks = append(c.blocks, b)
+		if len(v) == 3 {
+			t += v[0] + v[1]
+			q += v[0] + v[2]
+		} else {
+			header = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	intervals := map[string][]Interval{}
+	for i, b := range c.blocks {
+		intervals[b.TName] = append(intervals[b.TName], Interval{Start: b.TStart, End: b.TEnd, Value: i})
+	}
+	for chr, ivs := range intervals {
+		c.trees[chr] = NewIntervalTree(ivs)
+	}
+	return c, nil
+}
+
+func atois(s ...string) ([]int, error) {
+	v := make([]int, len(s))
+	for i := range s {
+		var err error
+		if v[i], err = strconv.Atoi(s[i]); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Lift returns every position the 0-based pos on chr maps to.
+func (c *Chain) Lift(chr string, pos int) (hits []Hit) {
+	tree, ok := c.trees[parse.NormalizeCHR(chr)]
+	if !ok {
+		return
+	}
+	for _, iv := range tree.Query(pos) {
+		b := c.blocks[iv.Value]
+		qpos := b.QStart + pos - b.TStart
+		if b.QStrand == '-' {
+			qpos = b.QSize - qpos - 1
+		}
+		hits = append(hits, Hit{Chr: b.QName, Pos: qpos, Strand: b.QStrand})
+	}
+	return
+}
+
+func (c *Chain) NumBlocks() int {
+	return len(c.blocks)
+}
@@ -0,0 +1,73 @@
+package liftover
+
+import "sort"
+
+// Interval is the half-open range [Start, End) carrying an index into the
+// caller's data.
+type Interval struct {
+	Start int
+	End   int
+	Value int
+}
+
+// IntervalTree is a static augmented interval tree: the intervals sorted by
+// start form an implicit balanced tree in which each node records the
+// largest end below it.
+type IntervalTree struct {
+	intervals []Interval
+	maxEnd    []int
+}
+
+func NewIntervalTree(intervals []Interval) *IntervalTree {
+	t := &IntervalTree{
+		intervals: append([]Interval(nil), intervals...),
+		maxEnd:    make([]int, len(intervals)),
+	}
+	sort.Slice(t.intervals, func(i, j int) bool { return t.intervals[i].Start < t.intervals[j].Start })
+	t.build(0, len(t.intervals))
+	return t
+}
+
+func (t *IntervalTree) build(lo int, hi int) int {
+	if lo >= hi {
+		return -1 << 62
+	}
+	mid := (lo + hi) / 2
+	end := t.intervals[mid].End
+	if l := t.build(lo, mid); l > end {
+		end = l
+	}
+	if r := t.build(mid+1, hi); r > end {
+		end = r
+	}
+	t.maxEnd[mid] = end
+	return end
+}
+
+// Query returns the intervals containing pos.
+func (t *IntervalTree) Query(pos int) (hits []Interval) {
+	t.query(0, len(t.intervals), pos, &hits)
+	return
+}
+
+func (t *IntervalTree) query(lo int, hi int, pos int, hits *[]Interval) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	if t.maxEnd[mid] <= pos {
+		return
+	}
+	t.query(lo, mid, pos, hits)
+	if t.intervals[mid].Start > pos {
+		return
+	}
+	if pos < t.intervals[mid].End {
+		*hits = append(*hits, t.intervals[mid])
+	}
+	t.query(mid+1, hi, pos, hits)
+}
+
+func (t *IntervalTree) Len() int {
+	return len(t.intervals)
+}
@@ -0,0 +1,137 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// SNPPosition is the chromosome and 1-based position of a SNP.
+type SNPPosition struct {
+	Chr string
+	Pos int
+}
+
+// BuildMatch counts the sampled SNPs found in the position table of a build
+// and those found at the same position.
+type BuildMatch struct {
+	Build   string
+	Found   int
+	Matched int
+}
+
+func (m BuildMatch) Rate() float64 {
+	if m.Found == 0 {
+		return 0
+	}
+	return float64(m.Matched) / float64(m.Found)
+}
+
+// positionCnames finds the SNP, CHR and BP columns of sumstats and position
+// tables.
+func positionCnames() map[string]string {
+	cnames := map[string]string{"ID": "SNP"}
+	for key, value := range constants.Default_cnames {
+		if value == "SNP" {
+			cnames[key] = value
+		}
+	}
+	for key, value := range constants.Extended_cnames {
+		if value == "CHR" || value == "BP" {
+			cnames[key] = value
+		}
+	}
+	return cnames
+}
+
+// scanPositions calls fn with the SNP, CHR and BP of each row of the
+// whitespace delimited file.
+func scanPositions(file string, fn func(snp string, pos SNPPosition)) error {
+	f, err := parse.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		return fmt.Errorf("%s is empty", file)
+	}
+	cnames := positionCnames()
+	cols := map[string]int{}
+	for i, h := range strings.Fields(scanner.Text()) {
+		if name, ok := cnames[parse.CleanName(h)]; ok {
+			cols[name] = i
+		}
+	}
+	for _, name := range []string{"SNP", "CHR", "BP"} {
+		if _, ok := cols[name]; !ok {
+			return fmt.Errorf("%s has no %s column", file, name)
+		}
+	}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= cols["SNP"] || len(fields) <= cols["CHR"] || len(fields) <= cols["BP"] {
+			continue
+		}
+		pos, err := strconv.ParseFloat(fields[cols["BP"]], 64)
+		if err != nil {
+			continue
+		}
+		fn(fields[cols["SNP"]], SNPPosition{Chr: parse.NormalizeCHR(fields[cols["CHR"]]), Pos: int(pos)})
+	}
+	return scanner.Err()
+}
+
+// SampleSNPPositions reservoir samples up to n rsIDs with their positions
+// from file.
+func SampleSNPPositions(file string, n int, seed int64) (map[string]SNPPosition, error) {
+	rng := rand.New(rand.NewSource(seed))
+	snps := make([]string, 0, n)
+	positions := make([]SNPPosition, 0, n)
+	seen := 0
+	err := scanPositions(file, func(snp string, pos SNPPosition) {
+		if !strings.HasPrefix(strings.ToLower(snp), "rs") {
+			return
+		}
+		seen++
+		if len(snps) < n {
+			snps = append(snps, snp)
+			positions = append(positions, pos)
+		} else if j := rng.Intn(seen); j < n {
+			snps[j], positions[j] = snp, pos
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sample := make(map[string]SNPPosition, len(snps))
+	for i, snp := range snps {
+		sample[snp] = positions[i]
+	}
+	return sample, nil
+}
+
+// MatchBuild compares sample against the position table of a build. Each
+// sampled rsID is counted once, and matches if any of its rows in the table
+// has its position.
+func MatchBuild(build string, file string, sample map[string]SNPPosition) (BuildMatch, error) {
+	found := map[string]bool{}
+	matched := map[string]bool{}
+	err := scanPositions(file, func(snp string, pos SNPPosition) {
+		want, ok := sample[snp]
+		if !ok {
+			return
+		}
+		found[snp] = true
+		if want == pos {
+			matched[snp] = true
+		}
+	})
+	return BuildMatch{Build: build, Found: len(found), Matched: len(matched)}, err
+}
@@ -0,0 +1,87 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSampleSNPPositions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sumstats.tsv")
+	data := "SNP\tCHR\tBP\tP\n" +
+		"rs1\tchr1\t100\t0.1\n" +
+		"1:200\t1\t200\t0.1\n" +
+		"RS2\t2\t300\t0.1\n" +
+		"rs3\tX\t400\t0.1\n" +
+		"rs4\t1\tNA\t0.1\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sample, err := SampleSNPPositions(file, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IDs that are not rsIDs and rows without a position are skipped
+	want := map[string]SNPPosition{"rs1": {"1", 100}, "RS2": {"2", 300}, "rs3": {"X", 400}}
+	if len(sample) != len(want) {
+		t.Errorf("sampled %v, want %v", sample, want)
+	}
+	for snp, pos := range want {
+		if sample[snp] != pos {
+			t.Errorf("%s at %v, want %v", snp, sample[snp], pos)
+		}
+	}
+
+	for seed := int64(0); seed < 5; seed++ {
+		sample, err := SampleSNPPositions(file, 2, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sample) != 2 {
+			t.Errorf("seed %d: sampled %d SNPs, want 2", seed, len(sample))
+		}
+		for snp, pos := range sample {
+			if want[snp] != pos {
+				t.Errorf("seed %d: sampled %s at %v", seed, snp, pos)
+			}
+		}
+	}
+
+	if err := os.WriteFile(file, []byte("SNP\tP\nrs1\t0.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SampleSNPPositions(file, 10, 1); err == nil {
+		t.Error("no error for a file without CHR and BP")
+	}
+}
+
+func TestMatchBuild(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "b37.txt")
+	// rs1 is listed twice, once at its position, and rs2 three times at
+	// another position
+	data := "ID CHROM POS\n" +
+		"rs1 1 100\n" +
+		"rs1 1 150\n" +
+		"rs2 2 999\n" +
+		"rs2 2 999\n" +
+		"rs2 2 998\n" +
+		"rs3 X 400\n" +
+		"rs9 1 5\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sample := map[string]SNPPosition{"rs1": {"1", 100}, "rs2": {"2", 300}, "rs3": {"X", 400}, "rs4": {"3", 1}}
+	m, err := MatchBuild("b37", file, sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != (BuildMatch{Build: "b37", Found: 3, Matched: 2}) {
+		t.Errorf("MatchBuild = %+v, want 3 found and 2 matched", m)
+	}
+	if r := m.Rate(); r != 2.0/3 {
+		t.Errorf("rate %g, want 2/3", r)
+	}
+	if r := (BuildMatch{}).Rate(); r != 0 {
+		t.Errorf("rate with none found %g, want 0", r)
+	}
+}
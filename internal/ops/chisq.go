@@ -0,0 +1,217 @@
+package ops
+
+import (
+	"math"
+	"sort"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Chi^2 of genome-wide significance, P = 5e-8, as counted by LDSC.
+const gws_chi2 = 29.0
+
+// ChiSquareStats are the inflation diagnostics of the Z column, as printed by
+// LDSC after munging.
+type ChiSquareStats struct {
+	CHR           string  `json:"chr,omitempty"`
+	SNPs          int64   `json:"snps"`
+	MeanChi2      float64 `json:"mean_chi2"`
+	LambdaGC      float64 `json:"lambda_gc"`
+	MaxChi2       float64 `json:"max_chi2"`
+	GenomeWideSig int64   `json:"genome_wide_significant"`
+	sum           float64
+	median        medianSketch
+}
+
+func (s *ChiSquareStats) add(z float64) {
+	if math.IsNaN(z) {
+		return
+	}
+	chi2 := z * z
+	s.SNPs++
+	s.sum += chi2
+	s.MaxChi2 = math.Max(s.MaxChi2, chi2)
+	if chi2 > gws_chi2 {
+		s.GenomeWideSig++
+	}
+	s.median.add(chi2)
+}
+
+func (s *ChiSquareStats) finish() {
+	s.MeanChi2 = s.sum / float64(s.SNPs)
+	s.LambdaGC = s.median.value() / distuv.ChiSquared{K: 1}.Quantile(0.5)
+}
+
+// ChiSquare computes the chi^2 diagnostics of the Z column of table in one
+// pass, overall and, if table has a CHR column, for each chromosome in the
+// order of constants.Chromosomes. ok is false without a Z column.
+func ChiSquare(table array.Table) (all ChiSquareStats, chrs []ChiSquareStats, ok bool) {
+	zi := ColumnIndex(table, "Z")
+	if zi < 0 {
+		return all, nil, false
+	}
+	ci := ColumnIndex(table, "CHR")
+	by_chr := map[string]*ChiSquareStats{}
+
+	tr := array.NewTableReader(table, 10000)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.Record()
+		z := array.NewFloat64Data(rec.Column(zi).Data())
+		var chr []string
+		if ci >= 0 {
+			chr = chunkStrings(rec.Column(ci))
+		}
+		for i := 0; i < z.Len(); i++ {
+			if z.IsNull(i) {
+				continue
+			}
+			all.add(z.Value(i))
+			if chr != nil {
+				c := parse.NormalizeCHR(chr[i])
+				s, seen := by_chr[c]
+				if !seen {
+					s = &ChiSquareStats{CHR: c}
+					by_chr[c] = s
+				}
+				s.add(z.Value(i))
+			}
+		}
+		z.Release()
+	}
+	all.finish()
+
+	rank := map[string]int{}
+	for i, c := range constants.Chromosomes {
+		rank[c] = i + 1
+	}
+	for _, s := range by_chr {
+		s.finish()
+		chrs = append(chrs, *s)
+	}
+	sort.Slice(chrs, func(i, j int) bool {
+		ri, rj := rank[chrs[i].CHR], rank[chrs[j].CHR]
+		if ri == 0 || rj == 0 {
+			// unknown chromosomes go last, by name
+			if ri != rj {
+				return rj == 0
+			}
+			return chrs[i].CHR < chrs[j].CHR
+		}
+		return ri < rj
+	})
+	return all, chrs, true
+}
+
+// chunkStrings returns the values of a string or float64 array as strings,
+// reading nulls as "".
+func chunkStrings(arr array.Interface) []string {
+	values := make([]string, arr.Len())
+	switch arr.DataType().ID() {
+	case arrow.STRING:
+		d := array.NewStringData(arr.Data())
+		for i := range values {
+			values[i] = d.Value(i)
+		}
+		d.Release()
+	case arrow.FLOAT64:
+		d := array.NewFloat64Data(arr.Data())
+		for i := range values {
+			if !d.IsNull(i) {
+				values[i] = FormatFloat(d.Value(i))
+			}
+		}
+		d.Release()
+	}
+	return values
+}
+
+// medianSketch estimates the median of a stream in constant memory with the
+// P^2 algorithm of Jain and Chlamtac (1985), which moves five markers
+// towards the minimum, quartiles, median and maximum as values arrive.
+type medianSketch struct {
+	n       int
+	q       [5]float64
+	pos     [5]float64
+	desired [5]float64
+}
+
+var p2_increments = [5]float64{0, 0.25, 0.5, 0.75, 1}
+
+func (m *medianSketch) add(x float64) {
+	if m.n < 5 {
+		m.q[m.n] = x
+		m.n++
+		if m.n == 5 {
+			sort.Float64s(m.q[:])
+			for i := range m.pos {
+				m.pos[i] = float64(i + 1)
+				m.desired[i] = 1 + 4*p2_increments[i]
+			}
+		}
+		return
+	}
+	m.n++
+
+	var k int
+	switch {
+	case x < m.q[0]:
+		m.q[0] = x
+		k = 0
+	case x >= m.q[4]:
+		m.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= m.q[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		m.pos[i]++
+	}
+	for i := range m.desired {
+		m.desired[i] += p2_increments[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := m.desired[i] - m.pos[i]
+		if (d >= 1 && m.pos[i+1]-m.pos[i] > 1) || (d <= -1 && m.pos[i-1]-m.pos[i] < -1) {
+			step := math.Copysign(1, d)
+			q := m.parabolic(i, step)
+			if m.q[i-1] < q && q < m.q[i+1] {
+				m.q[i] = q
+			} else {
+				j := i + int(step)
+				m.q[i] += step * (m.q[j] - m.q[i]) / (m.pos[j] - m.pos[i])
+			}
+			m.pos[i] += step
+		}
+	}
+}
+
+func (m *medianSketch) parabolic(i int, d float64) float64 {
+	return m.q[i] + d/(m.pos[i+1]-m.pos[i-1])*
+		((m.pos[i]-m.pos[i-1]+d)*(m.q[i+1]-m.q[i])/(m.pos[i+1]-m.pos[i])+
+			(m.pos[i+1]-m.pos[i]-d)*(m.q[i]-m.q[i-1])/(m.pos[i]-m.pos[i-1]))
+}
+
+// value returns the estimated median, exact for fewer than five values, or
+// NaN for none.
+func (m *medianSketch) value() float64 {
+	if m.n >= 5 {
+		return m.q[2]
+	}
+	if m.n == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), m.q[:m.n]...)
+	sort.Float64s(sorted)
+	mid := m.n / 2
+	if m.n%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
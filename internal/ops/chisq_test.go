@@ -0,0 +1,83 @@
+package ops
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// median_chi2 is the median of chi^2 with one degree of freedom, which
+// lambda GC is relative to.
+const median_chi2 = 0.454936423119572
+
+func TestChiSquare(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "z.tsv")
+	data := "CHR\tZ\n1\t1\n2\t-2\n10\t3\n2\tNA\n1\t6\nX\t-0.5\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctypes := map[string]arrow.DataType{"CHR": arrow.BinaryTypes.String, "Z": arrow.PrimitiveTypes.Float64}
+	table, _ := ArrowCSV(file, []string{"CHR", "Z"}, '\t', ctypes)
+
+	all, chrs, ok := ChiSquare(table)
+	if !ok {
+		t.Fatal("no Z column found")
+	}
+	// LDSC: mean(Z^2), median(Z^2) / 0.4549 and sum(Z^2 > 29)
+	check := func(got ChiSquareStats, want ChiSquareStats) {
+		t.Helper()
+		if got.CHR != want.CHR || got.SNPs != want.SNPs || got.GenomeWideSig != want.GenomeWideSig ||
+			math.Abs(got.MeanChi2-want.MeanChi2) > 1e-12 || math.Abs(got.LambdaGC-want.LambdaGC) > 1e-12 ||
+			got.MaxChi2 != want.MaxChi2 {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	check(all, ChiSquareStats{SNPs: 5, MeanChi2: 10.05, LambdaGC: 4 / median_chi2, MaxChi2: 36, GenomeWideSig: 1})
+	if len(chrs) != 4 {
+		t.Fatalf("got %d chromosomes, want 4", len(chrs))
+	}
+	check(chrs[0], ChiSquareStats{CHR: "1", SNPs: 2, MeanChi2: 18.5, LambdaGC: 18.5 / median_chi2, MaxChi2: 36, GenomeWideSig: 1})
+	check(chrs[1], ChiSquareStats{CHR: "2", SNPs: 1, MeanChi2: 4, LambdaGC: 4 / median_chi2, MaxChi2: 4})
+	check(chrs[2], ChiSquareStats{CHR: "10", SNPs: 1, MeanChi2: 9, LambdaGC: 9 / median_chi2, MaxChi2: 9})
+	check(chrs[3], ChiSquareStats{CHR: "X", SNPs: 1, MeanChi2: 0.25, LambdaGC: 0.25 / median_chi2, MaxChi2: 0.25})
+}
+
+func TestMedianSketch(t *testing.T) {
+	for n, want := range map[int]float64{1: 3, 2: 2, 3: 3, 4: 2.5} {
+		var m medianSketch
+		for _, x := range []float64{3, 1, 4, 2}[:n] {
+			m.add(x)
+		}
+		if got := m.value(); got != want {
+			t.Errorf("median of %d values is %g, want %g", n, got, want)
+		}
+	}
+	if got := (&medianSketch{}).value(); !math.IsNaN(got) {
+		t.Errorf("median of no values is %g, want NaN", got)
+	}
+
+	// chi^2 of null Z in a scrambled order, whose median gives lambda GC 1
+	const n = 200001
+	chi2 := make([]float64, n)
+	for i := range chi2 {
+		z := distuv.UnitNormal.Quantile((float64(i*7919%n) + 0.5) / n)
+		chi2[i] = z * z
+	}
+	var m medianSketch
+	for _, x := range chi2 {
+		m.add(x)
+	}
+	sort.Float64s(chi2)
+	exact := chi2[n/2]
+	if got := m.value(); math.Abs(got-exact) > 1e-3*exact {
+		t.Errorf("median sketch gives %g, exact median is %g", got, exact)
+	}
+	if lambda := exact / median_chi2; math.Abs(lambda-1) > 1e-4 {
+		t.Errorf("exact lambda GC of null Z is %g, want 1", lambda)
+	}
+}
@@ -0,0 +1,192 @@
+package ops
+
+import (
+	"math"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// ColumnIndex returns the index of the named column in table, or -1.
+func ColumnIndex(table array.Table, name string) int {
+	for i := 0; i < int(table.NumCols()); i++ {
+		if table.Column(i).Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Float64Values flattens a float64 column, reading nulls as NaN.
+func Float64Values(col *array.Column) []float64 {
+	values := make([]float64, 0, col.Len())
+	for _, c := range col.Data().Chunks() {
+		d := array.NewFloat64Data(c.Data())
+		for i, v := range d.Float64Values() {
+			if d.IsNull(i) {
+				v = math.NaN()
+			}
+			values = append(values, v)
+		}
+		d.Release()
+	}
+	return values
+}
+
+// StringValues flattens a string column, reading nulls as "".
+func StringValues(col *array.Column) []string {
+	values := make([]string, 0, col.Len())
+	for _, c := range col.Data().Chunks() {
+		d := array.NewStringData(c.Data())
+		for i := 0; i < d.Len(); i++ {
+			values = append(values, d.Value(i))
+		}
+		d.Release()
+	}
+	return values
+}
+
+// FilterRows returns the rows of table where keep is true.
+func FilterRows(table array.Table, keep []bool) (array.Table, error) {
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	records := make([]array.Record, 0)
+	tr := array.NewTableReader(table, 200)
+	defer tr.Release()
+	offset := 0
+	for tr.Next() {
+		rec := tr.Record()
+		nrows := int(rec.NumRows())
+		new_rec, err := filterRecord(rec, keep[offset:offset+nrows], mem)
+		if err != nil {
+			return nil, err
+		}
+		offset += nrows
+		if new_rec != nil {
+			records = append(records, new_rec)
+		}
+	}
+	return array.NewTableFromRecords(table.Schema(), records), nil
+}
+
+// filterRecord returns the rows of rec where keep is true, or nil if there
+// are none.
+func filterRecord(rec array.Record, keep []bool, mem memory.Allocator) (array.Record, error) {
+	nrows := int(rec.NumRows())
+	drop_idxs := []int{}
+	for i := 0; i < nrows; i++ {
+		if !keep[i] {
+			drop_idxs = append(drop_idxs, i)
+		}
+	}
+	if len(drop_idxs) == 0 {
+		rec.Retain()
+		return rec, nil
+	}
+	if len(drop_idxs) == nrows {
+		return nil, nil
+	}
+	slice_idxs := utils.Slices(nrows, drop_idxs)
+	cols := make([]array.Interface, 0, rec.NumCols())
+	for _, col := range rec.Columns() {
+		parts := make([]array.Interface, 0, len(slice_idxs))
+		for _, idx := range slice_idxs {
+			if idx[1] > idx[0] {
+				parts = append(parts, array.NewSlice(col, int64(idx[0]), int64(idx[1])))
+			}
+		}
+		new_col, err := array.Concatenate(parts, mem)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, new_col)
+	}
+	return array.NewRecord(rec.Schema(), cols, int64(cols[0].Len())), nil
+}
+
+// SetStringColumn replaces the named column of table with values, or appends
+// it if table has no such column.
+func SetStringColumn(table array.Table, name string, values []string) array.Table {
+	field := arrow.Field{Name: name, Type: arrow.BinaryTypes.String, Nullable: true}
+	return setColumn(table, field, func(mem memory.Allocator, lo int, hi int) array.Interface {
+		b := array.NewStringBuilder(mem)
+		defer b.Release()
+		b.AppendValues(values[lo:hi], nil)
+		return b.NewArray()
+	})
+}
+
+// SetFloat64Column is SetStringColumn for a float64 column. NaN values are
+// stored as nulls, the inverse of Float64Values.
+func SetFloat64Column(table array.Table, name string, values []float64) array.Table {
+	field := arrow.Field{Name: name, Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+	return setColumn(table, field, func(mem memory.Allocator, lo int, hi int) array.Interface {
+		b := array.NewFloat64Builder(mem)
+		defer b.Release()
+		for _, v := range values[lo:hi] {
+			if math.IsNaN(v) {
+				b.AppendNull()
+			} else {
+				b.Append(v)
+			}
+		}
+		return b.NewArray()
+	})
+}
+
+func setColumn(table array.Table, field arrow.Field, build func(mem memory.Allocator, lo int, hi int) array.Interface) array.Table {
+	idx := ColumnIndex(table, field.Name)
+	fields := append([]arrow.Field{}, table.Schema().Fields()...)
+	if idx < 0 {
+		idx = len(fields)
+		fields = append(fields, field)
+	} else {
+		fields[idx] = field
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	records := make([]array.Record, 0)
+	tr := array.NewTableReader(table, 200)
+	defer tr.Release()
+	offset := 0
+	for tr.Next() {
+		rec := tr.Record()
+		nrows := int(rec.NumRows())
+		cols := append([]array.Interface{}, rec.Columns()...)
+		col := build(mem, offset, offset+nrows)
+		if idx < len(cols) {
+			cols[idx] = col
+		} else {
+			cols = append(cols, col)
+		}
+		offset += nrows
+		records = append(records, array.NewRecord(schema, cols, rec.NumRows()))
+	}
+	return array.NewTableFromRecords(schema, records)
+}
+
+// DropColumns returns table without the named columns.
+func DropColumns(table array.Table, names []string) array.Table {
+	records, schema := selectColumns(table, func(name string) bool {
+		return !utils.InList(name, names)
+	})
+	return array.NewTableFromRecords(schema, records)
+}
+
+// DropUnkeptColumns drops the columns of constants.Written_by_keep_cols
+// that are not in keep, the canonical columns named with --keep-cols, or in
+// derived.
+func DropUnkeptColumns(table array.Table, keep []string, derived []string) array.Table {
+	drop := []string{}
+	for _, name := range constants.Written_by_keep_cols {
+		for _, col := range constants.Keep_cols[name] {
+			if !utils.InList(col, keep) && !utils.InList(col, derived) {
+				drop = append(drop, col)
+			}
+		}
+	}
+	return DropColumns(table, drop)
+}
@@ -0,0 +1,30 @@
+package ops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDropUnkeptColumns(t *testing.T) {
+	table := readTestTSV(t, "SNP\tZ\tP\tBETA\tOR\tSE\tFRQ\tINFO\nrs1\t1\t0.3\t0.1\t1.1\t0.1\t0.3\t0.95\n")
+	for _, c := range []struct {
+		keep    []string
+		derived []string
+		want    []string
+	}{
+		{nil, nil, []string{"SNP", "Z", "P", "SE"}},
+		{[]string{"BETA", "OR", "LOG_ODDS"}, nil, []string{"SNP", "Z", "P", "BETA", "OR", "SE"}},
+		{[]string{"FRQ", "INFO"}, nil, []string{"SNP", "Z", "P", "SE", "FRQ", "INFO"}},
+		// BETA derived with --derive-beta-se is written
+		{nil, []string{"BETA", "SE"}, []string{"SNP", "Z", "P", "BETA", "SE"}},
+	} {
+		got := DropUnkeptColumns(table, c.keep, c.derived)
+		var names []string
+		for _, f := range got.Schema().Fields() {
+			names = append(names, f.Name)
+		}
+		if !reflect.DeepEqual(names, c.want) {
+			t.Errorf("keep %v, derived %v: columns %v, want %v", c.keep, c.derived, names, c.want)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package ops
 
 import (
+	"fmt"
 	"log"
-	"os"
+	"math"
+	"runtime"
+	"sort"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/apache/arrow/go/arrow"
@@ -17,7 +20,44 @@ import (
 	"gonum.org/v1/gonum/floats"
 )
 
+// WorkerPool runs job(0) ... job(n-1) on a pool of workers goroutines, or one
+// per CPU if workers < 1, and returns the first error in job order.
+func WorkerPool(n int, workers int, job func(i int) error) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = job(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ArrowCSV(file string, header []string, delimiter rune, ctypes map[string]arrow.DataType) (table array.Table, schema *arrow.Schema) {
+	return ArrowCSVSelect(file, header, delimiter, ctypes, nil)
+}
+
+// ArrowCSVSelect is ArrowCSV keeping only the rows picked by sel, if it is
+// not nil, as each chunk is read.
+func ArrowCSVSelect(file string, header []string, delimiter rune, ctypes map[string]arrow.DataType, sel *RowSelect) (table array.Table, schema *arrow.Schema) {
 	defer utils.TimeTrack(time.Now(), "ArrowCSV")
 
 	fields := make([]arrow.Field, 0)
@@ -26,8 +66,12 @@ func ArrowCSV(file string, header []string, delimiter rune, ctypes map[string]ar
 		fields = append(fields, field)
 	}
 	schema = arrow.NewSchema(fields, nil)
+	out := schema
+	if sel != nil {
+		out = sel.schema(schema)
+	}
 
-	rFile, err := os.Open(file) //3 columns
+	rFile, err := parse.Open(file)
 	if err != nil {
 		log.Println("Error:", err)
 		return
@@ -42,394 +86,271 @@ func ArrowCSV(file string, header []string, delimiter rune, ctypes map[string]ar
 		csv.WithHeader(true),
 		csv.WithAllocator(mem),
 		csv.WithChunk(200),
-		csv.WithComma('\t'),
+		csv.WithComma(delimiter),
+		csv.WithNullReader(true, constants.Null_strings...),
 	)
 	defer r.Release()
 
 	records := make([]array.Record, 0)
 	for r.Next() {
+		// the reader names fields after the raw file header, so rebuild each
+		// record with the schema of the cleaned names
 		rec := r.Record()
-		rec.Retain()
+		rec = array.NewRecord(schema, rec.Columns(), rec.NumRows())
+		if sel != nil {
+			kept, err := sel.apply(rec, out, mem)
+			rec.Release()
+			if err != nil {
+				log.Println("Error:", err)
+				return nil, out
+			}
+			if kept == nil {
+				continue
+			}
+			rec = kept
+		}
 		records = append(records, rec)
 	}
+	if r.Err() != nil {
+		log.Println("Error:", r.Err())
+	}
 	log.Println("Finished Reading.")
 
-	table = array.NewTableFromRecords(schema, records)
-	return
+	table = array.NewTableFromRecords(out, records)
+	return table, out
 }
 
-func ParseDataframe(table array.Table, schema *arrow.Schema, cnames map[string]string) (new_table array.Table, new_schema *arrow.Schema) {
-	defer utils.TimeTrack(time.Now(), "ParseDataframe")
-	log.Println("Parsing dataframe.")
+// WriteTSV writes table as a tab separated file with a header, gzip
+// compressed if file ends in .gz. Nulls are written as NA and whole numbers
+// such as BP and N without an exponent.
+func WriteTSV(table array.Table, file string) error {
+	defer utils.TimeTrack(time.Now(), "WriteTSV")
 
+	f, err := parse.Create(file)
+	if err != nil {
+		return err
+	}
 	fields := make([]arrow.Field, 0)
-	for _, c := range schema.Fields() {
-		if utils.InList(c.Name, utils.GetKeys(cnames)) {
-			switch c.Type.ID().String() {
-			case "FLOAT64":
-				c = arrow.Field{Name: cnames[c.Name], Type: arrow.PrimitiveTypes.Float64, Nullable: true, Metadata: arrow.Metadata{}}
-				break
-			case "STRING":
-				c = arrow.Field{Name: cnames[c.Name], Type: arrow.BinaryTypes.String, Nullable: true, Metadata: arrow.Metadata{}}
-				break
-			}
-			fields = append(fields, c)
+	for _, field := range table.Schema().Fields() {
+		if field.Type.ID() == arrow.FLOAT64 {
+			field = arrow.Field{Name: field.Name, Type: arrow.BinaryTypes.String, Nullable: true, Metadata: field.Metadata}
 		}
+		fields = append(fields, field)
 	}
-	new_schema = arrow.NewSchema(fields, nil)
-	if new_schema.Equal(schema) {
-		log.Println("Schema is the same.")
-		new_schema = schema
-	}
+	schema := arrow.NewSchema(fields, nil)
 
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	w := csv.NewWriter(f, schema, csv.WithComma('\t'), csv.WithHeader(true), csv.WithNullWriter("NA"))
 	tr := array.NewTableReader(table, 200)
-	dropped := map[string]int{}
-	records := make([]array.Record, 0)
+	defer tr.Release()
 	for tr.Next() {
 		rec := tr.Record()
-		rec.Retain()
-		drop_idxs := []int{}
-		for i, col := range rec.Columns() {
-			colname := rec.ColumnName(i)
-			if utils.InList(colname, constants.Numeric_cols) {
-				d := array.NewFloat64Data(col.Data())
-				for i, v := range d.Float64Values() {
-					switch colname {
-					case "P":
-						if parse.FilterP(v) {
-							dropped["P"]++
-							drop_idxs = append(drop_idxs, i)
-						}
-						break
-					case "FRQ":
-						if parse.FilterFRQ(v, 0.05) {
-							dropped["FRQ"]++
-							drop_idxs = append(drop_idxs, i)
-						}
-						break
-					case "INFO":
-					case "INFO_LIST":
-						if parse.FilterINFO(v, 0.05) {
-							dropped["INFO"]++
-							drop_idxs = append(drop_idxs, i)
-						}
-						break
-					}
-				}
-			} else {
-				d := array.NewStringData(col.Data())
-				for i := 0; i < d.Len(); i++ {
-					switch colname {
-					case "A1":
-					case "A2":
-						if parse.FilterAllele(strings.ToUpper(d.Value(i))) {
-							dropped["A"]++
-							drop_idxs = append(drop_idxs, i)
-						}
-					}
-				}
-			}
-		}
-		if len(drop_idxs) > 0 {
-			slice_idxs := utils.Slices(int(rec.NumRows()), drop_idxs)
-			drop_idxs = []int{}
-			new_cols := make([]array.Interface, 0)
-			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
-			for i, col := range rec.Columns() {
-				if !utils.InList(rec.ColumnName(i), utils.GetKeys(cnames)) {
-					continue
-				}
-				new_data := make([]array.Interface, 0)
-				for _, idx := range slice_idxs {
-					ns := array.NewSliceData(col.Data(), int64(idx[0]), int64(idx[1]))
-					ns.Retain()
-					if col.DataType().ID() == arrow.FLOAT64 {
-						//log.Println(rec.ColumnName(i), " : ", col.DataType().ID().String())
-						new_data = append(new_data, array.NewFloat64Data(ns))
-					} else {
-						//log.Println(rec.ColumnName(i), " : ", col.DataType().ID().String())
-						new_data = append(new_data, array.NewStringData(ns))
-					}
-					ns.Release()
-				}
-				new_col, err := array.Concatenate(new_data, mem)
-				if err != nil {
-					log.Println("Error:", err)
-					return
-				}
-				new_col.Retain()
-				new_cols = append(new_cols, new_col)
-				new_col.Release()
+		cols := append([]array.Interface{}, rec.Columns()...)
+		for i, col := range cols {
+			if col.DataType().ID() != arrow.FLOAT64 {
+				continue
 			}
-			new_rec := array.NewRecord(new_schema, new_cols, int64(new_cols[0].Data().Len()))
-			new_rec.Retain()
-			records = append(records, new_rec)
-			new_rec.Release()
-		} else {
-			new_cols := make([]array.Interface, 0)
-			for i := range rec.Columns() {
-				if !utils.InList(rec.ColumnName(i), utils.GetKeys(cnames)) {
-					continue
+			d := array.NewFloat64Data(col.Data())
+			b := array.NewStringBuilder(mem)
+			for j := 0; j < d.Len(); j++ {
+				if d.IsNull(j) {
+					b.AppendNull()
+				} else {
+					b.Append(FormatFloat(d.Value(j)))
 				}
-				new_cols = append(new_cols, rec.Column(i))
 			}
-			new_rec := array.NewRecord(new_schema, new_cols, int64(new_cols[0].Data().Len()))
-			new_rec.Retain()
-			records = append(records, new_rec)
-			new_rec.Release()
+			cols[i] = b.NewArray()
+			b.Release()
+			d.Release()
+		}
+		if err := w.Write(array.NewRecord(schema, cols, rec.NumRows())); err != nil {
+			f.Close()
+			return err
 		}
-		rec.Release()
 	}
-	log.Println("Finished Parsing.")
-	log.Println("Dropped:", dropped)
-	new_table = array.NewTableFromRecords(new_schema, records)
-	return
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-func RemoveDuplicateSNPS(table array.Table, schema *arrow.Schema) (new_table array.Table) {
-	defer utils.TimeTrack(time.Now(), "RemoveDuplicateSNPS")
-	log.Println("Removing duplicate SNPs.")
-
-	tr := array.NewTableReader(table, 200)
+// FormatFloat formats v in the shortest form that parses back to v, without
+// an exponent if v is a whole number.
+func FormatFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
 
-	dropped := map[string]int{}
-	SNPS := map[string]bool{}
-	records := make([]array.Record, 0)
-	for tr.Next() {
-		rec := tr.Record()
-		rec.Retain()
-		drop_idxs := []int{}
-		for i, col := range rec.Columns() {
-			colname := rec.ColumnName(i)
-			if colname != "SNP" {
-				continue
-			}
-			d := array.NewStringData(col.Data())
-			for i := 0; i < d.Len(); i++ {
-				if SNPS[d.Value(i)] {
-					dropped["SNP"]++
-					drop_idxs = append(drop_idxs, i)
-				} else {
-					SNPS[d.Value(i)] = true
-				}
-			}
-		}
-		if len(drop_idxs) > 0 {
-			slice_idxs := utils.Slices(int(rec.NumRows()), drop_idxs)
-			drop_idxs = []int{}
-			new_cols := make([]array.Interface, 0)
-			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
-			for _, col := range rec.Columns() {
-				new_data := make([]array.Interface, 0)
-				for _, idx := range slice_idxs {
-					ns := array.NewSliceData(col.Data(), int64(idx[0]), int64(idx[1]))
-					ns.Retain()
-					if col.DataType().ID() == arrow.FLOAT64 {
-						//log.Println(rec.ColumnName(i), " : ", col.DataType().ID().String())
-						new_data = append(new_data, array.NewFloat64Data(ns))
-					} else {
-						//log.Println(rec.ColumnName(i), " : ", col.DataType().ID().String())
-						new_data = append(new_data, array.NewStringData(ns))
-					}
-					ns.Release()
-				}
-				new_col, err := array.Concatenate(new_data, mem)
-				if err != nil {
-					log.Println(new_data)
-					log.Println("Error:", err)
-					return
-				}
-				new_col.Retain()
-				new_cols = append(new_cols, new_col)
-				new_col.Release()
-			}
-			new_rec := array.NewRecord(schema, new_cols, int64(new_cols[0].Len()))
-			new_rec.Retain()
-			records = append(records, new_rec)
-			new_rec.Release()
-		} else {
-			records = append(records, rec)
+// ParseDataframe renames the columns of table in cnames to their canonical
+// names, drops the other columns, and drops rows that fail the filters of
+// pipeline. dropped counts the rows by the first filter they fail, which is
+// also the reason recorded in rejects.
+// RenameColumns keeps the columns of table named in cnames, renamed to
+// their canonical names.
+func RenameColumns(table array.Table, cnames map[string]string) array.Table {
+	fields := make([]arrow.Field, 0)
+	for _, f := range table.Schema().Fields() {
+		if cname, ok := cnames[f.Name]; ok {
+			fields = append(fields, arrow.Field{Name: cname, Type: f.Type, Nullable: true})
 		}
 	}
-	log.Println("Dropped", dropped["SNP"], "duplicate SNPS.")
-	new_table = array.NewTableFromRecords(schema, records)
-	return
+	schema := arrow.NewSchema(fields, nil)
+	records, _ := selectColumns(table, func(name string) bool {
+		_, ok := cnames[name]
+		return ok
+	})
+	for i, rec := range records {
+		records[i] = array.NewRecord(schema, rec.Columns(), rec.NumRows())
+	}
+	return array.NewTableFromRecords(schema, records)
 }
 
-func ProcessN(df array.Table, schema *arrow.Schema, narg []string) (new_table array.Table) {
-
-	colnames := map[string]int{}
-	var n float64
-	var nmin float64
+func ParseDataframe(table array.Table, cnames map[string]string, pipeline Pipeline, rejects *Rejects) (new_table array.Table, dropped map[string]int, err error) {
+	defer utils.TimeTrack(time.Now(), "ParseDataframe")
+	log.Println("Parsing dataframe.")
 
-	for i := 0; i < int(df.NumCols()); i++ {
-		colnames[df.Column(i).Name()] = i
+	new_table, dropped, err = pipeline.Run(RenameColumns(table, cnames), rejects)
+	if err != nil {
+		return nil, nil, err
 	}
+	log.Println("Finished Parsing.")
+	log.Println("Dropped:", dropped)
+	return
+}
 
-	if utils.InList("N", utils.GetKeys(colnames)) {
-		log.Println("N column found.")
-		tr := array.NewTableReader(df, 200)
-		for tr.Next() {
-		}
-		//nmin = stat.Quantile(0.9, stat.Empirical, ncol_data, nil) / 1.5
-		//log.Println("Nmin:", nmin)
+// quantile returns the q quantile of sorted by linear interpolation between
+// the closest ranks, the default of pandas that LDSC uses. stat.LinInterp
+// interpolates differently.
+func quantile(sorted []float64, q float64) float64 {
+	h := q * float64(len(sorted)-1)
+	lo := int(math.Floor(h))
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
 	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
 
-	if utils.InList("N_CON", utils.GetKeys(colnames)) {
-		log.Println("N_CON column found.")
-		ncon := df.Column(colnames["N_CON"]).Data()
-		ncas := df.Column(colnames["N_CAS"]).Data()
+// NOptions are the sample sizes given on the command line for ProcessN;
+// zero means not given.
+type NOptions struct {
+	N         float64
+	NCas      float64
+	NCon      float64
+	NMin      float64
+	NStudyMin float64
+	// KeepCases keeps N_CAS and N_CON after N is set from them, for the NC
+	// field of GWAS-VCF.
+	KeepCases bool
+}
 
-		ncon_data := []float64{}
-		ncas_data := []float64{}
+// ProcessN sets the N column as LDSC does. Per-SNP N_CAS and N_CON become an
+// effective N, scaled by the case fraction of the SNPs with the largest N.
+// SNPs with N below opts.NMin, or the 90th percentile of N / 1.5 if NMin is
+// not given, are dropped; without N, so are SNPs in fewer than
+// opts.NStudyMin studies, or all the studies if it is not given. A table
+// still without N gets the N, or N_CAS + N_CON, of opts. With
+// opts.KeepCases, N_CAS and N_CON are kept, or set from opts. dropped counts
+// the rows dropped for N and NSTUDY, which are recorded in rejects.
+func ProcessN(table array.Table, opts NOptions, rejects *Rejects) (new_table array.Table, dropped map[string]int, err error) {
+	defer utils.TimeTrack(time.Now(), "ProcessN")
 
-		for _, c := range ncon.Chunks() {
-			ncon_data = append(ncon_data, array.NewFloat64Data(c.Data()).Float64Values()...)
+	new_table = table
+	dropped = map[string]int{}
+	cas_idx, con_idx := ColumnIndex(new_table, "N_CAS"), ColumnIndex(new_table, "N_CON")
+	if cas_idx >= 0 && con_idx >= 0 {
+		ncas := Float64Values(new_table.Column(cas_idx))
+		ncon := Float64Values(new_table.Column(con_idx))
+		n := make([]float64, len(ncas))
+		floats.AddTo(n, ncas, ncon)
+		frac := make([]float64, len(n))
+		floats.DivTo(frac, ncas, n)
+		max_frac, count := 0.0, 0
+		if len(n) > 0 {
+			max_n := floats.Max(n)
+			for i := range n {
+				if n[i] == max_n {
+					max_frac += frac[i]
+					count++
+				}
+			}
+			max_frac /= float64(count)
 		}
-
-		for _, c := range ncas.Chunks() {
-			ncas_data = append(ncas_data, array.NewFloat64Data(c.Data()).Float64Values()...)
+		for i := range n {
+			n[i] *= frac[i] / max_frac
 		}
-		N := make([]float64, 0)
-		floats.AddTo(N, ncon_data, ncas_data)
-
-		P := make([]float64, len(N))
-		floats.DivTo(P, ncas_data, N)
-
-		max := make([]float64, len(N))
-		maxv := floats.Max(N)
-		for i := range N {
-			max[i] = maxv
+		new_table = SetFloat64Column(new_table, "N", n)
+		if !opts.KeepCases {
+			new_table = DropColumns(new_table, []string{"N_CAS", "N_CON"})
 		}
-		G := make([]float64, len(N))
-		PdivMax := make([]float64, len(N))
-		floats.DivTo(PdivMax, P, max)
-		floats.MulTo(G, N, PdivMax)
-		mean := floats.Sum(G) / float64(len(N))
-		n = mean
 	}
 
-	if utils.InList("NSTUDY", utils.GetKeys(colnames)) && !utils.InList("N", utils.GetKeys(colnames)) {
-		log.Println("NSTUDY column found.")
-		ncol := df.Column(colnames["NSTUDY"]).Data()
-		ncol_data := []float64{}
-		for _, c := range ncol.Chunks() {
-			ncol_data = append(ncol_data, array.NewFloat64Data(c.Data()).Float64Values()...)
+	if i := ColumnIndex(new_table, "N"); i >= 0 {
+		n := Float64Values(new_table.Column(i))
+		nmin := opts.NMin
+		if nmin == 0 && len(n) > 0 {
+			sorted := append([]float64{}, n...)
+			sort.Float64s(sorted)
+			nmin = quantile(sorted, 0.9) / 1.5
 		}
-		nmin = floats.Max(ncol_data)
-	}
-
-	if !utils.InList("N", utils.GetKeys(colnames)) {
-		var err error
-		if narg[0] != "" {
-			n, err = strconv.ParseFloat(narg[0], 64)
-			if err != nil {
-				log.Println("Error:", err)
-				return
+		reasons := make([]string, len(n))
+		for j, v := range n {
+			if !(v >= nmin) {
+				reasons[j] = "n"
+				dropped["n"]++
 			}
-			log.Println("N =", n)
-		} else if narg[1] != "" {
-			ncon, err := strconv.ParseFloat(narg[1], 64)
-			if err != nil {
-				log.Println("Error:", err)
-				return
-			}
-			ncas, err := strconv.ParseFloat(narg[2], 64)
-			if err != nil {
-				log.Println("Error:", err)
-				return
+		}
+		if new_table, err = rejects.Filter(new_table, reasons); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Removed %d SNPs with N < %s (%d SNPs remain).", dropped["n"], FormatFloat(nmin), new_table.NumRows())
+	} else if i := ColumnIndex(new_table, "NSTUDY"); i >= 0 {
+		nstudy := Float64Values(new_table.Column(i))
+		nstudy_min := opts.NStudyMin
+		if nstudy_min == 0 && len(nstudy) > 0 {
+			nstudy_min = floats.Max(nstudy)
+		}
+		reasons := make([]string, len(nstudy))
+		for j, v := range nstudy {
+			if !(v >= nstudy_min) {
+				reasons[j] = "nstudy"
+				dropped["nstudy"]++
 			}
-			n = ncon + ncas
-			log.Println("N =", n)
 		}
+		if new_table, err = rejects.Filter(new_table, reasons); err != nil {
+			return nil, nil, err
+		}
+		new_table = DropColumns(new_table, []string{"NSTUDY"})
+		log.Printf("Removed %d SNPs with NSTUDY < %s (%d SNPs remain).", dropped["nstudy"], FormatFloat(nstudy_min), new_table.NumRows())
 	}
 
-	records := make([]array.Record, 0)
-	tr := array.NewTableReader(df, 200)
-
-	log.Print("Processing...")
-	for tr.Next() {
-		rec := tr.Record()
-		log.Println("Processing record...")
-		rec.Retain()
-		drop_idxs := []int{}
-		for i, col := range rec.Columns() {
-			colname := rec.ColumnName(i)
-			if colname == "N" || colname == "NSTUDY" && nmin > 0 {
-				log.Println("Filtering column", colname, "by N >=", nmin)
-				d := array.NewFloat64Data(col.Data())
-				for i, v := range d.Float64Values() {
-					if v < nmin {
-						drop_idxs = append(drop_idxs, i)
-					}
-				}
-			}
+	if ColumnIndex(new_table, "N") < 0 {
+		n := opts.N
+		if n == 0 {
+			n = opts.NCas + opts.NCon
 		}
-		if len(drop_idxs) > 0 {
-			slice_idxs := utils.Slices(int(rec.NumRows()), drop_idxs)
-			drop_idxs = []int{}
-			new_cols := make([]array.Interface, 0)
-			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
-			for _, col := range rec.Columns() {
-				new_data := make([]array.Interface, 0)
-				for _, idx := range slice_idxs {
-					ns := array.NewSliceData(col.Data(), int64(idx[0]), int64(idx[1]))
-					ns.Retain()
-					new_data = append(new_data, array.NewFloat64Data(ns))
-					ns.Release()
-				}
-				new_col, err := array.Concatenate(new_data, mem)
-				if err != nil {
-					log.Println(new_data)
-					log.Println("Error:", err)
-					return
-				}
-				new_col.Retain()
-				new_cols = append(new_cols, new_col)
-				new_col.Release()
-			}
-			new_rec := array.NewRecord(schema, new_cols, int64(new_cols[0].Len()))
-			new_rec.Retain()
-			records = append(records, new_rec)
-			new_rec.Release()
-		} else {
-			data := make([]float64, rec.NumRows())
-			for i := range data {
-				data[i] = n
-			}
-			fields := make([]arrow.Field, 0)
-			fixed := false
-			for _, c := range schema.Fields() {
-				if utils.InList(c.Name, []string{"N", "NSTUDY", "N_CON", "N_CAS"}) && !fixed {
-					c = arrow.Field{Name: "N", Type: arrow.PrimitiveTypes.Float64, Nullable: true, Metadata: arrow.Metadata{}}
-					fixed = true
-				}
-				fields = append(fields, c)
-			}
-			nschema := arrow.NewSchema(fields, nil)
-			mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
-			nrbld := array.NewRecordBuilder(mem, nschema)
-			defer nrbld.Release()
-			for i := range nrbld.Fields() {
-				if nschema.Field(i).Type.Name() == "FLOAT64" {
-					if nschema.Field(i).Name == "N" {
-						nrbld.Field(i).(*array.Float64Builder).AppendValues(data, nil)
-					} else {
-						nrbld.Field(i).(*array.Float64Builder).AppendValues(array.NewFloat64Data(rec.Column(i).Data()).Float64Values(), nil)
-					}
-				} else {
-					strs := make([]string, rec.NumRows())
-					for i := 0; int64(i) < rec.NumRows(); i++ {
-						strs = append(strs, array.NewStringData(rec.Column(i).Data()).Value(i))
-					}
-					nrbld.Field(i).(*array.StringBuilder).AppendValues(strs, nil)
-				}
+		if n == 0 {
+			return nil, nil, fmt.Errorf("could not determine N")
+		}
+		values := make([]float64, new_table.NumRows())
+		for i := range values {
+			values[i] = n
+		}
+		new_table = SetFloat64Column(new_table, "N", values)
+	}
+	if opts.KeepCases && opts.NCas > 0 && ColumnIndex(new_table, "N_CAS") < 0 {
+		for _, c := range []struct {
+			name  string
+			value float64
+		}{{"N_CAS", opts.NCas}, {"N_CON", opts.NCon}} {
+			values := make([]float64, new_table.NumRows())
+			for i := range values {
+				values[i] = c.value
 			}
-			rec := nrbld.NewRecord()
-			records = append(records, rec)
+			new_table = SetFloat64Column(new_table, c.name, values)
 		}
 	}
-	new_table = array.NewTableFromRecords(schema, records)
-	new_table.Retain()
 	return
 }
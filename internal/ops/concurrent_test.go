@@ -0,0 +1,106 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+)
+
+// readTestTSV reads a TSV with a string SNP column and float64 others.
+func readTestTSV(t *testing.T, data string) array.Table {
+	file := filepath.Join(t.TempDir(), "in.tsv")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	header := strings.Split(strings.SplitN(data, "\n", 2)[0], "\t")
+	ctypes := map[string]arrow.DataType{}
+	for _, c := range header {
+		ctypes[c] = arrow.PrimitiveTypes.Float64
+	}
+	ctypes["SNP"] = arrow.BinaryTypes.String
+	table, _ := ArrowCSV(file, header, '\t', ctypes)
+	if table == nil {
+		t.Fatal("could not read", file)
+	}
+	return table
+}
+
+// The references follow process_n of LDSC's munge_sumstats.py: N_CAS and
+// N_CON give N * P / mean(P[N == max(N)]), and --n-min defaults to
+// N.quantile(0.9) / 1.5 with the linear interpolation of pandas.
+func TestProcessN(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		data    string
+		opts    NOptions
+		want    string
+		dropped map[string]int
+	}{
+		{
+			name:    "cases and controls",
+			data:    "SNP\tN_CAS\tN_CON\nrs1\t500\t500\nrs2\t1000\t1000\nrs3\t250\t750\n",
+			want:    "rs2:2000",
+			dropped: map[string]int{"n": 2},
+		},
+		{
+			name:    "default n-min",
+			data:    "SNP\tN\nrs1\t100\nrs2\t200\nrs3\t300\nrs4\t400\nrs5\t500\nrs6\t600\nrs7\t700\nrs8\t800\nrs9\t900\nrs10\t1000\n",
+			want:    "rs7:700,rs8:800,rs9:900,rs10:1000",
+			dropped: map[string]int{"n": 6},
+		},
+		{
+			name:    "n-min",
+			data:    "SNP\tN\nrs1\t100\nrs2\t200\nrs3\t300\nrs4\t400\n",
+			opts:    NOptions{NMin: 250},
+			want:    "rs3:300,rs4:400",
+			dropped: map[string]int{"n": 2},
+		},
+		{
+			name:    "nstudy",
+			data:    "SNP\tNSTUDY\nrs1\t3\nrs2\t2\nrs3\t3\n",
+			opts:    NOptions{N: 5000},
+			want:    "rs1:5000,rs3:5000",
+			dropped: map[string]int{"nstudy": 1},
+		},
+		{
+			name:    "ncas and ncon",
+			data:    "SNP\tP\nrs1\t0.1\n",
+			opts:    NOptions{NCas: 100, NCon: 300},
+			want:    "rs1:400",
+			dropped: map[string]int{},
+		},
+	} {
+		table, dropped, err := ProcessN(readTestTSV(t, c.data), c.opts, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		for _, col := range []string{"N_CAS", "N_CON", "NSTUDY"} {
+			if ColumnIndex(table, col) >= 0 {
+				t.Errorf("%s: %s column left in", c.name, col)
+			}
+		}
+		snps := StringValues(table.Column(ColumnIndex(table, "SNP")))
+		n := Float64Values(table.Column(ColumnIndex(table, "N")))
+		rows := []string{}
+		for i := range snps {
+			rows = append(rows, snps[i]+":"+FormatFloat(n[i]))
+		}
+		if got := strings.Join(rows, ","); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+		for reason, count := range c.dropped {
+			if dropped[reason] != count {
+				t.Errorf("%s: dropped %v, want %v", c.name, dropped, c.dropped)
+			}
+		}
+	}
+
+	if _, _, err := ProcessN(readTestTSV(t, "SNP\tP\nrs1\t0.1\n"), NOptions{}, nil); err == nil {
+		t.Error("ProcessN without any N: no error")
+	}
+}
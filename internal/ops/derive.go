@@ -0,0 +1,259 @@
+package ops
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+type DeriveOptions struct {
+	// BetaSE derives standardised BETA and SE from Z, FRQ and N when the
+	// file has none.
+	BetaSE bool
+	// SignedNull is the value of SIGNED_SUMSTAT with no effect, e.g. 0 for a
+	// BETA or 1 for an OR given with --signed-sumstats.
+	SignedNull float64
+}
+
+type derivation struct {
+	col     string
+	formula string
+	inputs  []string
+	fn      func(v []float64) float64
+}
+
+var derivations = []derivation{
+	{"Z", "BETA / SE", []string{"BETA", "SE"}, func(v []float64) float64 { return v[0] / v[1] }},
+	{"Z", "LOG_ODDS / SE", []string{"LOG_ODDS", "SE"}, func(v []float64) float64 { return v[0] / v[1] }},
+	{"Z", "log(OR) / SE", []string{"OR", "SE"}, func(v []float64) float64 { return math.Log(v[0]) / v[1] }},
+	{"Z", "sign(BETA) * Phi^-1(1 - P / 2)", []string{"BETA", "LOG10P"}, func(v []float64) float64 { return sign(v[0]) * zFromLog10P(v[1]) }},
+	{"Z", "sign(LOG_ODDS) * Phi^-1(1 - P / 2)", []string{"LOG_ODDS", "LOG10P"}, func(v []float64) float64 { return sign(v[0]) * zFromLog10P(v[1]) }},
+	{"Z", "sign(log(OR)) * Phi^-1(1 - P / 2)", []string{"OR", "LOG10P"}, func(v []float64) float64 { return sign(math.Log(v[0])) * zFromLog10P(v[1]) }},
+	{"Z", "sign(BETA) * Phi^-1(1 - P / 2)", []string{"BETA", "P"}, func(v []float64) float64 { return sign(v[0]) * zFromLog10P(-math.Log10(v[1])) }},
+	{"Z", "sign(LOG_ODDS) * Phi^-1(1 - P / 2)", []string{"LOG_ODDS", "P"}, func(v []float64) float64 { return sign(v[0]) * zFromLog10P(-math.Log10(v[1])) }},
+	{"Z", "sign(log(OR)) * Phi^-1(1 - P / 2)", []string{"OR", "P"}, func(v []float64) float64 { return sign(math.Log(v[0])) * zFromLog10P(-math.Log10(v[1])) }},
+	{"P", "10^-LOG10P", []string{"LOG10P"}, func(v []float64) float64 { return math.Pow(10, -v[0]) }},
+	{"P", "2 * Phi(-|Z|)", []string{"Z"}, func(v []float64) float64 { return 2 * distuv.UnitNormal.Survival(math.Abs(v[0])) }},
+}
+
+// signedDerivations give Z from the sign of a --signed-sumstats column
+// relative to its null value and P, as LDSC's munge_sumstats does. They come
+// before the other derivations of Z.
+func signedDerivations(null float64) []derivation {
+	return []derivation{
+		{"Z", "sign(SIGNED_SUMSTAT - null) * Phi^-1(1 - P / 2)", []string{"SIGNED_SUMSTAT", "LOG10P"}, func(v []float64) float64 { return sign(v[0]-null) * zFromLog10P(v[1]) }},
+		{"Z", "sign(SIGNED_SUMSTAT - null) * Phi^-1(1 - P / 2)", []string{"SIGNED_SUMSTAT", "P"}, func(v []float64) float64 { return sign(v[0]-null) * zFromLog10P(-math.Log10(v[1])) }},
+	}
+}
+
+var betaSEDerivations = []derivation{
+	{"BETA", "Z / sqrt(2 * FRQ * (1 - FRQ) * (N + Z^2))", []string{"Z", "FRQ", "N"}, func(v []float64) float64 {
+		return v[0] / math.Sqrt(2*v[1]*(1-v[1])*(v[2]+v[0]*v[0]))
+	}},
+	{"SE", "1 / sqrt(2 * FRQ * (1 - FRQ) * (N + Z^2))", []string{"Z", "FRQ", "N"}, func(v []float64) float64 {
+		return 1 / math.Sqrt(2*v[1]*(1-v[1])*(v[2]+v[0]*v[0]))
+	}},
+}
+
+// DeriveStats adds the statistics missing from table that can be computed
+// from the columns it has: Z from the sign of SIGNED_SUMSTAT and P, from
+// BETA, LOG_ODDS or OR and SE, or from the sign of the effect and P, P from
+// LOG10P or Z, and on request standardised BETA and SE. Each derived field
+// carries a "derived" metadata entry with its formula.
+func DeriveStats(table array.Table, opts DeriveOptions) (new_table array.Table, derived []string) {
+	defer utils.TimeTrack(time.Now(), "DeriveStats")
+
+	schema := table.Schema()
+	have := map[string]bool{}
+	for _, f := range schema.Fields() {
+		have[f.Name] = true
+	}
+
+	todo := make([]derivation, 0)
+	steps := append(signedDerivations(opts.SignedNull), derivations...)
+	if opts.BetaSE {
+		steps = append(steps, betaSEDerivations...)
+	}
+	for _, d := range steps {
+		if have[d.col] {
+			continue
+		}
+		ok := true
+		for _, in := range d.inputs {
+			ok = ok && have[in]
+		}
+		if ok {
+			todo = append(todo, d)
+			have[d.col] = true
+			derived = append(derived, d.col)
+			log.Printf("Deriving %s = %s.", d.col, d.formula)
+		}
+	}
+	if len(todo) == 0 {
+		return table, nil
+	}
+
+	fields := append([]arrow.Field{}, schema.Fields()...)
+	for _, d := range todo {
+		fields = append(fields, arrow.Field{
+			Name:     d.col,
+			Type:     arrow.PrimitiveTypes.Float64,
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{"derived"}, []string{d.formula}),
+		})
+	}
+	new_schema := arrow.NewSchema(fields, nil)
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	records := make([]array.Record, 0)
+	tr := array.NewTableReader(table, 200)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.Record()
+		nrows := int(rec.NumRows())
+		values := map[string][]float64{}
+		for i, f := range rec.Schema().Fields() {
+			if f.Type.ID() != arrow.FLOAT64 {
+				continue
+			}
+			d := array.NewFloat64Data(rec.Column(i).Data())
+			values[f.Name] = d.Float64Values()
+			d.Release()
+		}
+		cols := append([]array.Interface{}, rec.Columns()...)
+		in := []float64{}
+		for _, d := range todo {
+			out := make([]float64, nrows)
+			for i := range out {
+				in = in[:0]
+				for _, c := range d.inputs {
+					in = append(in, values[c][i])
+				}
+				out[i] = d.fn(in)
+			}
+			values[d.col] = out
+			b := array.NewFloat64Builder(mem)
+			b.AppendValues(out, nil)
+			cols = append(cols, b.NewArray())
+			b.Release()
+		}
+		records = append(records, array.NewRecord(new_schema, cols, rec.NumRows()))
+	}
+	new_table = array.NewTableFromRecords(new_schema, records)
+	return
+}
+
+// ParsePColumn converts a P column read as text to float64. If any P-value is
+// below the float64 range and the table has no LOG10P column, -log10(P) is
+// kept in an added LOG10P column so the hit is not lost. It returns the
+// number of such P-values.
+func ParsePColumn(table array.Table) (new_table array.Table, underflow int) {
+	defer utils.TimeTrack(time.Now(), "ParsePColumn")
+
+	idx := ColumnIndex(table, "P")
+	if idx < 0 || table.Column(idx).DataType().ID() != arrow.STRING {
+		return table, 0
+	}
+	text := StringValues(table.Column(idx))
+	p := make([]float64, len(text))
+	log10p := make([]float64, len(text))
+	for i, s := range text {
+		var err error
+		p[i], log10p[i], err = parse.ParseP(s)
+		if err != nil {
+			p[i], log10p[i] = math.NaN(), math.NaN()
+		}
+		if p[i] == 0 && !math.IsInf(log10p[i], 0) {
+			underflow++
+		}
+	}
+	add_log10p := underflow > 0 && ColumnIndex(table, "LOG10P") < 0
+
+	fields := append([]arrow.Field{}, table.Schema().Fields()...)
+	fields[idx] = arrow.Field{Name: "P", Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+	if add_log10p {
+		fields = append(fields, arrow.Field{
+			Name:     "LOG10P",
+			Type:     arrow.PrimitiveTypes.Float64,
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{"derived"}, []string{"-log10(P)"}),
+		})
+	}
+	new_schema := arrow.NewSchema(fields, nil)
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	records := make([]array.Record, 0)
+	tr := array.NewTableReader(table, 200)
+	defer tr.Release()
+	offset := 0
+	for tr.Next() {
+		rec := tr.Record()
+		nrows := int(rec.NumRows())
+		cols := append([]array.Interface{}, rec.Columns()...)
+		b := array.NewFloat64Builder(mem)
+		b.AppendValues(p[offset:offset+nrows], nil)
+		cols[idx] = b.NewArray()
+		if add_log10p {
+			b.AppendValues(log10p[offset:offset+nrows], nil)
+			cols = append(cols, b.NewArray())
+		}
+		b.Release()
+		offset += nrows
+		records = append(records, array.NewRecord(new_schema, cols, rec.NumRows()))
+	}
+	new_table = array.NewTableFromRecords(new_schema, records)
+	return
+}
+
+// zFromLog10P returns the two-sided |Z| for P = 10^-log10p. Beyond the range
+// of the standard quantile it solves log(Phi(-z)) = log(P / 2) by Newton's
+// method so that P-values below the float64 range still give a finite Z.
+func zFromLog10P(log10p float64) float64 {
+	if math.IsNaN(log10p) || log10p < 0 {
+		return math.NaN()
+	}
+	if log10p < 100 {
+		return math.Abs(distuv.UnitNormal.Quantile(math.Pow(10, -log10p) / 2))
+	}
+	target := -log10p*math.Ln10 - math.Ln2
+	z := math.Sqrt(-2*target - math.Log(-2*target) - math.Log(2*math.Pi))
+	for i := 0; i < 50; i++ {
+		logsf := logNormalSurvival(z)
+		// d/dz log(Phi(-z)) = -phi(z) / Phi(-z)
+		step := (logsf - target) / -math.Exp(-z*z/2-0.5*math.Log(2*math.Pi)-logsf)
+		z -= step
+		if math.Abs(step) < 1e-12*z {
+			break
+		}
+	}
+	return z
+}
+
+// logNormalSurvival returns log(Phi(-z)), using the asymptotic expansion of
+// the normal tail where erfc underflows.
+func logNormalSurvival(z float64) float64 {
+	if z < 30 {
+		return math.Log(0.5 * math.Erfc(z/math.Sqrt2))
+	}
+	z2 := z * z
+	return -z2/2 - math.Log(z) - 0.5*math.Log(2*math.Pi) + math.Log(1-1/z2+3/(z2*z2)-15/(z2*z2*z2))
+}
+
+func sign(v float64) float64 {
+	switch {
+	case math.IsNaN(v):
+		return v
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
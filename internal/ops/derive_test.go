@@ -0,0 +1,81 @@
+package ops
+
+import (
+	"math"
+	"testing"
+)
+
+// The references are scipy.stats.norm.isf(P / 2), solved beyond the float64
+// range of P from the asymptotic series of the normal tail.
+func TestZFromLog10P(t *testing.T) {
+	for _, c := range []struct {
+		log10p float64
+		z      float64
+	}{
+		{0, 0},
+		{-math.Log10(0.05), 1.9599639845400545},
+		{-math.Log10(5e-8), 5.451310437845478},
+		{10, 6.466951087240517},
+		{50, 14.979477571624336},
+		{99, 21.197831058255417},
+		{100, 21.30594006935153},
+		{101, 21.413505650993223},
+		{200, 30.22850808071547},
+		{300, 37.065787880772135},
+		{400, 42.82640649117117},
+		{1000, 67.79590817078778},
+		{10000, 214.57053142669346},
+	} {
+		if z := zFromLog10P(c.log10p); math.Abs(z-c.z) > 1e-9*math.Max(1, c.z) {
+			t.Errorf("zFromLog10P(%g) = %.15g, want %.15g", c.log10p, z, c.z)
+		}
+	}
+	for _, log10p := range []float64{-1, math.NaN()} {
+		if z := zFromLog10P(log10p); !math.IsNaN(z) {
+			t.Errorf("zFromLog10P(%g) = %g, want NaN", log10p, z)
+		}
+	}
+}
+
+func TestDeriveStats(t *testing.T) {
+	const z05 = 1.959963984540054 // Phi^-1(1 - 0.05 / 2)
+	for _, c := range []struct {
+		name string
+		data string
+		opts DeriveOptions
+		want map[string]float64
+	}{
+		{"Z from BETA/SE", "SNP\tBETA\tSE\nrs1\t0.2\t0.1\n", DeriveOptions{}, map[string]float64{"Z": 2, "P": 0.04550026389635842}},
+		{"Z from LOG_ODDS/SE", "SNP\tLOG_ODDS\tSE\nrs1\t-0.3\t0.1\n", DeriveOptions{}, map[string]float64{"Z": -3}},
+		{"Z from OR/SE", "SNP\tOR\tSE\nrs1\t1.6487212707001282\t0.25\n", DeriveOptions{}, map[string]float64{"Z": 2}},
+		{"Z from sign(BETA) and P", "SNP\tBETA\tP\nrs1\t-0.1\t0.05\n", DeriveOptions{}, map[string]float64{"Z": -z05}},
+		{"Z from sign(log(OR)) and LOG10P", "SNP\tOR\tLOG10P\nrs1\t1.1\t1.3010299956639813\n", DeriveOptions{}, map[string]float64{"Z": z05, "P": 0.05}},
+		{"Z from SIGNED_SUMSTAT and P", "SNP\tSIGNED_SUMSTAT\tP\nrs1\t0.9\t0.05\n", DeriveOptions{SignedNull: 1}, map[string]float64{"Z": -z05}},
+		{"SIGNED_SUMSTAT before BETA/SE", "SNP\tSIGNED_SUMSTAT\tBETA\tSE\tP\nrs1\t0.9\t0.2\t0.1\t0.05\n", DeriveOptions{}, map[string]float64{"Z": z05}},
+		{"P from Z", "SNP\tZ\nrs1\t-1.959963984540054\n", DeriveOptions{}, map[string]float64{"P": 0.05}},
+		{"P from LOG10P", "SNP\tLOG10P\nrs1\t3\n", DeriveOptions{}, map[string]float64{"P": 0.001}},
+		{"BETA and SE from Z, FRQ and N", "SNP\tZ\tFRQ\tN\nrs1\t2\t0.5\t996\n", DeriveOptions{BetaSE: true}, map[string]float64{"BETA": 2 / math.Sqrt(500), "SE": 1 / math.Sqrt(500)}},
+		{"BETA and SE only on request", "SNP\tZ\tFRQ\tN\nrs1\t2\t0.5\t996\n", DeriveOptions{}, map[string]float64{"BETA": math.NaN(), "SE": math.NaN()}},
+	} {
+		table, derived := DeriveStats(readTestTSV(t, c.data), c.opts)
+		for col, want := range c.want {
+			idx := ColumnIndex(table, col)
+			if math.IsNaN(want) {
+				if idx >= 0 {
+					t.Errorf("%s: derived %s", c.name, col)
+				}
+				continue
+			}
+			if idx < 0 {
+				t.Errorf("%s: no %s column, derived %v", c.name, col, derived)
+				continue
+			}
+			if got := Float64Values(table.Column(idx))[0]; math.Abs(got-want) > 1e-12*math.Max(1, math.Abs(want)) {
+				t.Errorf("%s: %s = %.15g, want %.15g", c.name, col, got, want)
+			}
+			if !table.Schema().Field(idx).HasMetadata() {
+				t.Errorf("%s: derived %s has no metadata", c.name, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package ops
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// Duplicate_policies are the ways RemoveDuplicateSNPS resolves rows with the
+// same SNP: keep the first, drop them all, keep the row with the largest N,
+// smallest P or largest INFO, or keep them all.
+var Duplicate_policies = []string{"first", "drop-all", "max-n", "min-p", "max-info", "none"}
+
+// DuplicateOptions select how RemoveDuplicateSNPS resolves duplicates. With
+// ByAlleles, rows of a multi-allelic SNP with different alleles are distinct
+// variants.
+type DuplicateOptions struct {
+	Policy    string
+	ByAlleles bool
+}
+
+// RemoveDuplicateSNPS drops all but one row of each SNP, chosen by
+// opts.Policy; ties keep the first row. Dropped rows are recorded in rejects
+// as "duplicate".
+func RemoveDuplicateSNPS(table array.Table, opts DuplicateOptions, rejects *Rejects) (new_table array.Table, dropped int, err error) {
+	defer utils.TimeTrack(time.Now(), "RemoveDuplicateSNPS")
+
+	if opts.Policy == "none" {
+		return table, 0, nil
+	}
+	var score []float64
+	switch opts.Policy {
+	case "first", "drop-all":
+	case "max-n":
+		score, err = scoreColumn(table, "N")
+	case "max-info":
+		score, err = scoreColumn(table, "INFO")
+	case "min-p":
+		if i := ColumnIndex(table, "LOG10P"); i >= 0 {
+			score = Float64Values(table.Column(i))
+		} else if i := ColumnIndex(table, "P"); i >= 0 {
+			score = Log10PValues(table.Column(i))
+		} else {
+			err = fmt.Errorf("--duplicates min-p needs a P or LOG10P column")
+		}
+	default:
+		err = fmt.Errorf("unknown duplicate policy %q; use one of %s", opts.Policy, strings.Join(Duplicate_policies, ", "))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	names := []string{"SNP"}
+	if opts.ByAlleles {
+		if ColumnIndex(table, "A1") < 0 || ColumnIndex(table, "A2") < 0 {
+			return nil, 0, fmt.Errorf("--duplicates-by-alleles needs A1 and A2 columns")
+		}
+		names = append(names, "A1", "A2")
+	}
+	keys := newKeyColumns(table, names)
+	defer keys.release()
+
+	// first[i] is the first row with the key of row i, and best[f] the row
+	// kept for the key of first row f
+	nrows := int(table.NumRows())
+	index := newKeyIndex(nrows)
+	first := make([]int32, nrows)
+	best := make([]int32, nrows)
+	count := make([]int32, nrows)
+	for i := 0; i < nrows; i++ {
+		f := index.add(keys, int32(i))
+		first[i] = f
+		count[f]++
+		if f == int32(i) {
+			best[f] = f
+		} else if score != nil && better(score[i], score[best[f]]) {
+			best[f] = int32(i)
+		}
+	}
+
+	reasons := make([]string, nrows)
+	for i := range reasons {
+		f := first[i]
+		if count[f] > 1 && (opts.Policy == "drop-all" || best[f] != int32(i)) {
+			reasons[i] = "duplicate"
+			dropped++
+		}
+	}
+	if new_table, err = rejects.Filter(table, reasons); err != nil {
+		return nil, 0, err
+	}
+	log.Println("Dropped", dropped, "duplicate SNPs.")
+	return
+}
+
+func scoreColumn(table array.Table, name string) ([]float64, error) {
+	i := ColumnIndex(table, name)
+	if i < 0 {
+		return nil, fmt.Errorf("--duplicates needs a %s column", name)
+	}
+	return Float64Values(table.Column(i)), nil
+}
+
+// better reports whether a beats b; NaN never does.
+func better(a float64, b float64) bool {
+	return a > b || (math.IsNaN(b) && !math.IsNaN(a))
+}
+
+// keyColumns reads the key of a row, its SNP and, by alleles, its A1 and A2
+// with the pair in sorted order, straight from the string columns of a
+// table.
+type keyColumns struct {
+	cols   [][]*array.String
+	starts [][]int
+}
+
+func newKeyColumns(table array.Table, names []string) *keyColumns {
+	k := &keyColumns{}
+	for _, name := range names {
+		chunks := table.Column(ColumnIndex(table, name)).Data().Chunks()
+		col := make([]*array.String, len(chunks))
+		starts := make([]int, len(chunks))
+		for c, chunk := range chunks {
+			col[c] = array.NewStringData(chunk.Data())
+			if c > 0 {
+				starts[c] = starts[c-1] + chunks[c-1].Len()
+			}
+		}
+		k.cols = append(k.cols, col)
+		k.starts = append(k.starts, starts)
+	}
+	return k
+}
+
+func (k *keyColumns) release() {
+	for _, col := range k.cols {
+		for _, d := range col {
+			d.Release()
+		}
+	}
+}
+
+// values returns the SNP of row and, with alleles, the upper case alleles
+// in sorted order.
+func (k *keyColumns) values(row int32) (snp string, a1 string, a2 string) {
+	snp = k.value(0, int(row))
+	if len(k.cols) == 3 {
+		a1, a2 = strings.ToUpper(k.value(1, int(row))), strings.ToUpper(k.value(2, int(row)))
+		if a2 < a1 {
+			a1, a2 = a2, a1
+		}
+	}
+	return
+}
+
+func (k *keyColumns) value(j int, row int) string {
+	starts := k.starts[j]
+	c := sort.Search(len(starts), func(c int) bool { return starts[c] > row }) - 1
+	return k.cols[j][c].Value(row - starts[c])
+}
+
+// keyIndex maps each key to the first row it was seen in. It holds a 64-bit
+// FNV-1a hash of each key and the row, not the key, which is read back from
+// the columns to tell apart keys whose hashes collide.
+type keyIndex struct {
+	rows      map[uint64]int32
+	collision map[[3]string]int32
+}
+
+func newKeyIndex(n int) *keyIndex {
+	return &keyIndex{rows: make(map[uint64]int32, n), collision: map[[3]string]int32{}}
+}
+
+// add returns the first row with the key of row, which is row if the key is
+// new.
+func (x *keyIndex) add(keys *keyColumns, row int32) int32 {
+	snp, a1, a2 := keys.values(row)
+	sum := hashKey(snp, a1, a2)
+	f, ok := x.rows[sum]
+	if !ok {
+		x.rows[sum] = row
+		return row
+	}
+	if s, b1, b2 := keys.values(f); s == snp && b1 == a1 && b2 == a2 {
+		return f
+	}
+	key := [3]string{snp, a1, a2}
+	if f, ok := x.collision[key]; ok {
+		return f
+	}
+	x.collision[key] = row
+	return row
+}
+
+// hashKey is 64-bit FNV-1a of the fields, each followed by a tab, without
+// joining them.
+func hashKey(fields ...string) uint64 {
+	h := uint64(14695981039346656037)
+	for _, key := range fields {
+		for i := 0; i < len(key); i++ {
+			h ^= uint64(key[i])
+			h *= 1099511628211
+		}
+		h ^= '\t'
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,83 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+)
+
+const dup_tsv = `SNP	A1	A2	N	P
+rs1	A	G	100	0.5
+rs2	C	T	100	0.1
+rs1	g	a	300	0.01
+rs3	A	C	100	0.2
+rs1	A	T	200	0.001
+rs2	C	T	50	0.3
+`
+
+func readDupTable(t *testing.T) array.Table {
+	file := filepath.Join(t.TempDir(), "dup.tsv")
+	if err := os.WriteFile(file, []byte(dup_tsv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"SNP", "A1", "A2", "N", "P"}
+	ctypes := map[string]arrow.DataType{
+		"SNP": arrow.BinaryTypes.String,
+		"A1":  arrow.BinaryTypes.String,
+		"A2":  arrow.BinaryTypes.String,
+		"N":   arrow.PrimitiveTypes.Float64,
+		"P":   arrow.PrimitiveTypes.Float64,
+	}
+	table, _ := ArrowCSV(file, header, '\t', ctypes)
+	if table == nil {
+		t.Fatal("could not read", file)
+	}
+	return table
+}
+
+func TestRemoveDuplicateSNPS(t *testing.T) {
+	for _, c := range []struct {
+		opts DuplicateOptions
+		want string
+	}{
+		{DuplicateOptions{Policy: "first"}, "0.5,0.1,0.2"},
+		{DuplicateOptions{Policy: "drop-all"}, "0.2"},
+		{DuplicateOptions{Policy: "max-n"}, "0.1,0.01,0.2"},
+		{DuplicateOptions{Policy: "min-p"}, "0.1,0.2,0.001"},
+		{DuplicateOptions{Policy: "none"}, "0.5,0.1,0.01,0.2,0.001,0.3"},
+		{DuplicateOptions{Policy: "first", ByAlleles: true}, "0.5,0.1,0.2,0.001"},
+		{DuplicateOptions{Policy: "max-n", ByAlleles: true}, "0.1,0.01,0.2,0.001"},
+	} {
+		table, dropped, err := RemoveDuplicateSNPS(readDupTable(t), c.opts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := []string{}
+		for _, v := range Float64Values(table.Column(ColumnIndex(table, "P"))) {
+			p = append(p, FormatFloat(v))
+		}
+		if got := strings.Join(p, ","); got != c.want {
+			t.Errorf("%+v keeps P %s, want %s", c.opts, got, c.want)
+		}
+		if want := 6 - len(strings.Split(c.want, ",")); dropped != want {
+			t.Errorf("%+v dropped %d, want %d", c.opts, dropped, want)
+		}
+	}
+}
+
+func TestKeyIndexCollision(t *testing.T) {
+	keys := newKeyColumns(readDupTable(t), []string{"SNP"})
+	defer keys.release()
+	// rows 0 and 1 under one hash, as if rs1 and rs2 collided
+	x := newKeyIndex(6)
+	x.rows[hashKey("rs2", "", "")] = 0
+	for row, want := range []int32{0, 1, 0, 3, 0, 1} {
+		if f := x.add(keys, int32(row)); f != want {
+			t.Errorf("row %d has first row %d, want %d", row, f, want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package ops
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// Filter is a row QC check of munge. Apply is given records whose columns
+// have their canonical names and include Columns, and returns for each row
+// whether to keep it and, if not, the reason code it is counted and
+// recorded under.
+type Filter interface {
+	Name() string
+	Columns() []string
+	Apply(rec array.Record) (keep []bool, reasons []string)
+}
+
+// FilterFactory makes a filter from the argument of its --filters spec,
+// which is "" if none is given.
+type FilterFactory func(arg string) (Filter, error)
+
+var filter_factories = map[string]FilterFactory{}
+
+// RegisterFilter makes a filter available to --filters under name. Packages
+// with custom filters call it from init.
+func RegisterFilter(name string, factory FilterFactory) {
+	if _, ok := filter_factories[name]; ok {
+		panic("ops: filter " + name + " registered twice")
+	}
+	filter_factories[name] = factory
+}
+
+// FilterNames returns the names of the registered filters, sorted.
+func FilterNames() []string {
+	names := make([]string, 0, len(filter_factories))
+	for name := range filter_factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFilters makes the filters of a comma separated --filters list of
+// NAME or NAME=ARG specs.
+func ParseFilters(specs string) ([]Filter, error) {
+	filters := []Filter{}
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(spec, "=")
+		factory, ok := filter_factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q; registered filters are %s", name, strings.Join(FilterNames(), ", "))
+		}
+		f, err := factory(arg)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %v", name, err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// QCOptions are the thresholds of the standard munge filters.
+type QCOptions struct {
+	MAFMin  float64
+	INFOMin float64
+}
+
+// DefaultFilters returns the standard munge QC: missing values, then P,
+// MAF, INFO, SE, BP, alleles and CHR, in the order their reasons are
+// assigned.
+func DefaultFilters(opts QCOptions) []Filter {
+	return []Filter{
+		MissingFilter{},
+		ColumnFilter{Reason: "p", Column: "P", Float: parse.FilterP, String: parse.FilterPString},
+		ColumnFilter{Reason: "p", Column: "LOG10P", Float: parse.FilterLog10P},
+		ColumnFilter{Reason: "maf", Column: "FRQ", Float: func(v float64) bool {
+			return parse.FilterFRQ(math.Min(v, 1-v), opts.MAFMin)
+		}},
+		ColumnFilter{Reason: "info", Column: "INFO", Float: func(v float64) bool {
+			return parse.FilterINFO(v, opts.INFOMin)
+		}},
+		ColumnFilter{Reason: "se", Column: "SE", Float: parse.FilterSE},
+		ColumnFilter{Reason: "bp", Column: "BP", Float: parse.FilterBP},
+		ColumnFilter{Reason: "alleles", Column: "A1", String: func(v string) bool {
+			return parse.FilterAllele(strings.ToUpper(v))
+		}},
+		ColumnFilter{Reason: "alleles", Column: "A2", String: func(v string) bool {
+			return parse.FilterAllele(strings.ToUpper(v))
+		}},
+		ColumnFilter{Reason: "chr", Column: "CHR", String: parse.FilterCHR},
+	}
+}
+
+func init() {
+	RegisterFilter("se-max", func(arg string) (Filter, error) {
+		max, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("needs a number, e.g. se-max=10")
+		}
+		return ColumnFilter{Reason: "se_max", Column: "SE", Float: func(v float64) bool { return v > max }}, nil
+	})
+	RegisterFilter("beta-max", func(arg string) (Filter, error) {
+		max, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("needs a number, e.g. beta-max=5")
+		}
+		return ColumnFilter{Reason: "beta_max", Column: "BETA", Float: func(v float64) bool { return math.Abs(v) > max }}, nil
+	})
+}
+
+// MissingFilter drops rows with a null in any canonical column but INFO,
+// which LDSC leaves out of its missing value check. Other columns, such as
+// those harmonise passes through, are not checked.
+type MissingFilter struct{}
+
+func (MissingFilter) Name() string      { return "missing" }
+func (MissingFilter) Columns() []string { return nil }
+
+func (MissingFilter) Apply(rec array.Record) (keep []bool, reasons []string) {
+	keep = make([]bool, rec.NumRows())
+	reasons = make([]string, rec.NumRows())
+	for i := range keep {
+		keep[i] = true
+	}
+	for j, col := range rec.Columns() {
+		if col.NullN() == 0 || rec.ColumnName(j) == "INFO" || !isCanonical(rec.ColumnName(j)) {
+			continue
+		}
+		for i := range keep {
+			if col.IsNull(i) {
+				keep[i] = false
+				reasons[i] = "missing"
+			}
+		}
+	}
+	return
+}
+
+func isCanonical(name string) bool {
+	for _, cnames := range []map[string]string{constants.Default_cnames, constants.Extended_cnames} {
+		for _, cname := range cnames {
+			if cname == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ColumnFilter drops the rows whose value in Column fails Float, for a
+// float64 column, or String, for a string column. A check that is nil
+// keeps every row, and nulls are left to MissingFilter.
+type ColumnFilter struct {
+	Reason string
+	Column string
+	Float  func(v float64) bool
+	String func(v string) bool
+}
+
+func (f ColumnFilter) Name() string      { return f.Reason }
+func (f ColumnFilter) Columns() []string { return []string{f.Column} }
+
+func (f ColumnFilter) Apply(rec array.Record) (keep []bool, reasons []string) {
+	keep = make([]bool, rec.NumRows())
+	reasons = make([]string, rec.NumRows())
+	col := rec.Column(rec.Schema().FieldIndices(f.Column)[0])
+	for i := range keep {
+		keep[i] = true
+	}
+	switch col.DataType().ID() {
+	case arrow.FLOAT64:
+		if f.Float == nil {
+			return
+		}
+		d := array.NewFloat64Data(col.Data())
+		defer d.Release()
+		for i, v := range d.Float64Values() {
+			if !d.IsNull(i) && f.Float(v) {
+				keep[i], reasons[i] = false, f.Reason
+			}
+		}
+	case arrow.STRING:
+		if f.String == nil {
+			return
+		}
+		d := array.NewStringData(col.Data())
+		defer d.Release()
+		for i := 0; i < d.Len(); i++ {
+			if !d.IsNull(i) && f.String(d.Value(i)) {
+				keep[i], reasons[i] = false, f.Reason
+			}
+		}
+	}
+	return
+}
+
+// Pipeline runs filters over a table. Each row is dropped for the first
+// filter that rejects it; filters reading a column the table lacks are
+// skipped.
+type Pipeline []Filter
+
+// Run returns the rows of table that pass every filter, recording the rest
+// in rejects. dropped counts the rows by reason.
+func (p Pipeline) Run(table array.Table, rejects *Rejects) (new_table array.Table, dropped map[string]int, err error) {
+	defer utils.TimeTrack(time.Now(), "Pipeline")
+
+	schema := table.Schema()
+	active := Pipeline{}
+	for _, f := range p {
+		ok := true
+		for _, col := range f.Columns() {
+			ok = ok && schema.HasField(col)
+		}
+		if ok {
+			active = append(active, f)
+		}
+	}
+
+	dropped = map[string]int{}
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	records := make([]array.Record, 0)
+	tr := array.NewTableReader(table, 10000)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.Record()
+		reasons := make([]string, rec.NumRows())
+		for _, f := range active {
+			keep, r := f.Apply(rec)
+			for i := range keep {
+				if keep[i] || reasons[i] != "" {
+					continue
+				}
+				reasons[i] = r[i]
+				if reasons[i] == "" {
+					reasons[i] = f.Name()
+				}
+				dropped[reasons[i]]++
+			}
+		}
+		if rejects != nil {
+			rejects.addRecord(rec, reasons)
+		}
+		keep := make([]bool, len(reasons))
+		for i, reason := range reasons {
+			keep[i] = reason == ""
+		}
+		new_rec, err := filterRecord(rec, keep, mem)
+		if err != nil {
+			return nil, nil, err
+		}
+		if new_rec != nil {
+			records = append(records, new_rec)
+		}
+	}
+	return array.NewTableFromRecords(schema, records), dropped, nil
+}
@@ -0,0 +1,97 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+)
+
+func findFilter(t *testing.T, filters []Filter, name string) ColumnFilter {
+	for _, f := range filters {
+		if f, ok := f.(ColumnFilter); ok && f.Name() == name {
+			return f
+		}
+	}
+	t.Fatalf("no %s filter", name)
+	return ColumnFilter{}
+}
+
+// The MAF and INFO bounds follow LDSC's munge_sumstats: frq > maf_min and
+// info >= info_min are kept.
+func TestDefaultFiltersBounds(t *testing.T) {
+	filters := DefaultFilters(QCOptions{MAFMin: 0.01, INFOMin: 0.9})
+	maf, info := findFilter(t, filters, "maf"), findFilter(t, filters, "info")
+	for _, c := range []struct {
+		f    ColumnFilter
+		v    float64
+		drop bool
+	}{
+		{maf, 0.01, true},
+		{maf, 0.995, true},
+		{maf, 0.0101, false},
+		{maf, 0.5, false},
+		{maf, 0, true},
+		{maf, 1.2, true},
+		{info, 0.9, false},
+		{info, 0.8999, true},
+		{info, 1.05, false},
+		{info, 2.1, true},
+	} {
+		if got := c.f.Float(c.v); got != c.drop {
+			t.Errorf("%s filter of %g drops %v, want %v", c.f.Reason, c.v, got, c.drop)
+		}
+	}
+}
+
+const filter_tsv = `SNP	A1	A2	P	FRQ	INFO
+rs1	A	G	0.5	0.3	0.95
+rs2	A	G	NA	0.3	0.95
+rs3	A	G	1.5	0.3	0.95
+rs4	A	G	0.5	0.005	0.95
+rs5	A	G	0.5	0.3	0.5
+rs6	A	GT	0.5	0.3	0.95
+rs7	c	t	0.2	0.6	NA
+rs8	A	G	2	0.001	0.1
+`
+
+func TestPipelineRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "flt.tsv")
+	if err := os.WriteFile(file, []byte(filter_tsv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"SNP", "A1", "A2", "P", "FRQ", "INFO"}
+	ctypes := map[string]arrow.DataType{
+		"SNP":  arrow.BinaryTypes.String,
+		"A1":   arrow.BinaryTypes.String,
+		"A2":   arrow.BinaryTypes.String,
+		"P":    arrow.PrimitiveTypes.Float64,
+		"FRQ":  arrow.PrimitiveTypes.Float64,
+		"INFO": arrow.PrimitiveTypes.Float64,
+	}
+	table, _ := ArrowCSV(file, header, '\t', ctypes)
+	if table == nil {
+		t.Fatal("could not read", file)
+	}
+	pipeline := Pipeline(DefaultFilters(QCOptions{MAFMin: 0.01, INFOMin: 0.9}))
+	new_table, dropped, err := pipeline.Run(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rs7 has no INFO, which does not make it missing. rs8 fails P, MAF
+	// and INFO and is counted once, under P.
+	if got := strings.Join(StringValues(new_table.Column(ColumnIndex(new_table, "SNP"))), ","); got != "rs1,rs7" {
+		t.Errorf("kept %s, want rs1,rs7", got)
+	}
+	want := map[string]int{"missing": 1, "p": 2, "maf": 1, "info": 1, "alleles": 1}
+	for reason, n := range want {
+		if dropped[reason] != n {
+			t.Errorf("dropped %d for %s, want %d", dropped[reason], reason, n)
+		}
+	}
+	if len(dropped) != len(want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+}
@@ -0,0 +1,222 @@
+package ops
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// GWASSSFMeta is the study metadata written to the GWAS-SSF YAML sidecar.
+type GWASSSFMeta struct {
+	GWASID         string
+	Trait          string
+	GenomeAssembly string
+}
+
+// gwasssf_chromosomes are the chromosome codes GWAS-SSF allows.
+var gwasssf_chromosomes = map[string]string{"X": "23", "Y": "24", "MT": "25"}
+
+// gwasssfColumn is an output column of a GWAS-SSF file.
+type gwasssfColumn struct {
+	name   string
+	format func(i int) string
+}
+
+// WriteGWASSSF writes table as a GWAS Catalog GWAS-SSF file sorted by
+// chromosome and position, and its metadata to file + "-meta.yaml". It
+// fails if a mandatory field is missing: CHR, BP, A1, A2, an effect size
+// (BETA, LOG_ODDS or OR), SE, FRQ and P or LOG10P.
+func WriteGWASSSF(table array.Table, file string, meta GWASSSFMeta) error {
+	defer utils.TimeTrack(time.Now(), "WriteGWASSSF")
+
+	if meta.GenomeAssembly == "" {
+		return fmt.Errorf("GWAS-SSF metadata needs a genome assembly")
+	}
+	missing := []string{}
+	for _, col := range []string{"CHR", "BP", "A1", "A2", "SE", "FRQ"} {
+		if ColumnIndex(table, col) < 0 {
+			missing = append(missing, col)
+		}
+	}
+	effect := ""
+	for _, col := range []string{"BETA", "LOG_ODDS", "OR"} {
+		if effect == "" && ColumnIndex(table, col) >= 0 {
+			effect = col
+		}
+	}
+	if effect == "" {
+		missing = append(missing, "BETA, LOG_ODDS or OR")
+	}
+	// LOG10P is only present if the input had it or P underflowed, so it is
+	// preferred to P
+	pcol := ""
+	for _, col := range []string{"LOG10P", "P"} {
+		if pcol == "" && ColumnIndex(table, col) >= 0 {
+			pcol = col
+		}
+	}
+	if pcol == "" {
+		missing = append(missing, "P or LOG10P")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("GWAS-SSF output needs %s", strings.Join(missing, ", "))
+	}
+
+	chr := StringValues(table.Column(ColumnIndex(table, "CHR")))
+	bp := Float64Values(table.Column(ColumnIndex(table, "BP")))
+	order := positionOrder(chr, bp)
+	for i, c := range chr {
+		if code, ok := gwasssf_chromosomes[c]; ok {
+			chr[i] = code
+		} else if v, err := strconv.Atoi(c); err != nil || v < 1 || v > 22 {
+			return fmt.Errorf("GWAS-SSF does not allow chromosome %s", c)
+		}
+	}
+
+	text := func(col string) func(i int) string {
+		values := StringValues(table.Column(ColumnIndex(table, col)))
+		return func(i int) string {
+			return strings.ToUpper(values[i])
+		}
+	}
+	number := func(col string) func(i int) string {
+		values := Float64Values(table.Column(ColumnIndex(table, col)))
+		return func(i int) string {
+			if math.IsNaN(values[i]) {
+				return "#NA"
+			}
+			return FormatFloat(values[i])
+		}
+	}
+	effect_name := map[string]string{"BETA": "beta", "LOG_ODDS": "beta", "OR": "odds_ratio"}[effect]
+	p_name := map[string]string{"LOG10P": "neg_log_10_p_value", "P": "p_value"}[pcol]
+	p_format := number(pcol)
+	if table.Column(ColumnIndex(table, pcol)).DataType().ID() != arrow.FLOAT64 {
+		p_format = text(pcol)
+	}
+	columns := []gwasssfColumn{
+		{"chromosome", func(i int) string { return chr[i] }},
+		{"base_pair_location", number("BP")},
+		{"effect_allele", text("A1")},
+		{"other_allele", text("A2")},
+		{effect_name, number(effect)},
+		{"standard_error", number("SE")},
+		{"effect_allele_frequency", number("FRQ")},
+		{p_name, p_format},
+	}
+	if ColumnIndex(table, "SNP") >= 0 {
+		snps := StringValues(table.Column(ColumnIndex(table, "SNP")))
+		columns = append(columns, gwasssfColumn{"rsid", func(i int) string {
+			if !strings.HasPrefix(strings.ToLower(snps[i]), "rs") {
+				return "#NA"
+			}
+			return snps[i]
+		}})
+	}
+	for _, col := range []string{"INFO", "N"} {
+		if ColumnIndex(table, col) >= 0 {
+			columns = append(columns, gwasssfColumn{strings.ToLower(col), number(col)})
+		}
+	}
+
+	mandatory := columns[:8]
+	for _, c := range mandatory {
+		nmissing := 0
+		for i := range order {
+			if v := c.format(i); v == "#NA" || v == "" {
+				nmissing++
+			}
+		}
+		if nmissing > 0 {
+			return fmt.Errorf("GWAS-SSF does not allow missing values in %s: %d rows", c.name, nmissing)
+		}
+	}
+
+	f, err := parse.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	row := make([]string, len(columns))
+	for k, c := range columns {
+		row[k] = c.name
+	}
+	fmt.Fprintln(w, strings.Join(row, "\t"))
+	for _, i := range order {
+		for k, c := range columns {
+			row[k] = c.format(i)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return writeGWASSSFMeta(file, meta)
+}
+
+// writeGWASSSFMeta writes the metadata YAML of the GWAS-SSF file, with the
+// MD5 checksum of the file as written.
+func writeGWASSSFMeta(file string, meta GWASSSFMeta) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	y, err := os.Create(file + "-meta.yaml")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(y)
+	fmt.Fprintln(w, "# Study meta-data")
+	fmt.Fprintf(w, "date_metadata_last_modified: %s\n", time.Now().Format("2006-01-02"))
+	if meta.GWASID != "" {
+		fmt.Fprintf(w, "gwas_id: %s\n", yamlString(meta.GWASID))
+	}
+	if meta.Trait != "" {
+		fmt.Fprintf(w, "trait_description:\n  - %s\n", yamlString(meta.Trait))
+	}
+	fmt.Fprintln(w, "# Summary statistic information")
+	fmt.Fprintf(w, "data_file_name: %s\n", yamlString(filepath.Base(file)))
+	fmt.Fprintln(w, "file_type: GWAS-SSF v1.0")
+	fmt.Fprintf(w, "data_file_md5sum: %s\n", hex.EncodeToString(h.Sum(nil)))
+	fmt.Fprintln(w, "is_harmonised: false")
+	fmt.Fprintln(w, "is_sorted: true")
+	fmt.Fprintf(w, "genome_assembly: %s\n", yamlString(meta.GenomeAssembly))
+	fmt.Fprintln(w, "coordinate_system: 1-based")
+	if err := w.Flush(); err != nil {
+		y.Close()
+		return err
+	}
+	return y.Close()
+}
+
+// yamlString quotes s if it would not read back as the same plain string.
+func yamlString(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#'\"{}[],&*!|>%@`") || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package ops
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+func gwasssfTable(t *testing.T) array.Table {
+	table := readTestTSV(t, "SNP\tCHR\tBP\tA1\tA2\tBETA\tSE\tFRQ\tP\tN\n"+
+		"rs2\t0\t200\t0\t0\t-0.05\t0.02\t0.2\t0.01\t900\n"+
+		"1:100\t0\t100\t0\t0\t0.1\t0.02\t0.3\t1e-06\t1000\n"+
+		"rs3\t0\t50\t0\t0\t0.01\t0.02\t0.4\t0.6\t1000\n")
+	table = SetStringColumn(table, "CHR", []string{"1", "1", "X"})
+	table = SetStringColumn(table, "A1", []string{"t", "G", "A"})
+	return SetStringColumn(table, "A2", []string{"C", "A", "G"})
+}
+
+func TestWriteGWASSSF(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "study.tsv.gz")
+	meta := GWASSSFMeta{GWASID: "GCST1", Trait: "Height: adult", GenomeAssembly: "GRCh37"}
+	if err := WriteGWASSSF(gwasssfTable(t), file, meta); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parse.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sorted by position, with X coded as 23 and IDs that are not rsIDs
+	// missing
+	want := "chromosome\tbase_pair_location\teffect_allele\tother_allele\tbeta\tstandard_error\teffect_allele_frequency\tp_value\trsid\tn\n" +
+		"1\t100\tG\tA\t0.1\t0.02\t0.3\t1e-06\t#NA\t1000\n" +
+		"1\t200\tT\tC\t-0.05\t0.02\t0.2\t0.01\trs2\t900\n" +
+		"23\t50\tA\tG\t0.01\t0.02\t0.4\t0.6\trs3\t1000\n"
+	if string(data) != want {
+		t.Errorf("wrote\n%s\nwant\n%s", data, want)
+	}
+
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(raw)
+	yaml, err := os.ReadFile(file + "-meta.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"data_file_md5sum: " + hex.EncodeToString(sum[:]),
+		"data_file_name: study.tsv.gz",
+		"gwas_id: GCST1",
+		`  - "Height: adult"`,
+		"genome_assembly: GRCh37",
+		"file_type: GWAS-SSF v1.0",
+	} {
+		if !strings.Contains(string(yaml), line+"\n") {
+			t.Errorf("metadata has no line %q:\n%s", line, yaml)
+		}
+	}
+}
+
+func TestWriteGWASSSFMandatory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "study.tsv.gz")
+	table := gwasssfTable(t)
+	for _, c := range []struct {
+		name  string
+		table array.Table
+		meta  GWASSSFMeta
+		err   string
+	}{
+		{"no assembly", table, GWASSSFMeta{}, "genome assembly"},
+		{"no SE and FRQ", DropColumns(table, []string{"SE", "FRQ"}), GWASSSFMeta{GenomeAssembly: "GRCh38"}, "needs SE, FRQ"},
+		{"no effect", DropColumns(table, []string{"BETA"}), GWASSSFMeta{GenomeAssembly: "GRCh38"}, "BETA, LOG_ODDS or OR"},
+		{"no P", DropColumns(table, []string{"P"}), GWASSSFMeta{GenomeAssembly: "GRCh38"}, "P or LOG10P"},
+		{"missing SE", SetFloat64Column(table, "SE", []float64{0.02, math.NaN(), 0.02}), GWASSSFMeta{GenomeAssembly: "GRCh38"}, "missing values in standard_error: 1 rows"},
+		{"bad chromosome", SetStringColumn(table, "CHR", []string{"1", "1", "6_cox_hap2"}), GWASSSFMeta{GenomeAssembly: "GRCh38"}, "chromosome 6_COX_HAP2"},
+	} {
+		err := WriteGWASSSF(c.table, file, c.meta)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
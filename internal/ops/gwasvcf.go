@@ -0,0 +1,418 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// GWAS-VCF FORMAT fields and the canonical columns they hold. ALT is the
+// effect allele, so A1 is ALT and A2 is REF.
+var gwasvcf_fields = []struct {
+	id          string
+	col         string
+	description string
+}{
+	{"ES", "BETA", "Effect size estimate relative to the alternative allele"},
+	{"SE", "SE", "Standard error of effect size estimate"},
+	{"LP", "LOG10P", "-log10 p-value for effect estimate"},
+	{"AF", "FRQ", "Alternate allele frequency in the association study"},
+	{"SS", "N", "Sample size used to estimate genetic effect"},
+	{"EZ", "Z", "Z-score provided if it was used to derive the EFFECT and SE fields"},
+	{"NC", "N_CAS", "Number of cases used to estimate genetic effect"},
+}
+
+// GWASVCFMeta is the study metadata written to the GWAS-VCF header.
+type GWASVCFMeta struct {
+	Sample string
+	Trait  string
+	// VariantsNotRead are the input variants left out of the file.
+	VariantsNotRead int
+	// Harmonised is set by harmonise, with the variants it aligned to the
+	// reference and the number of those whose alleles it swapped.
+	Harmonised         bool
+	HarmonisedVariants int
+	SwitchedAlleles    int
+}
+
+// gwasvcf_meta are the ##META declarations of the ##SAMPLE keys.
+var gwasvcf_meta = []struct {
+	id          string
+	kind        string
+	description string
+}{
+	{"TotalVariants", "Integer", "Total number of variants in input"},
+	{"VariantsNotRead", "Integer", "Number of variants that could not be read"},
+	{"HarmonisedVariants", "Integer", "Total number of harmonised variants"},
+	{"VariantsNotHarmonised", "Integer", "Total number of variants that could not be harmonised"},
+	{"SwitchedAlleles", "Integer", "Total number of variants strand switched"},
+	{"TotalControls", "Float", "Total number of controls in the association study"},
+	{"TotalCases", "Float", "Total number of cases in the association study"},
+	{"StudyType", "String", "Type of GWAS study [Continuous or CaseControl]"},
+}
+
+// ReadGWASVCF reads a GWAS-VCF into a table with canonical column names. A
+// file with several samples needs sample to choose one. FORMAT fields that
+// the header declares but no record has a value for, such as the NC and EZ
+// of OpenGWAS files, are left out rather than read as all-null columns.
+// sel, if not nil, picks the records kept as they are read.
+func ReadGWASVCF(file string, sample string, sel *RowSelect) (table array.Table, err error) {
+	defer utils.TimeTrack(time.Now(), "ReadGWASVCF")
+
+	f, err := parse.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	declared := map[string]bool{}
+	sample_col := -1
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(text, "##FORMAT=<ID=") {
+			id := strings.TrimPrefix(text, "##FORMAT=<ID=")
+			declared[id[:strings.IndexAny(id+",", ",>")]] = true
+			continue
+		}
+		if strings.HasPrefix(text, "#CHROM") {
+			names := strings.Split(text, "\t")
+			if len(names) < 10 {
+				return nil, fmt.Errorf("%s has no samples", file)
+			}
+			samples := names[9:]
+			switch {
+			case sample != "":
+				for i, s := range samples {
+					if s == sample {
+						sample_col = 9 + i
+					}
+				}
+				if sample_col < 0 {
+					return nil, fmt.Errorf("%s has no sample %s; samples are %s", file, sample, strings.Join(samples, ", "))
+				}
+			case len(samples) > 1:
+				return nil, fmt.Errorf("%s has %d samples, choose one with --sample: %s", file, len(samples), strings.Join(samples, ", "))
+			default:
+				sample_col = 9
+			}
+			break
+		}
+		if !strings.HasPrefix(text, "#") {
+			return nil, fmt.Errorf("%s line %d: data before the #CHROM header", file, line)
+		}
+	}
+	if sample_col < 0 {
+		return nil, fmt.Errorf("%s has no #CHROM header", file)
+	}
+
+	fields := []arrow.Field{
+		{Name: "SNP", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "CHR", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "BP", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "A1", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "A2", Type: arrow.BinaryTypes.String, Nullable: true},
+	}
+	ids := []string{}
+	for _, fd := range gwasvcf_fields {
+		if declared[fd.id] {
+			ids = append(ids, fd.id)
+			fields = append(fields, arrow.Field{Name: fd.col, Type: arrow.PrimitiveTypes.Float64, Nullable: true})
+		}
+	}
+	schema := arrow.NewSchema(fields, nil)
+	out := schema
+	if sel != nil {
+		out = sel.schema(schema)
+	}
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+	records := make([]array.Record, 0)
+	add := func() error {
+		rec := b.NewRecord()
+		if sel != nil {
+			kept, err := sel.apply(rec, out, mem)
+			rec.Release()
+			if err != nil || kept == nil {
+				return err
+			}
+			rec = kept
+		}
+		records = append(records, rec)
+		return nil
+	}
+	nrows := 0
+	for scanner.Scan() {
+		line++
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) <= sample_col {
+			return nil, fmt.Errorf("%s line %d: expected %d columns", file, line, sample_col+1)
+		}
+		pos, err := strconv.ParseFloat(cols[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", file, line, err)
+		}
+		id := cols[2]
+		if id == "." {
+			id = cols[0] + ":" + cols[1]
+		}
+		b.Field(0).(*array.StringBuilder).Append(id)
+		b.Field(1).(*array.StringBuilder).Append(cols[0])
+		b.Field(2).(*array.Float64Builder).Append(pos)
+		b.Field(3).(*array.StringBuilder).Append(cols[4])
+		b.Field(4).(*array.StringBuilder).Append(cols[3])
+		values := map[string]string{}
+		keys := strings.Split(cols[8], ":")
+		for i, v := range strings.Split(cols[sample_col], ":") {
+			if i < len(keys) {
+				values[keys[i]] = v
+			}
+		}
+		for i, id := range ids {
+			fb := b.Field(5 + i).(*array.Float64Builder)
+			if v, err := strconv.ParseFloat(values[id], 64); err == nil {
+				fb.Append(v)
+			} else {
+				fb.AppendNull()
+			}
+		}
+		nrows++
+		if nrows%200 == 0 {
+			if err := add(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if nrows%200 != 0 {
+		if err := add(); err != nil {
+			return nil, err
+		}
+	}
+	table = array.NewTableFromRecords(out, records)
+	absent := []string{}
+	for i := range ids {
+		if col := table.Column(5 + i); col.NullN() == col.Len() {
+			absent = append(absent, fields[5+i].Name)
+		}
+	}
+	if len(absent) > 0 {
+		table = DropColumns(table, absent)
+	}
+	return table, nil
+}
+
+// WriteGWASVCF writes table as a single sample GWAS-VCF sorted by
+// chromosome and position. It needs CHR, BP, A1 and A2; the effect size is
+// taken from BETA, LOG_ODDS or log(OR), and LP from LOG10P or P.
+func WriteGWASVCF(table array.Table, file string, meta GWASVCFMeta) error {
+	defer utils.TimeTrack(time.Now(), "WriteGWASVCF")
+
+	for _, col := range []string{"CHR", "BP", "A1", "A2"} {
+		if ColumnIndex(table, col) < 0 {
+			return fmt.Errorf("GWAS-VCF output needs a %s column", col)
+		}
+	}
+	n := int(table.NumRows())
+	chr := StringValues(table.Column(ColumnIndex(table, "CHR")))
+	bp := Float64Values(table.Column(ColumnIndex(table, "BP")))
+	a1 := StringValues(table.Column(ColumnIndex(table, "A1")))
+	a2 := StringValues(table.Column(ColumnIndex(table, "A2")))
+	snp := make([]string, n)
+	if i := ColumnIndex(table, "SNP"); i >= 0 {
+		snp = StringValues(table.Column(i))
+	}
+
+	values := map[string][]float64{}
+	for _, fd := range gwasvcf_fields {
+		if i := ColumnIndex(table, fd.col); i >= 0 {
+			values[fd.id] = Float64Values(table.Column(i))
+		}
+	}
+	if values["ES"] == nil {
+		for _, col := range []string{"LOG_ODDS", "OR"} {
+			if i := ColumnIndex(table, col); i >= 0 {
+				values["ES"] = Float64Values(table.Column(i))
+				if col == "OR" {
+					for j, v := range values["ES"] {
+						values["ES"][j] = math.Log(v)
+					}
+				}
+				break
+			}
+		}
+	}
+	if values["LP"] == nil {
+		if i := ColumnIndex(table, "P"); i >= 0 {
+			values["LP"] = Log10PValues(table.Column(i))
+		}
+	}
+	ids := []string{}
+	for _, fd := range gwasvcf_fields {
+		if values[fd.id] != nil {
+			ids = append(ids, fd.id)
+		}
+	}
+
+	order := positionOrder(chr, bp)
+
+	f, err := parse.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	writeGWASVCFHeader(w, ids, meta, n, maxColumn(table, "N_CAS"), maxColumn(table, "N_CON"))
+	format := strings.Join(ids, ":")
+	sample := make([]string, len(ids))
+	for _, i := range order {
+		for k, id := range ids {
+			sample[k] = "."
+			if v := values[id][i]; !math.IsNaN(v) {
+				sample[k] = FormatFloat(v)
+			}
+		}
+		id := snp[i]
+		if id == "" {
+			id = "."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t.\tPASS\t.\t%s\t%s\n",
+			chr[i], FormatFloat(bp[i]), id, strings.ToUpper(a2[i]), strings.ToUpper(a1[i]), format, strings.Join(sample, ":"))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeGWASVCFHeader writes the header of n variants; cases and controls
+// are NaN for a study without them.
+func writeGWASVCFHeader(w io.Writer, ids []string, meta GWASVCFMeta, n int, cases float64, controls float64) {
+	fmt.Fprintln(w, "##fileformat=VCFv4.2")
+	fmt.Fprintln(w, "##fileDate="+time.Now().Format("20060102"))
+	fmt.Fprintln(w, "##source=golink")
+	fmt.Fprintln(w, `##FILTER=<ID=PASS,Description="All filters passed">`)
+	for _, fd := range gwasvcf_fields {
+		if utils.InList(fd.id, ids) {
+			fmt.Fprintf(w, "##FORMAT=<ID=%s,Number=A,Type=Float,Description=\"%s\">\n", fd.id, fd.description)
+		}
+	}
+	sample := meta.Sample
+	if sample == "" {
+		sample = "golink"
+	}
+	values := map[string]string{
+		"TotalVariants":   strconv.Itoa(n + meta.VariantsNotRead),
+		"VariantsNotRead": strconv.Itoa(meta.VariantsNotRead),
+		"StudyType":       "Continuous",
+	}
+	if meta.Harmonised {
+		values["HarmonisedVariants"] = strconv.Itoa(meta.HarmonisedVariants)
+		values["VariantsNotHarmonised"] = strconv.Itoa(n - meta.HarmonisedVariants)
+		values["SwitchedAlleles"] = strconv.Itoa(meta.SwitchedAlleles)
+	}
+	if !math.IsNaN(cases) {
+		values["StudyType"] = "CaseControl"
+		values["TotalCases"] = FormatFloat(cases)
+		if !math.IsNaN(controls) {
+			values["TotalControls"] = FormatFloat(controls)
+		}
+	}
+	fields := []string{"ID=" + sample}
+	for _, m := range gwasvcf_meta {
+		if v, ok := values[m.id]; ok {
+			fmt.Fprintf(w, "##META=<ID=%s,Number=.,Type=%s,Description=\"%s\">\n", m.id, m.kind, m.description)
+			fields = append(fields, m.id+"="+v)
+		}
+	}
+	if meta.Trait != "" {
+		fields = append(fields, fmt.Sprintf("TraitName=\"%s\"", strings.ReplaceAll(meta.Trait, `"`, `'`)))
+	}
+	fmt.Fprintf(w, "##SAMPLE=<%s>\n", strings.Join(fields, ","))
+	fmt.Fprintf(w, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\t%s\n", sample)
+}
+
+// maxColumn returns the largest value of the named column, or NaN if table
+// has no such column or it is all null.
+func maxColumn(table array.Table, name string) float64 {
+	max := math.NaN()
+	if i := ColumnIndex(table, name); i >= 0 {
+		for _, v := range Float64Values(table.Column(i)) {
+			if v > max || math.IsNaN(max) {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+// positionOrder normalises chr in place and returns the row order sorted by
+// chromosome, in the order of constants.Chromosomes, then by position.
+func positionOrder(chr []string, bp []float64) []int {
+	chr_order := map[string]int{}
+	for i, c := range constants.Chromosomes {
+		chr_order[c] = i
+	}
+	order := make([]int, len(chr))
+	for i := range order {
+		chr[i] = parse.NormalizeCHR(chr[i])
+		order[i] = i
+	}
+	rank := func(c string) int {
+		if r, ok := chr_order[c]; ok {
+			return r
+		}
+		return len(constants.Chromosomes)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		ri, rj := rank(chr[order[i]]), rank(chr[order[j]])
+		if ri != rj {
+			return ri < rj
+		}
+		if chr[order[i]] != chr[order[j]] {
+			return chr[order[i]] < chr[order[j]]
+		}
+		return bp[order[i]] < bp[order[j]]
+	})
+	return order
+}
+
+// Log10PValues returns -log10(P) of a P column stored as float64 or as text.
+func Log10PValues(col *array.Column) []float64 {
+	if col.DataType().ID() == arrow.FLOAT64 {
+		values := Float64Values(col)
+		for i, v := range values {
+			values[i] = 0 - math.Log10(v)
+		}
+		return values
+	}
+	text := StringValues(col)
+	values := make([]float64, len(text))
+	for i, s := range text {
+		_, log10p, err := parse.ParseP(s)
+		if err != nil {
+			log10p = math.NaN()
+		}
+		values[i] = log10p
+	}
+	return values
+}
@@ -0,0 +1,144 @@
+package ops
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// OpenGWAS files declare NC and EZ in every header, whether or not the
+// records carry them.
+const opengwas_vcf = `##fileformat=VCFv4.2
+##FORMAT=<ID=ES,Number=A,Type=Float,Description="Effect size estimate relative to the alternative allele">
+##FORMAT=<ID=SE,Number=A,Type=Float,Description="Standard error of effect size estimate">
+##FORMAT=<ID=LP,Number=A,Type=Float,Description="-log10 p-value for effect estimate">
+##FORMAT=<ID=AF,Number=A,Type=Float,Description="Alternate allele frequency in the association study">
+##FORMAT=<ID=SS,Number=A,Type=Float,Description="Sample size used to estimate genetic effect">
+##FORMAT=<ID=EZ,Number=A,Type=Float,Description="Z-score provided if it was used to derive the EFFECT and SE fields">
+##FORMAT=<ID=SI,Number=A,Type=Float,Description="Accuracy score of summary data imputation">
+##FORMAT=<ID=NC,Number=A,Type=Float,Description="Number of cases used to estimate genetic effect">
+##FORMAT=<ID=ID,Number=1,Type=String,Description="Study variant identifier">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	ieu-a-2
+1	10000	rs1	A	G	.	PASS	.	ES:SE:LP:AF:SS	0.1:0.02:5.3:0.3:10000
+1	20000	rs2	C	T	.	PASS	.	ES:SE:LP:AF:SS	-0.05:0.02:1.9:0.2:10000
+2	30000	.	G	A	.	PASS	.	ES:SE:LP:AF:SS:NC	0.01:0.02:0.2:0.4:10000:.
+`
+
+func TestReadGWASVCFUndeclaredFields(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "opengwas.vcf")
+	if err := os.WriteFile(file, []byte(opengwas_vcf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := ReadGWASVCF(file, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.NumRows() != 3 {
+		t.Fatalf("read %d rows, want 3", table.NumRows())
+	}
+	cols := []string{}
+	for _, f := range table.Schema().Fields() {
+		cols = append(cols, f.Name)
+	}
+	if got, want := strings.Join(cols, ","), "SNP,CHR,BP,A1,A2,BETA,SE,LOG10P,FRQ,N"; got != want {
+		t.Errorf("columns %s, want %s", got, want)
+	}
+	if snps := StringValues(table.Column(0)); snps[2] != "2:30000" {
+		t.Errorf("SNP of ID . is %s, want 2:30000", snps[2])
+	}
+
+	cnames := map[string]string{}
+	for _, c := range cols {
+		cnames[c] = c
+	}
+	parsed, dropped, err := ParseDataframe(table, cnames, DefaultFilters(QCOptions{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.NumRows() != 3 {
+		t.Errorf("%d rows pass QC, want 3; dropped %v", parsed.NumRows(), dropped)
+	}
+}
+
+func TestGWASVCFRoundTrip(t *testing.T) {
+	table := readTestTSV(t, "SNP\tCHR\tBP\tA1\tA2\tBETA\tSE\tP\tFRQ\tN\tN_CAS\tN_CON\n"+
+		"rs2\t0\t200\t0\t0\t-0.05\t0.02\t0.0125\t0.2\t900\t300\t600\n"+
+		"rs3\t0\t50\t0\t0\t0.01\t0.02\t0.6\t0.4\t1000\t400\t600\n"+
+		"rs1\t0\t100\t0\t0\t0.1\t0.02\t1e-06\t0.3\t1000\t400\t600\n")
+	table = SetStringColumn(table, "CHR", []string{"1", "2", "1"})
+	table = SetStringColumn(table, "A1", []string{"t", "A", "G"})
+	table = SetStringColumn(table, "A2", []string{"C", "G", "A"})
+	file := filepath.Join(t.TempDir(), "out.vcf.gz")
+	meta := GWASVCFMeta{Sample: "study1", Trait: `Height "cm"`, VariantsNotRead: 2}
+	if err := WriteGWASVCF(table, file, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parse.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`##META=<ID=TotalCases,Number=.,Type=Float,Description="Total number of cases in the association study">`,
+		`##SAMPLE=<ID=study1,TotalVariants=5,VariantsNotRead=2,TotalControls=600,TotalCases=400,StudyType=CaseControl,TraitName="Height 'cm'">`,
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tstudy1",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("header has no line %s", line)
+		}
+	}
+
+	got, err := ReadGWASVCF(file, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the records are sorted by position
+	if snps := StringValues(got.Column(ColumnIndex(got, "SNP"))); !reflect.DeepEqual(snps, []string{"rs1", "rs2", "rs3"}) {
+		t.Errorf("SNPs %v", snps)
+	}
+	for name, want := range map[string][]string{"CHR": {"1", "1", "2"}, "A1": {"G", "T", "A"}, "A2": {"A", "C", "G"}} {
+		if values := StringValues(got.Column(ColumnIndex(got, name))); !reflect.DeepEqual(values, want) {
+			t.Errorf("%s %v, want %v", name, values, want)
+		}
+	}
+	for name, want := range map[string][]float64{
+		"BP":     {100, 200, 50},
+		"BETA":   {0.1, -0.05, 0.01},
+		"SE":     {0.02, 0.02, 0.02},
+		"LOG10P": {6, -math.Log10(0.0125), -math.Log10(0.6)},
+		"FRQ":    {0.3, 0.2, 0.4},
+		"N":      {1000, 900, 1000},
+		"N_CAS":  {400, 300, 400},
+	} {
+		i := ColumnIndex(got, name)
+		if i < 0 {
+			t.Errorf("no %s column", name)
+			continue
+		}
+		for j, v := range Float64Values(got.Column(i)) {
+			if math.Abs(v-want[j]) > 1e-9 {
+				t.Errorf("%s of row %d is %g, want %g", name, j+1, v, want[j])
+			}
+		}
+	}
+}
+
+func TestGWASVCFHeaderHarmonised(t *testing.T) {
+	var b strings.Builder
+	writeGWASVCFHeader(&b, []string{"ES"}, GWASVCFMeta{Harmonised: true, HarmonisedVariants: 8, SwitchedAlleles: 3}, 10, math.NaN(), math.NaN())
+	want := "##SAMPLE=<ID=golink,TotalVariants=10,VariantsNotRead=0,HarmonisedVariants=8,VariantsNotHarmonised=2,SwitchedAlleles=3,StudyType=Continuous>\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("header\n%s\nhas no line %s", b.String(), want)
+	}
+}
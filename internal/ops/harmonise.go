@@ -0,0 +1,196 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// Harmonisation action codes.
+const (
+	ActionAligned        = "aligned"
+	ActionSwapped        = "swapped"
+	ActionFlipped        = "flipped"
+	ActionFlippedSwapped = "flipped_swapped"
+	ActionAmbiguous      = "ambiguous"
+	ActionMismatch       = "mismatch"
+	ActionMissing        = "missing"
+)
+
+// VariantAlleles are the REF and ALT alleles of a VCF record.
+type VariantAlleles struct {
+	Ref string
+	Alt []string
+}
+
+// VariantKey identifies a variant by chromosome and position.
+func VariantKey(chr string, pos int) string {
+	return fmt.Sprintf("%s:%d", parse.NormalizeCHR(chr), pos)
+}
+
+// ReadVCFAlleles reads the alleles of the records in a VCF whose key is in
+// keys, so only the variants of interest are held in memory. The key is the
+// ID column if byID, otherwise VariantKey of CHROM and POS.
+func ReadVCFAlleles(file string, keys map[string]bool, byID bool) (map[string][]VariantAlleles, error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	alleles := map[string][]VariantAlleles{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(text, "#") || text == "" {
+			continue
+		}
+		// only the first five columns are needed
+		fields := strings.SplitN(text, "\t", 6)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("%s line %d: expected at least 5 columns", file, line)
+		}
+		key := fields[2]
+		if !byID {
+			pos := 0
+			if _, err := fmt.Sscan(fields[1], &pos); err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", file, line, err)
+			}
+			key = VariantKey(fields[0], pos)
+		}
+		if !keys[key] {
+			continue
+		}
+		alleles[key] = append(alleles[key], VariantAlleles{
+			Ref: strings.ToUpper(fields[3]),
+			Alt: strings.Split(strings.ToUpper(fields[4]), ","),
+		})
+	}
+	return alleles, scanner.Err()
+}
+
+// reverseComplement returns the allele on the other strand, or "" if it is
+// not made of A, C, G and T.
+func reverseComplement(allele string) string {
+	b := make([]byte, len(allele))
+	for i := range allele {
+		c, ok := complement[string(allele[len(allele)-1-i])]
+		if !ok {
+			return ""
+		}
+		b[i] = c[0]
+	}
+	return string(b)
+}
+
+// harmoniseAlleles returns the action aligning a1 (effect) and a2 (other) to
+// ALT and REF, and the REF and ALT it matched.
+func harmoniseAlleles(a1 string, a2 string, candidates []VariantAlleles) (action string, ref string, alt string) {
+	if len(candidates) == 0 {
+		return ActionMissing, "", ""
+	}
+	rc1, rc2 := reverseComplement(a1), reverseComplement(a2)
+	palindromic := rc1 != "" && rc1 == a2
+	for _, c := range candidates {
+		for _, alt := range c.Alt {
+			switch {
+			case palindromic && ((a1 == alt && a2 == c.Ref) || (a1 == c.Ref && a2 == alt)):
+				return ActionAmbiguous, c.Ref, alt
+			case a1 == alt && a2 == c.Ref:
+				return ActionAligned, c.Ref, alt
+			case a1 == c.Ref && a2 == alt:
+				return ActionSwapped, c.Ref, alt
+			case rc1 != "" && rc1 == alt && rc2 == c.Ref:
+				return ActionFlipped, c.Ref, alt
+			case rc1 != "" && rc1 == c.Ref && rc2 == alt:
+				return ActionFlippedSwapped, c.Ref, alt
+			}
+		}
+	}
+	return ActionMismatch, "", ""
+}
+
+// Harmonise aligns the A1 and A2 of each row of table to the ALT and REF of
+// the matching variant in ref, keyed by SNP if byID or else by CHR and BP.
+// Swapped rows have Z, BETA, LOG_ODDS and SIGNED_SUMSTAT negated, OR
+// inverted and FRQ replaced by 1 - FRQ; strand flipped rows are relabelled.
+// The action taken is written to an ACTION column. Ambiguous, mismatched
+// and missing rows are left unchanged, or dropped if drop is set.
+func Harmonise(table array.Table, ref map[string][]VariantAlleles, byID bool, drop bool) (new_table array.Table, counts map[string]int, err error) {
+	defer utils.TimeTrack(time.Now(), "Harmonise")
+
+	keys := HarmoniseKeys(table, byID)
+	a1s := StringValues(table.Column(ColumnIndex(table, "A1")))
+	a2s := StringValues(table.Column(ColumnIndex(table, "A2")))
+	actions := make([]string, len(keys))
+	keep := make([]bool, len(keys))
+	swapped := make([]bool, len(keys))
+	counts = map[string]int{}
+	for i, key := range keys {
+		action, r, alt := harmoniseAlleles(strings.ToUpper(a1s[i]), strings.ToUpper(a2s[i]), ref[key])
+		actions[i] = action
+		counts[action]++
+		switch action {
+		case ActionAligned, ActionSwapped, ActionFlipped, ActionFlippedSwapped:
+			a1s[i], a2s[i] = alt, r
+			swapped[i] = action == ActionSwapped || action == ActionFlippedSwapped
+			keep[i] = true
+		default:
+			keep[i] = !drop
+		}
+	}
+
+	new_table = SetStringColumn(SetStringColumn(table, "A1", a1s), "A2", a2s)
+	for _, name := range []string{"Z", "BETA", "LOG_ODDS", "SIGNED_SUMSTAT", "OR", "FRQ"} {
+		idx := ColumnIndex(new_table, name)
+		if idx < 0 {
+			continue
+		}
+		values := Float64Values(new_table.Column(idx))
+		for i := range values {
+			if !swapped[i] {
+				continue
+			}
+			switch name {
+			case "OR":
+				values[i] = 1 / values[i]
+			case "FRQ":
+				values[i] = 1 - values[i]
+			default:
+				values[i] = -values[i]
+			}
+		}
+		new_table = SetFloat64Column(new_table, name, values)
+	}
+	new_table = SetStringColumn(new_table, "ACTION", actions)
+	if drop {
+		new_table, err = FilterRows(new_table, keep)
+	}
+	return
+}
+
+// HarmoniseKeys returns the key Harmonise looks each row of table up by.
+// Rows with a null BP get an empty key, which matches no variant.
+func HarmoniseKeys(table array.Table, byID bool) []string {
+	if byID {
+		return StringValues(table.Column(ColumnIndex(table, "SNP")))
+	}
+	chr := StringValues(table.Column(ColumnIndex(table, "CHR")))
+	bp := Float64Values(table.Column(ColumnIndex(table, "BP")))
+	keys := make([]string, len(chr))
+	for i := range keys {
+		if math.IsNaN(bp[i]) {
+			continue
+		}
+		keys[i] = VariantKey(chr[i], int(bp[i]))
+	}
+	return keys
+}
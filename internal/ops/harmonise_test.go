@@ -0,0 +1,84 @@
+package ops
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHarmoniseAlleles(t *testing.T) {
+	ref := []VariantAlleles{{Ref: "A", Alt: []string{"G"}}}
+	for _, c := range []struct {
+		a1, a2     string
+		candidates []VariantAlleles
+		want       string
+	}{
+		{"G", "A", ref, ActionAligned},
+		{"A", "G", ref, ActionSwapped},
+		{"C", "T", ref, ActionFlipped},
+		{"T", "C", ref, ActionFlippedSwapped},
+		{"A", "T", []VariantAlleles{{Ref: "T", Alt: []string{"A"}}}, ActionAmbiguous},
+		{"A", "C", ref, ActionMismatch},
+		{"G", "A", nil, ActionMissing},
+		// the second ALT of a multi-allelic record
+		{"C", "A", []VariantAlleles{{Ref: "A", Alt: []string{"G", "C"}}}, ActionAligned},
+	} {
+		if got, _, _ := harmoniseAlleles(c.a1, c.a2, c.candidates); got != c.want {
+			t.Errorf("harmoniseAlleles(%s, %s) = %s, want %s", c.a1, c.a2, got, c.want)
+		}
+	}
+}
+
+func TestHarmonise(t *testing.T) {
+	table := readTestTSV(t, "SNP\tCHR\tBP\tA1\tA2\tZ\tBETA\tOR\tFRQ\n"+
+		"rs1\t1\t100\t0\t0\t2\t0.2\t2\t0.3\n"+
+		"rs2\t1\t200\t0\t0\t2\t0.2\t2\t0.3\n"+
+		"rs3\t1\t300\t0\t0\t2\t0.2\t2\t0.3\n"+
+		"rs4\t1\t400\t0\t0\t2\t0.2\t2\t0.3\n"+
+		"rs5\t1\t500\t0\t0\t2\t0.2\t2\t0.3\n"+
+		"rs6\t1\tNA\t0\t0\t2\t0.2\t2\t0.3\n")
+	table = SetStringColumn(table, "CHR", []string{"1", "1", "1", "1", "1", "1"})
+	table = SetStringColumn(table, "A1", []string{"G", "A", "C", "T", "A", "G"})
+	table = SetStringColumn(table, "A2", []string{"A", "G", "T", "C", "C", "A"})
+	keys := HarmoniseKeys(table, false)
+	if keys[5] != "" {
+		t.Errorf("null BP has key %q, want none", keys[5])
+	}
+	ref := map[string][]VariantAlleles{}
+	for _, key := range keys[:5] {
+		ref[key] = []VariantAlleles{{Ref: "A", Alt: []string{"G"}}}
+	}
+
+	got, counts, err := Harmonise(table, ref, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{ActionAligned: 1, ActionSwapped: 1, ActionFlipped: 1, ActionFlippedSwapped: 1, ActionMismatch: 1, ActionMissing: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts %v, want %v", counts, want)
+	}
+	if a1 := StringValues(got.Column(ColumnIndex(got, "A1"))); !reflect.DeepEqual(a1, []string{"G", "G", "G", "G", "A", "G"}) {
+		t.Errorf("A1 %v", a1)
+	}
+	// the swapped rows have their effects reversed, the others are unchanged
+	for name, values := range map[string][2]float64{"Z": {2, -2}, "BETA": {0.2, -0.2}, "OR": {2, 0.5}, "FRQ": {0.3, 0.7}} {
+		got := Float64Values(got.Column(ColumnIndex(got, name)))
+		for i, swapped := range []bool{false, true, false, true, false, false} {
+			w := values[0]
+			if swapped {
+				w = values[1]
+			}
+			if math.Abs(got[i]-w) > 1e-12 {
+				t.Errorf("%s of row %d is %g, want %g", name, i+1, got[i], w)
+			}
+		}
+	}
+
+	got, _, err = Harmonise(table, ref, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions := StringValues(got.Column(ColumnIndex(got, "ACTION"))); !reflect.DeepEqual(actions, []string{ActionAligned, ActionSwapped, ActionFlipped, ActionFlippedSwapped}) {
+		t.Errorf("kept %v with drop", actions)
+	}
+}
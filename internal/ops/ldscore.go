@@ -0,0 +1,377 @@
+package ops
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+const NChr = 22
+
+var ldscoreDropCols = []string{"CHR", "BP", "CM", "MAF"}
+
+// PresentChrs returns the chromosomes for which prefix{chr}suffix exists.
+func PresentChrs(prefix string, suffix string) (chrs []int) {
+	for chr := 1; chr <= NChr; chr++ {
+		if _, err := parse.WhichCompression(parse.SubChr(prefix, chr) + suffix); err == nil {
+			chrs = append(chrs, chr)
+		}
+	}
+	return
+}
+
+// ReadLDScoreChr reads prefix{1..22}.l2.ldscore[.gz|.bz2] into a single table
+// holding the SNP column and the LD score columns.
+func ReadLDScoreChr(prefix string) (table array.Table, schema *arrow.Schema, err error) {
+	defer utils.TimeTrack(time.Now(), "ReadLDScoreChr")
+
+	chrs := PresentChrs(prefix, ".l2.ldscore")
+	if len(chrs) == 0 {
+		err = fmt.Errorf("no LD score files found for %s", prefix)
+		return
+	}
+	records := make([]array.Record, 0)
+	var header []string
+	for _, chr := range chrs {
+		file, _ := parse.WhichCompression(parse.SubChr(prefix, chr) + ".l2.ldscore")
+		t, rerr := readWhitespaceTable(file)
+		if rerr != nil {
+			err = rerr
+			return
+		}
+		h := make([]string, 0, t.NumCols())
+		for _, f := range t.Schema().Fields() {
+			h = append(h, f.Name)
+		}
+		if header == nil {
+			header = h
+		} else if strings.Join(h, "\t") != strings.Join(header, "\t") {
+			err = fmt.Errorf("LD score columns in %s do not match chromosome %d", file, chrs[0])
+			return
+		}
+		if t.NumRows() == 0 {
+			continue
+		}
+		recs, s := selectColumns(t, func(name string) bool { return !utils.InList(name, ldscoreDropCols) })
+		schema = s
+		records = append(records, recs...)
+	}
+	if schema == nil || schema.FieldIndices("SNP") == nil {
+		err = fmt.Errorf("LD score files for %s have no SNP column", prefix)
+		return
+	}
+	table = array.NewTableFromRecords(schema, records)
+	log.Println("Read LD scores for", table.NumRows(), "SNPs from", prefix)
+	return
+}
+
+// ReadLDScoreChrList reads the chromosome split LD scores of each prefix
+// and binds their columns into one table with the M_5_50 counts of every
+// column. The prefixes must list the same SNPs in the same order. With more
+// than one prefix, LD score columns are suffixed _0, _1, ... by prefix.
+func ReadLDScoreChrList(prefixes []string) (table array.Table, M []float64, err error) {
+	fields := make([]arrow.Field, 0)
+	cols := make([]array.Column, 0)
+	var snps []string
+	for p, prefix := range prefixes {
+		t, schema, rerr := ReadLDScoreChr(prefix)
+		if rerr != nil {
+			err = rerr
+			return
+		}
+		m, merr := ReadMChr(prefix, PresentChrs(prefix, ".l2.ldscore"))
+		if merr != nil {
+			err = merr
+			return
+		}
+		if len(m) != len(schema.Fields())-1 {
+			err = fmt.Errorf("%s has %d LD score columns but %d M values", prefix, len(schema.Fields())-1, len(m))
+			return
+		}
+		M = append(M, m...)
+		for i, f := range schema.Fields() {
+			if f.Name == "SNP" {
+				s := StringValues(t.Column(i))
+				if p == 0 {
+					snps = s
+					fields = append(fields, f)
+					cols = append(cols, *t.Column(i))
+				} else if strings.Join(s, "\t") != strings.Join(snps, "\t") {
+					err = fmt.Errorf("LD scores in %s and %s must have identical SNP columns", prefixes[0], prefix)
+					return
+				}
+				continue
+			}
+			if len(prefixes) > 1 {
+				f.Name = f.Name + "_" + strconv.Itoa(p)
+			}
+			fields = append(fields, f)
+			cols = append(cols, *array.NewColumn(f, t.Column(i).Data()))
+		}
+	}
+	table = array.NewTable(arrow.NewSchema(fields, nil), cols, -1)
+	return
+}
+
+// ReadMChr sums the per-annotation SNP counts in prefix{chr}.l2.M_5_50 over
+// the given chromosomes.
+func ReadMChr(prefix string, chrs []int) (M []float64, err error) {
+	for _, chr := range chrs {
+		file := parse.SubChr(prefix, chr) + ".l2.M_5_50"
+		f, oerr := parse.Open(file)
+		if oerr != nil {
+			err = oerr
+			return
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan()
+		fields := strings.Fields(scanner.Text())
+		f.Close()
+		if M == nil {
+			M = make([]float64, len(fields))
+		}
+		if len(fields) != len(M) {
+			err = fmt.Errorf("%s has %d columns, expected %d", file, len(fields), len(M))
+			return
+		}
+		for i, v := range fields {
+			m, perr := strconv.ParseFloat(v, 64)
+			if perr != nil {
+				err = perr
+				return
+			}
+			M[i] += m
+		}
+	}
+	if M == nil {
+		err = fmt.Errorf("no M_5_50 files found for %s", prefix)
+	}
+	return
+}
+
+// ReadFrq reads the FRQ (or MAF) column of a whitespace delimited PLINK
+// .frq file.
+func ReadFrq(file string) (frq []float64, err error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	col := -1
+	for i, c := range strings.Fields(scanner.Text()) {
+		if c == "FRQ" || c == "MAF" {
+			col = i
+		}
+	}
+	if col < 0 {
+		err = fmt.Errorf("%s has no FRQ or MAF column", file)
+		return
+	}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= col {
+			continue
+		}
+		v, perr := strconv.ParseFloat(fields[col], 64)
+		if perr != nil {
+			err = perr
+			return
+		}
+		frq = append(frq, v)
+	}
+	err = scanner.Err()
+	return
+}
+
+// ReadAnnotOverlapChr computes the cross-product of the annotation matrix in
+// prefix{chr}.annot over the given chromosomes, binding the columns of each
+// prefix, restricted to SNPs with 0.05 < FRQ < 0.95 when frqprefix is set.
+// It also returns the number of SNPs counted.
+func ReadAnnotOverlapChr(prefixes []string, frqprefix string, chrs []int) (overlap *mat.Dense, mtot float64, err error) {
+	defer utils.TimeTrack(time.Now(), "ReadAnnotOverlapChr")
+
+	for _, chr := range chrs {
+		annot := [][]float64{}
+		nrows := -1
+		for _, prefix := range prefixes {
+			file, werr := parse.WhichCompression(parse.SubChr(prefix, chr) + ".annot")
+			if werr != nil {
+				err = werr
+				return
+			}
+			a, rerr := readAnnot(file)
+			if rerr != nil {
+				err = rerr
+				return
+			}
+			if nrows >= 0 && len(a[0]) != nrows {
+				err = fmt.Errorf("%s has %d SNPs, expected %d", file, len(a[0]), nrows)
+				return
+			}
+			nrows = len(a[0])
+			annot = append(annot, a...)
+		}
+		if overlap == nil {
+			overlap = mat.NewDense(len(annot), len(annot), nil)
+		}
+		if r, _ := overlap.Dims(); r != len(annot) {
+			err = errors.New("annotation files have differing numbers of columns")
+			return
+		}
+		var frq []float64
+		if frqprefix != "" {
+			frqfile, werr := parse.WhichCompression(parse.SubChr(frqprefix, chr) + ".frq")
+			if werr != nil {
+				err = werr
+				return
+			}
+			frq, err = ReadFrq(frqfile)
+			if err != nil {
+				return
+			}
+			if len(frq) != nrows {
+				err = fmt.Errorf("%s has %d SNPs, expected %d", frqfile, len(frq), nrows)
+				return
+			}
+		}
+		nz := make([]int, 0, len(annot))
+		for row := 0; row < nrows; row++ {
+			if frq != nil && !(frq[row] > 0.05 && frq[row] < 0.95) {
+				continue
+			}
+			mtot++
+			nz = nz[:0]
+			for j := range annot {
+				if annot[j][row] != 0 {
+					nz = append(nz, j)
+				}
+			}
+			for _, a := range nz {
+				for _, b := range nz {
+					overlap.Set(a, b, overlap.At(a, b)+annot[a][row]*annot[b][row])
+				}
+			}
+		}
+	}
+	if overlap == nil {
+		err = fmt.Errorf("no annotation files found for %s", strings.Join(prefixes, ","))
+	}
+	return
+}
+
+// readAnnot reads the annotation columns of an LDSC .annot file.
+func readAnnot(file string) (annot [][]float64, err error) {
+	t, err := readWhitespaceTable(file)
+	if err != nil {
+		return
+	}
+	for i := 0; i < int(t.NumCols()); i++ {
+		if !utils.InList(t.Column(i).Name(), []string{"SNP", "CHR", "BP", "CM"}) {
+			annot = append(annot, Float64Values(t.Column(i)))
+		}
+	}
+	if len(annot) == 0 {
+		err = fmt.Errorf("%s has no annotation columns", file)
+	}
+	return
+}
+
+// readWhitespaceTable reads a whitespace delimited LDSC file, which may be
+// compressed, with SNP as text and every other column as float64.
+func readWhitespaceTable(file string) (table array.Table, err error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		if err = scanner.Err(); err == nil {
+			err = fmt.Errorf("%s is empty", file)
+		}
+		return
+	}
+	header := strings.Fields(scanner.Text())
+	fields := make([]arrow.Field, len(header))
+	for i, c := range header {
+		fields[i] = arrow.Field{Name: c, Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+		if c == "SNP" {
+			fields[i].Type = arrow.BinaryTypes.String
+		}
+	}
+	schema := arrow.NewSchema(fields, nil)
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer b.Release()
+	line := 1
+	for scanner.Scan() {
+		line++
+		values := strings.Fields(scanner.Text())
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) != len(header) {
+			return nil, fmt.Errorf("%s line %d: expected %d columns, found %d", file, line, len(header), len(values))
+		}
+		for i, v := range values {
+			switch fb := b.Field(i).(type) {
+			case *array.StringBuilder:
+				fb.Append(v)
+			case *array.Float64Builder:
+				if utils.InList(v, constants.Null_strings) {
+					fb.AppendNull()
+					continue
+				}
+				x, perr := strconv.ParseFloat(v, 64)
+				if perr != nil {
+					return nil, fmt.Errorf("%s line %d: column %s: %v", file, line, header[i], perr)
+				}
+				fb.Append(x)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	table = array.NewTableFromRecords(schema, []array.Record{rec})
+	return
+}
+
+func selectColumns(table array.Table, keep func(name string) bool) (records []array.Record, schema *arrow.Schema) {
+	idxs := []int{}
+	fields := make([]arrow.Field, 0)
+	for i, f := range table.Schema().Fields() {
+		if keep(f.Name) {
+			idxs = append(idxs, i)
+			fields = append(fields, f)
+		}
+	}
+	schema = arrow.NewSchema(fields, nil)
+	tr := array.NewTableReader(table, -1)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.Record()
+		cols := make([]array.Interface, 0, len(idxs))
+		for _, i := range idxs {
+			cols = append(cols, rec.Column(i))
+		}
+		records = append(records, array.NewRecord(schema, cols, rec.NumRows()))
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package ops
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeLDSCFile writes data to file, gzipped if the name ends in .gz.
+func writeLDSCFile(t *testing.T, file string, data string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !strings.HasSuffix(file, ".gz") {
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// LDSC files are whitespace delimited, so chromosome 1 is written with tabs
+// and gzipped and chromosome 2 with runs of spaces.
+func writeLDScores(t *testing.T, dir string) string {
+	prefix := filepath.Join(dir, "ld.")
+	writeLDSCFile(t, prefix+"1.l2.ldscore.gz", "CHR\tSNP\tBP\tCM\tMAF\tA_L2\tB_L2\n"+
+		"1\trs1\t100\t0\t0.3\t1.5\t0.5\n"+
+		"1\trs2\t200\t0\t0.02\t2.5\t0\n")
+	writeLDSCFile(t, prefix+"2.l2.ldscore", "CHR  SNP BP   CM MAF  A_L2 B_L2\n"+
+		"2 rs3   300 0  0.4  3.5  1\n")
+	writeLDSCFile(t, prefix+"1.l2.M_5_50", "10\t4\n")
+	writeLDSCFile(t, prefix+"2.l2.M_5_50", "20 6\n")
+	return prefix
+}
+
+func TestReadLDScoreChr(t *testing.T) {
+	prefix := writeLDScores(t, t.TempDir())
+	table, schema, err := ReadLDScoreChr(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range schema.Fields() {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"SNP", "A_L2", "B_L2"}) {
+		t.Errorf("columns %v, want SNP, A_L2 and B_L2", names)
+	}
+	if snps := StringValues(table.Column(0)); !reflect.DeepEqual(snps, []string{"rs1", "rs2", "rs3"}) {
+		t.Errorf("SNPs %v", snps)
+	}
+	if ld := Float64Values(table.Column(1)); !reflect.DeepEqual(ld, []float64{1.5, 2.5, 3.5}) {
+		t.Errorf("A_L2 %v", ld)
+	}
+
+	if _, _, err := ReadLDScoreChr(filepath.Join(t.TempDir(), "none.")); err == nil {
+		t.Error("no error for missing LD score files")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.")
+	writeLDSCFile(t, bad+"1.l2.ldscore", "SNP L2\nrs1 x\n")
+	if _, _, err := ReadLDScoreChr(bad); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error %v for a non-numeric LD score", err)
+	}
+	writeLDSCFile(t, bad+"1.l2.ldscore", "SNP L2\nrs1 1 2\n")
+	if _, _, err := ReadLDScoreChr(bad); err == nil {
+		t.Error("no error for a row with too many columns")
+	}
+}
+
+func TestReadMChr(t *testing.T) {
+	prefix := writeLDScores(t, t.TempDir())
+	M, err := ReadMChr(prefix, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(M, []float64{30, 10}) {
+		t.Errorf("M %v, want [30 10]", M)
+	}
+	writeLDSCFile(t, prefix+"2.l2.M_5_50", "20\n")
+	if _, err := ReadMChr(prefix, []int{1, 2}); err == nil {
+		t.Error("no error for M files with differing columns")
+	}
+}
+
+func TestReadLDScoreChrList(t *testing.T) {
+	dir := t.TempDir()
+	prefix := writeLDScores(t, dir)
+	other := filepath.Join(dir, "other.")
+	writeLDSCFile(t, other+"1.l2.ldscore", "SNP C_L2\nrs1 1\nrs2 2\n")
+	writeLDSCFile(t, other+"2.l2.ldscore", "SNP C_L2\nrs3 3\n")
+	writeLDSCFile(t, other+"1.l2.M_5_50", "7\n")
+	writeLDSCFile(t, other+"2.l2.M_5_50", "8\n")
+
+	table, M, err := ReadLDScoreChrList([]string{prefix, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range table.Schema().Fields() {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"SNP", "A_L2_0", "B_L2_0", "C_L2_1"}) {
+		t.Errorf("columns %v", names)
+	}
+	if !reflect.DeepEqual(M, []float64{30, 10, 15}) {
+		t.Errorf("M %v, want [30 10 15]", M)
+	}
+
+	writeLDSCFile(t, other+"2.l2.ldscore", "SNP C_L2\nrs4 3\n")
+	if _, _, err := ReadLDScoreChrList([]string{prefix, other}); err == nil {
+		t.Error("no error for prefixes with different SNPs")
+	}
+}
+
+func TestReadFrq(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "1.frq")
+	writeLDSCFile(t, file, " CHR  SNP   A1   A2          MAF  NCHROBS\n   1  rs1    A    G       0.25       20\n   1  rs2    C    T       0.5        20\n")
+	frq, err := ReadFrq(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(frq, []float64{0.25, 0.5}) {
+		t.Errorf("frq %v", frq)
+	}
+	writeLDSCFile(t, file, "CHR SNP\n1 rs1\n")
+	if _, err := ReadFrq(file); err == nil {
+		t.Error("no error for a file without FRQ or MAF")
+	}
+}
+
+func TestReadAnnotOverlapChr(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a."), filepath.Join(dir, "b.")
+	writeLDSCFile(t, a+"1.annot.gz", "CHR\tBP\tSNP\tCM\tbase\tX\n1\t1\trs1\t0\t1\t1\n1\t2\trs2\t0\t1\t0\n1\t3\trs3\t0\t1\t0.5\n")
+	writeLDSCFile(t, b+"1.annot", "Y\n0\n1\n1\n")
+	writeLDSCFile(t, filepath.Join(dir, "f.1.frq"), "CHR SNP MAF\n1 rs1 0.3\n1 rs2 0.01\n1 rs3 0.2\n")
+
+	overlap, mtot, err := ReadAnnotOverlapChr([]string{a, b}, "", []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{3, 1.5, 2}, {1.5, 1.25, 0.5}, {2, 0.5, 2}}
+	for i := range want {
+		for j := range want[i] {
+			if overlap.At(i, j) != want[i][j] {
+				t.Errorf("overlap[%d][%d] = %g, want %g", i, j, overlap.At(i, j), want[i][j])
+			}
+		}
+	}
+	if mtot != 3 {
+		t.Errorf("mtot %g, want 3", mtot)
+	}
+
+	// rs2 is left out with a MAF of 0.01
+	overlap, mtot, err = ReadAnnotOverlapChr([]string{a, b}, filepath.Join(dir, "f."), []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtot != 2 || overlap.At(0, 0) != 2 || overlap.At(2, 2) != 1 || overlap.At(0, 1) != 1.5 {
+		t.Errorf("overlap with frq %v and mtot %g", overlap.RawMatrix().Data, mtot)
+	}
+}
@@ -0,0 +1,81 @@
+package ops
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/liftover"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// LiftoverCounts are the rows dropped by Liftover, by reason, and Flipped
+// the rows lifted onto the reverse strand, whose alleles were complemented.
+type LiftoverCounts struct {
+	Unmapped int
+	Multiple int
+	OtherChr int
+	Strand   int
+	Flipped  int
+}
+
+func (c LiftoverCounts) Total() int {
+	return c.Unmapped + c.Multiple + c.OtherChr + c.Strand
+}
+
+// Liftover converts the CHR and BP columns of table with chain. A position
+// lifted onto the reverse strand of the same chromosome, as in an inverted
+// region, has its A1 and A2 reverse complemented. Rows whose position is
+// unmapped, maps to more than one place or moves to another chromosome are
+// dropped, as are reverse strand rows with alleles that cannot be
+// complemented, and recorded in rejects as liftover_unmapped,
+// liftover_multiple, liftover_other_chr or liftover_strand.
+func Liftover(table array.Table, chain *liftover.Chain, rejects *Rejects) (new_table array.Table, counts LiftoverCounts, err error) {
+	defer utils.TimeTrack(time.Now(), "Liftover")
+
+	chr := StringValues(table.Column(ColumnIndex(table, "CHR")))
+	bp := Float64Values(table.Column(ColumnIndex(table, "BP")))
+	var a1, a2 []string
+	if ColumnIndex(table, "A1") >= 0 && ColumnIndex(table, "A2") >= 0 {
+		a1 = StringValues(table.Column(ColumnIndex(table, "A1")))
+		a2 = StringValues(table.Column(ColumnIndex(table, "A2")))
+	}
+	reasons := make([]string, len(bp))
+	for i := range bp {
+		// BP is 1-based, chain files are 0-based
+		hits := chain.Lift(chr[i], int(bp[i])-1)
+		switch {
+		case len(hits) == 0:
+			counts.Unmapped++
+			reasons[i] = "liftover_unmapped"
+			continue
+		case len(hits) > 1:
+			counts.Multiple++
+			reasons[i] = "liftover_multiple"
+			continue
+		case hits[0].Chr != parse.NormalizeCHR(chr[i]):
+			counts.OtherChr++
+			reasons[i] = "liftover_other_chr"
+			continue
+		}
+		if hits[0].Strand == '-' && a1 != nil {
+			x, y := reverseComplement(strings.ToUpper(a1[i])), reverseComplement(strings.ToUpper(a2[i]))
+			if x == "" || y == "" {
+				counts.Strand++
+				reasons[i] = "liftover_strand"
+				continue
+			}
+			a1[i], a2[i] = x, y
+			counts.Flipped++
+		}
+		bp[i] = float64(hits[0].Pos + 1)
+	}
+	new_table = SetFloat64Column(table, "BP", bp)
+	if counts.Flipped > 0 {
+		new_table = SetStringColumn(new_table, "A1", a1)
+		new_table = SetStringColumn(new_table, "A2", a2)
+	}
+	new_table, err = rejects.Filter(new_table, reasons)
+	return
+}
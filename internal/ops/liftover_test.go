@@ -0,0 +1,65 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/awilliamson10/golink/internal/liftover"
+)
+
+// chr1 moves up 5000bp, chr2 moves to chr3 and chr4:0-100 is inverted.
+const test_chain = `chain 1000 chr1 100000 + 0 1600 chr1 200000 + 5000 6550 1
+1000 100 50
+500
+
+chain 900 chr2 100000 + 100 300 chr3 100000 + 0 200 2
+200
+
+chain 800 chr4 100000 + 0 100 chr4 100000 - 10 110 3
+100
+`
+
+func TestLiftover(t *testing.T) {
+	dir := t.TempDir()
+	chain_file := filepath.Join(dir, "test.chain")
+	if err := os.WriteFile(chain_file, []byte(test_chain), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := liftover.ReadChain(chain_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "in.tsv")
+	data := "CHR\tBP\tA1\tA2\n1\t11\tA\tG\n4\t1\tac\tG\n2\t150\tA\tG\n4\t2\tI\tD\n5\t1\tA\tG\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctypes := map[string]arrow.DataType{
+		"CHR": arrow.BinaryTypes.String,
+		"BP":  arrow.PrimitiveTypes.Float64,
+		"A1":  arrow.BinaryTypes.String,
+		"A2":  arrow.BinaryTypes.String,
+	}
+	table, _ := ArrowCSV(file, []string{"CHR", "BP", "A1", "A2"}, '\t', ctypes)
+
+	lifted, counts, err := Liftover(table, chain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LiftoverCounts{Unmapped: 1, OtherChr: 1, Strand: 1, Flipped: 1}); counts != want {
+		t.Errorf("counts %+v, want %+v", counts, want)
+	}
+	rows := []string{}
+	bp := Float64Values(lifted.Column(ColumnIndex(lifted, "BP")))
+	a1 := StringValues(lifted.Column(ColumnIndex(lifted, "A1")))
+	a2 := StringValues(lifted.Column(ColumnIndex(lifted, "A2")))
+	for i := range bp {
+		rows = append(rows, FormatFloat(bp[i])+":"+a1[i]+"/"+a2[i])
+	}
+	if got, want := strings.Join(rows, ","), "5011:A/G,99990:GT/C"; got != want {
+		t.Errorf("lifted %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+var complement = map[string]string{"A": "T", "T": "A", "C": "G", "G": "C"}
+
+// MergeAllele is a reference SNP for --merge-alleles. FRQ is the frequency
+// of A1, or NaN if the list has none.
+type MergeAllele struct {
+	A1  string
+	A2  string
+	FRQ float64
+}
+
+type MergeOptions struct {
+	// PalindromicByFrq keeps A/T and C/G SNPs whose MAF in both the sumstats
+	// and the reference is below MaxMAF, taking the strand from whether the
+	// two frequencies are on the same side of 0.5.
+	PalindromicByFrq bool
+	MaxMAF           float64
+}
+
+// MergeCounts are the rows dropped by MergeAlleles, by reason, and the
+// palindromic SNPs it kept.
+type MergeCounts struct {
+	NotInRef    int
+	Mismatch    int
+	Palindromic int
+	Resolved    int
+	StrandFlip  int
+}
+
+// ReadMergeAlleles reads a whitespace delimited SNP list with SNP, A1 and A2
+// columns and, optionally, the frequency of A1. A MAF column is ignored,
+// since it does not say which allele it is the frequency of.
+func ReadMergeAlleles(file string) (map[string]MergeAllele, error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("%s is empty", file)
+	}
+	cols := map[string]int{}
+	for i, h := range strings.Fields(scanner.Text()) {
+		if parse.CleanName(h) == "MAF" {
+			continue
+		}
+		name := constants.Default_cnames[parse.CleanName(h)]
+		if utils.InList(name, []string{"SNP", "A1", "A2", "FRQ"}) {
+			cols[name] = i
+		}
+	}
+	for _, name := range []string{"SNP", "A1", "A2"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("%s: missing required column: %s", file, name)
+		}
+	}
+	_, has_frq := cols["FRQ"]
+	alleles := map[string]MergeAllele{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		a := MergeAllele{
+			A1:  strings.ToUpper(fields[cols["A1"]]),
+			A2:  strings.ToUpper(fields[cols["A2"]]),
+			FRQ: math.NaN(),
+		}
+		if has_frq {
+			if v, err := strconv.ParseFloat(fields[cols["FRQ"]], 64); err == nil {
+				a.FRQ = v
+			}
+		}
+		alleles[fields[cols["SNP"]]] = a
+	}
+	return alleles, scanner.Err()
+}
+
+// MergeAlleles keeps the SNPs of table that are in ref with matching alleles,
+// on either strand and in either order. Strand ambiguous SNPs are dropped
+// unless opts.PalindromicByFrq resolves them, in which case A1 and A2 are
+// relabelled to the reference strand. Dropped SNPs are recorded in rejects as
+// merge_not_in_ref, merge_mismatch or merge_palindromic.
+func MergeAlleles(table array.Table, ref map[string]MergeAllele, opts MergeOptions, rejects *Rejects) (new_table array.Table, counts MergeCounts, err error) {
+	defer utils.TimeTrack(time.Now(), "MergeAlleles")
+
+	snps := StringValues(table.Column(ColumnIndex(table, "SNP")))
+	a1s := StringValues(table.Column(ColumnIndex(table, "A1")))
+	a2s := StringValues(table.Column(ColumnIndex(table, "A2")))
+	var frq []float64
+	if i := ColumnIndex(table, "FRQ"); i >= 0 {
+		frq = Float64Values(table.Column(i))
+	}
+	reasons := make([]string, len(snps))
+	for i, snp := range snps {
+		r, ok := ref[snp]
+		if !ok {
+			counts.NotInRef++
+			reasons[i] = "merge_not_in_ref"
+			continue
+		}
+		a1, a2 := strings.ToUpper(a1s[i]), strings.ToUpper(a2s[i])
+		if !allelesMatch(a1, a2, r) {
+			counts.Mismatch++
+			reasons[i] = "merge_mismatch"
+			continue
+		}
+		if complement[a1] != a2 {
+			continue
+		}
+		if !opts.PalindromicByFrq || frq == nil {
+			counts.Palindromic++
+			reasons[i] = "merge_palindromic"
+			continue
+		}
+		// the frequency of the sumstats A1 in the reference, on the
+		// reference strand
+		ref_frq := r.FRQ
+		if a1 != r.A1 {
+			ref_frq = 1 - ref_frq
+		}
+		f := frq[i]
+		if math.IsNaN(f) || math.IsNaN(ref_frq) || math.Min(f, 1-f) >= opts.MaxMAF || math.Min(ref_frq, 1-ref_frq) >= opts.MaxMAF {
+			counts.Palindromic++
+			reasons[i] = "merge_palindromic"
+			continue
+		}
+		counts.Resolved++
+		if (f-0.5)*(ref_frq-0.5) < 0 {
+			// the sumstats are on the other strand, where A1 reads as A2
+			a1s[i], a2s[i] = a2s[i], a1s[i]
+			counts.StrandFlip++
+		}
+	}
+	new_table = table
+	if counts.StrandFlip > 0 {
+		new_table = SetStringColumn(SetStringColumn(new_table, "A1", a1s), "A2", a2s)
+	}
+	new_table, err = rejects.Filter(new_table, reasons)
+	return
+}
+
+func allelesMatch(a1 string, a2 string, r MergeAllele) bool {
+	for _, pair := range [][2]string{{r.A1, r.A2}, {complement[r.A1], complement[r.A2]}} {
+		if (a1 == pair[0] && a2 == pair[1]) || (a1 == pair[1] && a2 == pair[0]) {
+			return true
+		}
+	}
+	return false
+}
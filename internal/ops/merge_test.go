@@ -0,0 +1,87 @@
+package ops
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadMergeAlleles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ref.txt")
+	for _, c := range []struct {
+		data string
+		frq  float64
+	}{
+		{"SNP A1 A2 FRQ\nrs1 a g 0.3\n", 0.3},
+		{"SNP\tA1\tA2\tEAF\nrs1\ta\tg\t0.3\n", 0.3},
+		// a MAF is not the frequency of A1
+		{"SNP A1 A2 MAF\nrs1 a g 0.3\n", math.NaN()},
+	} {
+		if err := os.WriteFile(file, []byte(c.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		ref, err := ReadMergeAlleles(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ref["rs1"]
+		if got.A1 != "A" || got.A2 != "G" || !(got.FRQ == c.frq || math.IsNaN(got.FRQ) && math.IsNaN(c.frq)) {
+			t.Errorf("%q: read %+v, want A, G and %g", c.data, got, c.frq)
+		}
+	}
+}
+
+func TestMergeAlleles(t *testing.T) {
+	table := readTestTSV(t, "SNP\tA1\tA2\tFRQ\n"+
+		"rs1\t0\t0\t0.3\n"+
+		"rs2\t0\t0\t0.3\n"+
+		"rs3\t0\t0\t0.3\n"+
+		"rs4\t0\t0\t0.3\n"+
+		"rs5\t0\t0\t0.2\n"+
+		"rs6\t0\t0\t0.2\n"+
+		"rs7\t0\t0\t0.45\n"+
+		"rs8\t0\t0\t0.3\n")
+	// rs1 matches, rs2 is swapped, rs3 is on the other strand and rs4
+	// does not match; rs5 to rs7 are A/T SNPs
+	table = SetStringColumn(table, "A1", []string{"A", "G", "T", "A", "A", "A", "A", "A"})
+	table = SetStringColumn(table, "A2", []string{"G", "A", "C", "C", "T", "T", "T", "G"})
+	ref := map[string]MergeAllele{
+		"rs1": {"A", "G", math.NaN()},
+		"rs2": {"A", "G", math.NaN()},
+		"rs3": {"A", "G", math.NaN()},
+		"rs4": {"A", "G", math.NaN()},
+		// the same strand: A has a frequency of 0.25
+		"rs5": {"A", "T", 0.25},
+		// A has a frequency of 0.75, so the sumstats A is the reference T
+		"rs6": {"T", "A", 0.25},
+		// too common to tell
+		"rs7": {"A", "T", 0.45},
+	}
+	for _, c := range []struct {
+		opts   MergeOptions
+		snps   []string
+		a1     []string
+		counts MergeCounts
+	}{
+		{MergeOptions{}, []string{"rs1", "rs2", "rs3"}, []string{"A", "G", "T"},
+			MergeCounts{NotInRef: 1, Mismatch: 1, Palindromic: 3}},
+		{MergeOptions{PalindromicByFrq: true, MaxMAF: 0.4}, []string{"rs1", "rs2", "rs3", "rs5", "rs6"}, []string{"A", "G", "T", "A", "T"},
+			MergeCounts{NotInRef: 1, Mismatch: 1, Palindromic: 1, Resolved: 2, StrandFlip: 1}},
+	} {
+		got, counts, err := MergeAlleles(table, ref, c.opts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts != c.counts {
+			t.Errorf("%+v: counts %+v, want %+v", c.opts, counts, c.counts)
+		}
+		if snps := StringValues(got.Column(ColumnIndex(got, "SNP"))); !reflect.DeepEqual(snps, c.snps) {
+			t.Errorf("%+v: kept %v, want %v", c.opts, snps, c.snps)
+		}
+		if a1 := StringValues(got.Column(ColumnIndex(got, "A1"))); !reflect.DeepEqual(a1, c.a1) {
+			t.Errorf("%+v: A1 %v, want %v", c.opts, a1, c.a1)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// Region_presets are the regions --exclude-regions knows by name, by genome
+// build. mhc is the extended MHC, chr6:25-34Mb, commonly left out of LD score
+// regression; its bounds are rounded to the Mb, which the GRCh37 to GRCh38
+// shift there is well inside.
+var Region_presets = map[string]map[string]string{
+	"mhc": {
+		"GRCh37": "6\t25000000\t34000000",
+		"GRCh38": "6\t25000000\t34000000",
+	},
+}
+
+// Regions is an index of genomic intervals for overlap queries: the merged,
+// sorted 0-based half-open intervals of each chromosome.
+type Regions struct {
+	chrs map[string][][2]int
+}
+
+// NormalizeBuild maps the common names of GRCh37 and GRCh38 to those two.
+func NormalizeBuild(build string) string {
+	switch strings.ToLower(build) {
+	case "grch37", "hg19", "b37":
+		return "GRCh37"
+	case "grch38", "hg38", "b38":
+		return "GRCh38"
+	}
+	return build
+}
+
+// ReadRegions reads a comma separated list of BED files and presets of
+// Region_presets, taking presets in build.
+func ReadRegions(specs string, build string) (*Regions, error) {
+	r := &Regions{chrs: map[string][][2]int{}}
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if preset, ok := Region_presets[strings.ToLower(spec)]; ok {
+			bed, ok := preset[NormalizeBuild(build)]
+			if !ok {
+				return nil, fmt.Errorf("no %s regions for genome build %q; use GRCh37 or GRCh38", spec, build)
+			}
+			if err := r.readBED(strings.NewReader(bed), spec); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, err := parse.Open(spec)
+		if err != nil {
+			return nil, err
+		}
+		err = r.readBED(f, spec)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.merge()
+	return r, nil
+}
+
+func (r *Regions) readBED(in io.Reader, name string) error {
+	scanner := bufio.NewScanner(in)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "track") || strings.HasPrefix(text, "browser") {
+			continue
+		}
+		cols := strings.Fields(text)
+		if len(cols) < 3 {
+			return fmt.Errorf("%s line %d: expected chrom, start and end", name, line)
+		}
+		start, err1 := strconv.Atoi(cols[1])
+		end, err2 := strconv.Atoi(cols[2])
+		if err1 != nil || err2 != nil || end < start {
+			return fmt.Errorf("%s line %d: bad interval %s-%s", name, line, cols[1], cols[2])
+		}
+		chr := parse.NormalizeCHR(cols[0])
+		r.chrs[chr] = append(r.chrs[chr], [2]int{start, end})
+	}
+	return scanner.Err()
+}
+
+// merge sorts the intervals of each chromosome and joins those that overlap,
+// so that Contains is a binary search.
+func (r *Regions) merge() {
+	for chr, ivs := range r.chrs {
+		sort.Slice(ivs, func(i, j int) bool { return ivs[i][0] < ivs[j][0] })
+		merged := ivs[:0]
+		for _, iv := range ivs {
+			if n := len(merged); n > 0 && iv[0] <= merged[n-1][1] {
+				if iv[1] > merged[n-1][1] {
+					merged[n-1][1] = iv[1]
+				}
+				continue
+			}
+			merged = append(merged, iv)
+		}
+		r.chrs[chr] = merged
+	}
+}
+
+// Len returns the number of merged intervals.
+func (r *Regions) Len() int {
+	n := 0
+	for _, ivs := range r.chrs {
+		n += len(ivs)
+	}
+	return n
+}
+
+// Contains reports whether the 1-based position bp of chr is in a region.
+func (r *Regions) Contains(chr string, bp int) bool {
+	ivs := r.chrs[parse.NormalizeCHR(chr)]
+	// the first interval ending at or after bp, which holds bp if it starts
+	// before it
+	i := sort.Search(len(ivs), func(i int) bool { return ivs[i][1] >= bp })
+	return i < len(ivs) && ivs[i][0] < bp
+}
+
+// Filter returns a Filter dropping the rows whose CHR and BP are in r, for
+// the reason "region".
+func (r *Regions) Filter() Filter {
+	return regionFilter{r}
+}
+
+type regionFilter struct {
+	regions *Regions
+}
+
+func (regionFilter) Name() string      { return "region" }
+func (regionFilter) Columns() []string { return []string{"CHR", "BP"} }
+
+func (f regionFilter) Apply(rec array.Record) (keep []bool, reasons []string) {
+	chr := chunkStrings(rec.Column(rec.Schema().FieldIndices("CHR")[0]))
+	bp := array.NewFloat64Data(rec.Column(rec.Schema().FieldIndices("BP")[0]).Data())
+	defer bp.Release()
+	keep = make([]bool, len(chr))
+	reasons = make([]string, len(chr))
+	for i := range keep {
+		keep[i] = bp.IsNull(i) || !f.regions.Contains(chr[i], int(bp.Value(i)))
+		if !keep[i] {
+			reasons[i] = "region"
+		}
+	}
+	return
+}
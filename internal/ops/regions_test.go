@@ -0,0 +1,99 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// BED intervals are 0-based and half-open, so the interval 100 200 holds the
+// 1-based positions 101 to 200.
+func TestRegionsContains(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "regions.bed")
+	bed := "track name=test\n" +
+		"# comment\n" +
+		"chr1\t100\t200\n" +
+		"1\t200\t300\n" +
+		"2\t500\t500\n" +
+		"2\t1000\t1001\textra\tcolumns\n" +
+		"chrX\t0\t10\n"
+	if err := os.WriteFile(file, []byte(bed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadRegions(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the adjacent intervals of chromosome 1 are merged
+	if r.Len() != 4 {
+		t.Errorf("%d merged intervals, want 4", r.Len())
+	}
+	for _, c := range []struct {
+		chr  string
+		bp   int
+		want bool
+	}{
+		{"1", 100, false},
+		{"1", 101, true},
+		{"chr1", 200, true},
+		{"1", 201, true},
+		{"1", 300, true},
+		{"1", 301, false},
+		// an empty interval holds nothing
+		{"2", 500, false},
+		{"2", 501, false},
+		{"2", 1000, false},
+		{"2", 1001, true},
+		{"2", 1002, false},
+		{"X", 1, true},
+		{"23", 10, true},
+		{"X", 11, false},
+		{"3", 150, false},
+	} {
+		if got := r.Contains(c.chr, c.bp); got != c.want {
+			t.Errorf("Contains(%s, %d) = %v, want %v", c.chr, c.bp, got, c.want)
+		}
+	}
+
+	mhc, err := ReadRegions("MHC", "hg19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mhc.Contains("6", 25000000) || !mhc.Contains("6", 25000001) || !mhc.Contains("6", 34000000) || mhc.Contains("6", 34000001) {
+		t.Error("mhc preset does not hold chr6:25000001-34000000")
+	}
+	if _, err := ReadRegions("mhc", "hg17"); err == nil {
+		t.Error("no error for a preset in an unknown build")
+	}
+	if err := os.WriteFile(file, []byte("1\t200\t100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadRegions(file, ""); err == nil {
+		t.Error("no error for an interval ending before it starts")
+	}
+}
+
+func TestRegionsFilter(t *testing.T) {
+	r, err := ReadRegions("mhc", "GRCh38")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := readTestTSV(t, "SNP\tCHR\tBP\n"+
+		"rs1\t0\t25000000\n"+
+		"rs2\t0\t25000001\n"+
+		"rs3\t0\tNA\n"+
+		"rs4\t0\t30000000\n")
+	table = SetStringColumn(table, "CHR", []string{"6", "chr6", "6", "5"})
+	got, dropped, err := Pipeline{r.Filter()}.Run(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows without a position are left to the missing value check
+	if snps := StringValues(got.Column(0)); !reflect.DeepEqual(snps, []string{"rs1", "rs3", "rs4"}) {
+		t.Errorf("kept %v, want rs1, rs3 and rs4", snps)
+	}
+	if dropped["region"] != 1 {
+		t.Errorf("dropped %v, want 1 region", dropped)
+	}
+}
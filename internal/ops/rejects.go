@@ -0,0 +1,170 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// Rejects records the rows dropped by the munge stages and why, for
+// --write-dropped. Rows are identified by the ROW column RowSelect adds as the
+// file is read, which the stages carry along. A nil *Rejects records nothing.
+type Rejects struct {
+	rows map[int64]reject
+}
+
+type reject struct {
+	snp    string
+	reason string
+}
+
+func NewRejects() *Rejects {
+	return &Rejects{rows: map[int64]reject{}}
+}
+
+// Len returns the number of rows recorded.
+func (r *Rejects) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rows)
+}
+
+func (r *Rejects) add(row float64, snp string, reason string) {
+	if _, ok := r.rows[int64(row)]; !ok {
+		r.rows[int64(row)] = reject{snp: snp, reason: reason}
+	}
+}
+
+// Filter drops the rows of table with a reason, recording them, and keeps
+// those whose reason is "".
+func (r *Rejects) Filter(table array.Table, reasons []string) (array.Table, error) {
+	keep := make([]bool, len(reasons))
+	dropped := false
+	for i, reason := range reasons {
+		keep[i] = reason == ""
+		dropped = dropped || !keep[i]
+	}
+	if !dropped {
+		return table, nil
+	}
+	if r != nil && ColumnIndex(table, "ROW") >= 0 {
+		rows := Float64Values(table.Column(ColumnIndex(table, "ROW")))
+		var snps []string
+		if i := ColumnIndex(table, "SNP"); i >= 0 {
+			snps = StringValues(table.Column(i))
+		}
+		for i, reason := range reasons {
+			if reason == "" {
+				continue
+			}
+			snp := ""
+			if snps != nil {
+				snp = snps[i]
+			}
+			r.add(rows[i], snp, reason)
+		}
+	}
+	return FilterRows(table, keep)
+}
+
+// Write writes the recorded rows of input to file, gzipped if it ends in
+// .gz, as a TSV of the reason of each row and its original line. With
+// add_snp, for input without a SNP column, each line starts with the SNP the
+// row had when it was dropped. Lines starting with ## are skipped and the
+// next line is taken as the header, so both TSV and VCF input work.
+func (r *Rejects) Write(file string, input string, add_snp bool) error {
+	in, err := parse.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	f, err := parse.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	rows := make([]int64, 0, r.Len())
+	for row := range r.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i] < rows[j] })
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	header := true
+	row, next := int64(0), 0
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "##") || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if header {
+			if add_snp {
+				w.WriteString("SNP\t")
+			}
+			fmt.Fprintf(w, "REASON\t%s\n", strings.TrimPrefix(text, "#"))
+			header = false
+			continue
+		}
+		if next == len(rows) {
+			break
+		}
+		if row == rows[next] {
+			rej := r.rows[row]
+			if add_snp {
+				fmt.Fprintf(w, "%s\t", rej.snp)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", rej.reason, text)
+			next++
+		}
+		row++
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return err
+	}
+	if next < len(rows) {
+		f.Close()
+		return fmt.Errorf("%s has fewer data lines than rows read", input)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addRecord records the rows of rec with a reason.
+func (r *Rejects) addRecord(rec array.Record, reasons []string) {
+	var rows *array.Float64
+	var snps *array.String
+	for i, col := range rec.Columns() {
+		switch rec.ColumnName(i) {
+		case "ROW":
+			rows = array.NewFloat64Data(col.Data())
+			defer rows.Release()
+		case "SNP":
+			snps = array.NewStringData(col.Data())
+			defer snps.Release()
+		}
+	}
+	if rows == nil {
+		return
+	}
+	for i, reason := range reasons {
+		if reason == "" {
+			continue
+		}
+		snp := ""
+		if snps != nil {
+			snp = snps.Value(i)
+		}
+		r.add(rows.Value(i), snp, reason)
+	}
+}
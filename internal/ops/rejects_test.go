@@ -0,0 +1,46 @@
+package ops
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+func TestRejectsWrite(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.tsv")
+	if err := os.WriteFile(input, []byte("SNP\tP\nrs1\t0.1\nrs2\tNA\n\nrs3\t2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRejects()
+	r.add(2, "rs3", "p")
+	r.add(1, "rs2", "missing")
+	r.add(1, "rs2", "p")
+	for _, c := range []struct {
+		add_snp bool
+		want    string
+	}{
+		{false, "REASON\tSNP\tP\nmissing\trs2\tNA\np\trs3\t2\n"},
+		{true, "SNP\tREASON\tSNP\tP\nrs2\tmissing\trs2\tNA\nrs3\tp\trs3\t2\n"},
+	} {
+		file := filepath.Join(dir, "dropped.tsv.gz")
+		if err := r.Write(file, input, c.add_snp); err != nil {
+			t.Fatal(err)
+		}
+		f, err := parse.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.want {
+			t.Errorf("add_snp %v wrote\n%s\nwant\n%s", c.add_snp, got, c.want)
+		}
+	}
+}
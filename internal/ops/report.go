@@ -0,0 +1,103 @@
+package ops
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// ReportColumn is the interpretation of an input column.
+type ReportColumn struct {
+	Name        string `json:"name"`
+	Cname       string `json:"cname"`
+	Description string `json:"description"`
+}
+
+// Report is the QC report of a munge run, written as <out>.report.json.
+// Statistics that cannot be computed are null.
+type Report struct {
+	Sumstats         string            `json:"sumstats"`
+	InputRows        int64             `json:"input_rows"`
+	OutputRows       int64             `json:"output_rows"`
+	Dropped          map[string]int    `json:"dropped"`
+	Columns          []ReportColumn    `json:"columns"`
+	MeanChi2         *float64          `json:"mean_chi2"`
+	LambdaGC         *float64          `json:"lambda_gc"`
+	MaxChi2          *float64          `json:"max_chi2"`
+	GenomeWideSig    int64             `json:"genome_wide_significant"`
+	Chromosomes      []ChiSquareStats  `json:"chromosomes,omitempty"`
+	SignedStat       string            `json:"signed_stat,omitempty"`
+	MedianSignedStat *float64          `json:"median_signed_stat"`
+	Timings          []utils.Timing    `json:"timings"`
+	Options          map[string]string `json:"options"`
+}
+
+// AddDropped adds counts to the drop counts of r, leaving out zeros.
+func (r *Report) AddDropped(counts map[string]int) {
+	if r.Dropped == nil {
+		r.Dropped = map[string]int{}
+	}
+	for reason, n := range counts {
+		if n > 0 {
+			r.Dropped[reason] += n
+		}
+	}
+}
+
+// SetStats sets the chi^2 diagnostics of r from the Z column of table, and
+// the median signed statistic from Z or, without Z, the effect size.
+func (r *Report) SetStats(table array.Table) {
+	for _, col := range []string{"Z", "BETA", "LOG_ODDS", "OR"} {
+		if i := ColumnIndex(table, col); i >= 0 {
+			var m medianSketch
+			for _, c := range table.Column(i).Data().Chunks() {
+				d := array.NewFloat64Data(c.Data())
+				for j := 0; j < d.Len(); j++ {
+					if !d.IsNull(j) && !math.IsNaN(d.Value(j)) {
+						m.add(d.Value(j))
+					}
+				}
+				d.Release()
+			}
+			r.SignedStat = col
+			r.MedianSignedStat = reportFloat(m.value())
+			break
+		}
+	}
+	all, chrs, ok := ChiSquare(table)
+	if !ok || all.SNPs == 0 {
+		return
+	}
+	r.MeanChi2 = reportFloat(all.MeanChi2)
+	r.LambdaGC = reportFloat(all.LambdaGC)
+	r.MaxChi2 = reportFloat(all.MaxChi2)
+	r.GenomeWideSig = all.GenomeWideSig
+	r.Chromosomes = chrs
+}
+
+// Write writes r as indented JSON to file.
+func (r *Report) Write(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reportFloat returns nil for values JSON cannot encode.
+func reportFloat(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,71 @@
+package ops
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The drop counts of report.json add up the reasons of each stage of munge
+// and, with the output rows, account for every input row.
+func TestReportDropped(t *testing.T) {
+	table := readTestTSV(t, "SNP\tA1\tA2\tN\tP\n"+
+		"rs1\t0\t0\t1000\t0.01\n"+
+		"rs2\t0\t0\t1000\t2\n"+
+		"rs3\t0\t0\t1000\tNA\n"+
+		"rs4\t0\t0\t100\t0.1\n"+
+		"rs1\t0\t0\t1000\t0.5\n"+
+		"rs5\t0\t0\t1000\t0.1\n"+
+		"rs6\t0\t0\t1000\t0.2\n")
+	table = SetStringColumn(table, "A1", []string{"A", "C", "A", "A", "A", "A", "A"})
+	table = SetStringColumn(table, "A2", []string{"G", "T", "C", "C", "G", "Z", "G"})
+
+	report := Report{InputRows: table.NumRows()}
+	report.AddDropped(map[string]int{"extract": 0})
+	table, dropped, err := Pipeline(DefaultFilters(QCOptions{MAFMin: 0.01, INFOMin: 0.9})).Run(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.AddDropped(dropped)
+	table, dropped, err = ProcessN(table, NOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.AddDropped(dropped)
+	table, duplicates, err := RemoveDuplicateSNPS(table, DuplicateOptions{Policy: "first"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.AddDropped(map[string]int{"duplicate": duplicates})
+	report.OutputRows = table.NumRows()
+
+	file := filepath.Join(t.TempDir(), "out.report.json")
+	if err := report.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		InputRows  int64          `json:"input_rows"`
+		OutputRows int64          `json:"output_rows"`
+		Dropped    map[string]int `json:"dropped"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"p": 1, "missing": 1, "alleles": 1, "n": 1, "duplicate": 1}
+	if !reflect.DeepEqual(got.Dropped, want) {
+		t.Errorf("dropped %v, want %v", got.Dropped, want)
+	}
+	total := got.OutputRows
+	for _, n := range got.Dropped {
+		total += int64(n)
+	}
+	if got.InputRows != 7 || got.OutputRows != 2 || total != got.InputRows {
+		t.Errorf("%d input rows, %d output rows and %d dropped", got.InputRows, got.OutputRows, total-got.OutputRows)
+	}
+}
@@ -0,0 +1,111 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// ReadSNPList reads the SNP IDs of a PLINK style --extract or --exclude
+// file: the first field of each line.
+func ReadSNPList(file string) (map[string]struct{}, error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	snps := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			snps[fields[0]] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(snps) == 0 {
+		return nil, fmt.Errorf("%s has no SNPs", file)
+	}
+	return snps, nil
+}
+
+// RowSelect picks the rows of a sumstats file while it is read, so that
+// rows left out are never held in memory. With Extract only the rows whose
+// SNP is listed are kept, and with Exclude the rows whose SNP is listed are
+// left out, counted as "extract" and "exclude". With Rejects each row kept
+// gets a ROW column numbering it among the data lines of the file, and the
+// rows left out are recorded there under that number.
+type RowSelect struct {
+	Column  string
+	Extract map[string]struct{}
+	Exclude map[string]struct{}
+	Rejects *Rejects
+
+	// Rows counts the rows read, and Dropped the rows left out by reason.
+	Rows    int64
+	Dropped map[string]int
+}
+
+// schema returns the schema of the records apply makes from records of
+// schema.
+func (s *RowSelect) schema(schema *arrow.Schema) *arrow.Schema {
+	if s.Rejects == nil {
+		return schema
+	}
+	fields := append(schema.Fields(), arrow.Field{Name: "ROW", Type: arrow.PrimitiveTypes.Float64, Nullable: true})
+	return arrow.NewSchema(fields, nil)
+}
+
+// apply returns the rows of rec that s keeps, with the schema of s.schema,
+// or nil if it keeps none.
+func (s *RowSelect) apply(rec array.Record, schema *arrow.Schema, mem memory.Allocator) (array.Record, error) {
+	if s.Dropped == nil {
+		s.Dropped = map[string]int{}
+	}
+	n := int(rec.NumRows())
+	first := s.Rows
+	s.Rows += int64(n)
+	keep := make([]bool, n)
+	for i := range keep {
+		keep[i] = true
+	}
+	if s.Extract != nil || s.Exclude != nil {
+		snps := chunkStrings(rec.Column(schema.FieldIndices(s.Column)[0]))
+		for i, snp := range snps {
+			reason := ""
+			if _, ok := s.Extract[snp]; s.Extract != nil && !ok {
+				reason = "extract"
+			} else if _, ok := s.Exclude[snp]; ok {
+				reason = "exclude"
+			}
+			if reason == "" {
+				continue
+			}
+			keep[i] = false
+			s.Dropped[reason]++
+			if s.Rejects != nil {
+				s.Rejects.add(float64(first+int64(i)), snp, reason)
+			}
+		}
+	}
+	cols := rec.Columns()
+	if s.Rejects != nil {
+		b := array.NewFloat64Builder(mem)
+		defer b.Release()
+		for i := 0; i < n; i++ {
+			b.Append(float64(first + int64(i)))
+		}
+		rows := b.NewArray()
+		defer rows.Release()
+		cols = append(cols[:len(cols):len(cols)], rows)
+	}
+	all := array.NewRecord(schema, cols, int64(n))
+	defer all.Release()
+	return filterRecord(all, keep, mem)
+}
@@ -0,0 +1,46 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+)
+
+func TestArrowCSVSelect(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "in.tsv")
+	data := "MarkerName\tP\nrs1\t0.1\nrs2\t0.2\nrs3\t0.3\nrs4\t0.4\nNA\t0.5\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sel := &RowSelect{
+		Column:  "SNP",
+		Extract: map[string]struct{}{"rs1": {}, "rs3": {}, "rs4": {}},
+		Exclude: map[string]struct{}{"rs4": {}},
+		Rejects: NewRejects(),
+	}
+	ctypes := map[string]arrow.DataType{"SNP": arrow.BinaryTypes.String, "P": arrow.PrimitiveTypes.Float64}
+	table, _ := ArrowCSVSelect(file, []string{"SNP", "P"}, '\t', ctypes, sel)
+	if table == nil {
+		t.Fatal("could not read", file)
+	}
+
+	if sel.Rows != 5 {
+		t.Errorf("read %d rows, want 5", sel.Rows)
+	}
+	if sel.Dropped["extract"] != 2 || sel.Dropped["exclude"] != 1 {
+		t.Errorf("dropped %v, want 2 extract and 1 exclude", sel.Dropped)
+	}
+	snps := StringValues(table.Column(ColumnIndex(table, "SNP")))
+	rows := Float64Values(table.Column(ColumnIndex(table, "ROW")))
+	if len(snps) != 2 || snps[0] != "rs1" || snps[1] != "rs3" || rows[0] != 0 || rows[1] != 2 {
+		t.Errorf("kept %v at rows %v, want [rs1 rs3] at [0 2]", snps, rows)
+	}
+	for row, want := range map[int64]string{1: "extract", 3: "exclude", 4: "extract"} {
+		if got := sel.Rejects.rows[row].reason; got != want {
+			t.Errorf("row %d recorded as %q, want %q", row, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ops
+
+import (
+	"log"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/snpmap"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// MapSNPs sets the SNP column of table to the rsIDs found in idx by CHR, BP
+// and, when present, A1 and A2. Unmapped rows keep their SNP, or are dropped
+// if table has no SNP column, recorded in rejects as snp_map_unmapped. It
+// returns the number of unmapped rows.
+func MapSNPs(table array.Table, idx *snpmap.Index, rejects *Rejects) (new_table array.Table, unmapped int, err error) {
+	defer utils.TimeTrack(time.Now(), "MapSNPs")
+
+	chr := StringValues(table.Column(ColumnIndex(table, "CHR")))
+	bp := Float64Values(table.Column(ColumnIndex(table, "BP")))
+	queries := make([]snpmap.Query, len(chr))
+	for i := range queries {
+		queries[i] = snpmap.Query{Chr: chr[i], Pos: int(bp[i])}
+	}
+	if a1, a2 := ColumnIndex(table, "A1"), ColumnIndex(table, "A2"); a1 >= 0 && a2 >= 0 {
+		for i, v := range StringValues(table.Column(a1)) {
+			queries[i].A1 = v
+		}
+		for i, v := range StringValues(table.Column(a2)) {
+			queries[i].A2 = v
+		}
+	}
+	rsids, err := idx.Lookup(queries)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snps []string
+	if i := ColumnIndex(table, "SNP"); i >= 0 {
+		snps = StringValues(table.Column(i))
+	}
+	reasons := make([]string, len(rsids))
+	for i, rsid := range rsids {
+		switch {
+		case rsid != "":
+		case snps != nil:
+			rsids[i] = snps[i]
+			unmapped++
+		default:
+			reasons[i] = "snp_map_unmapped"
+			unmapped++
+		}
+	}
+	new_table = SetStringColumn(table, "SNP", rsids)
+	if snps == nil {
+		log.Println("Dropping", unmapped, "rows without an rsID.")
+		new_table, err = rejects.Filter(new_table, reasons)
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package ops
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// ApplySourceColumns resolves the tool specific columns a --source preset
+// reads: rows whose TEST is not the additive test are dropped, and A2 is
+// set to whichever of REF and ALT is not A1 when the file has no other
+// allele column. TEST, REF and ALT are then removed. Dropped rows are
+// recorded in rejects as "test".
+func ApplySourceColumns(table array.Table, rejects *Rejects) (new_table array.Table, dropped int, err error) {
+	defer utils.TimeTrack(time.Now(), "ApplySourceColumns")
+
+	new_table = table
+	if i := ColumnIndex(new_table, "TEST"); i >= 0 {
+		tests := StringValues(new_table.Column(i))
+		reasons := make([]string, len(tests))
+		for j, test := range tests {
+			if !strings.EqualFold(test, "ADD") {
+				reasons[j] = "test"
+				dropped++
+			}
+		}
+		new_table, err = rejects.Filter(new_table, reasons)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	ref_idx, alt_idx := ColumnIndex(new_table, "REF"), ColumnIndex(new_table, "ALT")
+	if ref_idx >= 0 && alt_idx >= 0 && ColumnIndex(new_table, "A1") >= 0 && ColumnIndex(new_table, "A2") < 0 {
+		a1s := StringValues(new_table.Column(ColumnIndex(new_table, "A1")))
+		refs := StringValues(new_table.Column(ref_idx))
+		alts := StringValues(new_table.Column(alt_idx))
+		a2s := make([]string, len(a1s))
+		for j, a1 := range a1s {
+			a2s[j] = refs[j]
+			if strings.EqualFold(a1, refs[j]) {
+				a2s[j] = alts[j]
+			}
+		}
+		new_table = SetStringColumn(new_table, "A2", a2s)
+	}
+	new_table = DropColumns(new_table, []string{"TEST", "REF", "ALT"})
+	return
+}
@@ -0,0 +1,139 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// readSourceTSV reads data as munge does for the source it detects: the
+// columns are renamed with the source's mapping, the source columns are
+// resolved and the default QC is run.
+func readSourceTSV(t *testing.T, data string) (source string, snps []string, dropped map[string]int) {
+	file := filepath.Join(t.TempDir(), "in.tsv")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	header := parse.CleanNames(strings.Split(strings.SplitN(data, "\n", 2)[0], "\t"))
+	source, err := parse.DetectSource("auto", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	default_cnames, extended_cnames := parse.SourceCnames(source, header)
+	cnames := map[string]string{}
+	ctypes := map[string]arrow.DataType{}
+	for _, c := range header {
+		cname, ok := default_cnames[c]
+		if !ok {
+			cname, ok = extended_cnames[c]
+		}
+		if ok {
+			cnames[c] = cname
+		}
+		if cname != "P" && utils.InList(cname, constants.Numeric_cols) {
+			ctypes[c] = arrow.PrimitiveTypes.Float64
+		} else {
+			ctypes[c] = arrow.BinaryTypes.String
+		}
+	}
+	table, _ := ArrowCSV(file, header, '\t', ctypes)
+	if table == nil {
+		t.Fatal("could not read", file)
+	}
+	table, test_dropped, err := ApplySourceColumns(RenameColumns(table, cnames), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"TEST", "REF", "ALT"} {
+		if ColumnIndex(table, c) >= 0 {
+			t.Errorf("%s: column %s was not removed", source, c)
+		}
+	}
+	table, dropped, err = Pipeline(DefaultFilters(QCOptions{MAFMin: 0.01, INFOMin: 0.9})).Run(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test_dropped > 0 {
+		dropped["test"] = test_dropped
+	}
+	snps = StringValues(table.Column(ColumnIndex(table, "SNP")))
+	for _, c := range []string{"A1", "A2", "FRQ"} {
+		if ColumnIndex(table, c) < 0 {
+			t.Errorf("%s: no %s column", source, c)
+		}
+	}
+	return
+}
+
+func TestSourcePresets(t *testing.T) {
+	for _, c := range []struct {
+		want    string
+		data    string
+		snps    []string
+		dropped map[string]int
+	}{
+		// A2 is the one of REF and ALT that is not A1; rs3 gets an indel A2
+		// from ALT, which the allele filter drops
+		{"plink2", "#CHROM\tPOS\tID\tREF\tALT\tA1\tTEST\tOBS_CT\tBETA\tSE\tP\tA1_FREQ\n" +
+			"1\t100\trs1\tA\tG\tG\tADD\t1000\t0.1\t0.05\t0.01\t0.3\n" +
+			"1\t200\trs1\tA\tG\tG\tSEX\t1000\t0.1\t0.05\t0.01\t0.3\n" +
+			"1\t300\trs2\tC\tA\tC\tADD\t1000\t0.1\t0.05\t0.01\t0.3\n" +
+			"1\t400\trs3\tA\tAT\tA\tADD\t1000\t0.1\t0.05\t0.01\t0.3\n" +
+			"1\t600\trs5\tA\tC\tA\tADD\t1000\t0.1\t0.05\t0.01\t0.005\n",
+			[]string{"rs1", "rs2"}, map[string]int{"test": 1, "alleles": 1, "maf": 1}},
+		{"regenie", "CHROM\tGENPOS\tID\tALLELE0\tALLELE1\tA1FREQ\tN\tTEST\tBETA\tSE\tLOG10P\n" +
+			"1\t100\trs1\tA\tG\t0.3\t1000\tADD\t0.1\t0.05\t2\n" +
+			"1\t100\trs1\tA\tG\t0.3\t1000\tDOM\t0.1\t0.05\t2\n" +
+			"1\t200\trs2\tA\tC\t0.995\t1000\tADD\t0.1\t0.05\t2\n" +
+			"1\t300\trs3\tA\tC\t0.3\t1000\tADD\t0.1\t0.05\t-1\n",
+			[]string{"rs1"}, map[string]int{"test": 1, "maf": 1, "p": 1}},
+		// Allele2 is the effect allele
+		{"saige", "CHR\tPOS\tMarkerID\tAllele1\tAllele2\tAF_Allele2\timputationInfo\tN\tBETA\tSE\tp.value\n" +
+			"1\t100\trs1\tA\tG\t0.3\t0.95\t1000\t0.1\t0.05\t0.01\n" +
+			"1\t200\trs2\tA\tC\t0.3\t0.5\t1000\t0.1\t0.05\t0.01\n",
+			[]string{"rs1"}, map[string]int{"info": 1}},
+		// P_BOLT_LMM is used over P_BOLT_LMM_INF
+		{"bolt", "SNP\tCHR\tBP\tGENPOS\tALLELE1\tALLELE0\tA1FREQ\tBETA\tSE\tP_BOLT_LMM_INF\tP_BOLT_LMM\n" +
+			"rs1\t1\t100\t0.1\tG\tA\t0.3\t0.1\t0.05\t0.01\t0.02\n" +
+			"rs2\t1\t200\t0.2\tC\tA\t0.3\t0.1\t0.05\t0.01\t2\n",
+			[]string{"rs1"}, map[string]int{"p": 1}},
+	} {
+		source, snps, dropped := readSourceTSV(t, c.data)
+		if source != c.want {
+			t.Errorf("detected source %q, want %q", source, c.want)
+			continue
+		}
+		if !reflect.DeepEqual(snps, c.snps) {
+			t.Errorf("%s: kept %v, want %v", source, snps, c.snps)
+		}
+		for reason, n := range dropped {
+			if n == 0 {
+				delete(dropped, reason)
+			}
+		}
+		if !reflect.DeepEqual(dropped, c.dropped) {
+			t.Errorf("%s: dropped %v, want %v", source, dropped, c.dropped)
+		}
+	}
+}
+
+func TestApplySourceColumnsAlleles(t *testing.T) {
+	table := readTestTSV(t, "SNP\tA1\tREF\tALT\nrs1\tG\tA\tG\nrs2\tc\tC\tT\n")
+	table = SetStringColumn(table, "A1", []string{"G", "c"})
+	table = SetStringColumn(table, "REF", []string{"A", "C"})
+	table = SetStringColumn(table, "ALT", []string{"G", "T"})
+	table, dropped, err := ApplySourceColumns(table, nil)
+	if err != nil || dropped != 0 {
+		t.Fatal(dropped, err)
+	}
+	if got := StringValues(table.Column(ColumnIndex(table, "A2"))); !reflect.DeepEqual(got, []string{"A", "T"}) {
+		t.Errorf("A2 %v, want [A T]", got)
+	}
+}
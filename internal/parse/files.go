@@ -0,0 +1,114 @@
+package parse
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type compressedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (c *compressedFile) Close() (err error) {
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if cerr := c.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// Open opens a plain, gzip (or bgzip) or bzip2 compressed file, choosing the
+// decompressor from the file extension.
+func Open(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".bgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &compressedFile{Reader: gz, closers: []io.Closer{f, gz}}, nil
+	case strings.HasSuffix(file, ".bz2"):
+		return &compressedFile{Reader: bzip2.NewReader(f), closers: []io.Closer{f}}, nil
+	}
+	return f, nil
+}
+
+type compressedWriter struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (c *compressedWriter) Close() (err error) {
+	for _, closer := range c.closers {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// Create creates file for writing, gzip compressing the output if the name
+// ends in .gz.
+func Create(file string) (io.WriteCloser, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file, ".gz") {
+		gz := gzip.NewWriter(f)
+		return &compressedWriter{Writer: gz, closers: []io.Closer{gz, f}}, nil
+	}
+	return f, nil
+}
+
+// IsVCF reports whether file is a VCF, by its extension or else by its
+// ##fileformat line.
+func IsVCF(file string) bool {
+	for _, suffix := range []string{".vcf", ".vcf.gz", ".vcf.bgz", ".vcf.bz2"} {
+		if strings.HasSuffix(strings.ToLower(file), suffix) {
+			return true
+		}
+	}
+	f, err := Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len("##fileformat=VCF"))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return string(head) == "##fileformat=VCF"
+}
+
+// WhichCompression returns the first of prefix.bz2, prefix.gz and prefix that
+// exists on disk.
+func WhichCompression(prefix string) (string, error) {
+	for _, suffix := range []string{".bz2", ".gz", ""} {
+		if _, err := os.Stat(prefix + suffix); err == nil {
+			return prefix + suffix, nil
+		}
+	}
+	return "", fmt.Errorf("could not find %s[.gz|.bz2]", prefix)
+}
+
+// SubChr substitutes chr for the '@' in an LDSC-style file prefix, appending
+// the chromosome number if the prefix has no '@'.
+func SubChr(prefix string, chr int) string {
+	if !strings.Contains(prefix, "@") {
+		prefix += "@"
+	}
+	return strings.ReplaceAll(prefix, "@", strconv.Itoa(chr))
+}
@@ -2,23 +2,26 @@ package parse
 
 import (
 	"bufio"
+	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/awilliamson10/golink/internal/constants"
 	"github.com/awilliamson10/golink/internal/utils"
 	parquet "github.com/kostya-sh/parquet-go/parquet"
 )
 
 func ReadHeader(file string, delimiter string) (header []string, err error) {
-	f, err := os.Open(file)
+	f, err := Open(file)
 	if err != nil {
 		return
 	}
 	defer f.Close()
-	parquet.ReadFileMetaData(f)
 	scanner := bufio.NewScanner(f)
 	scanner.Scan()
-	header = strings.Split(scanner.Text(), delimiter)
+	header = strings.Split(strings.TrimRight(scanner.Text(), "\r"), delimiter)
 	return
 }
 
@@ -60,6 +63,75 @@ func CleanName(name string) string {
 	return strings.ToUpper(ws)
 }
 
+// IsGWASSSF reports whether the cleaned column names are those of a GWAS
+// Catalog GWAS-SSF file.
+func IsGWASSSF(cnames []string) bool {
+	for _, name := range constants.Gwas_ssf_cnames {
+		if !utils.InList(name, cnames) {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectSource returns the --source preset to use: source itself, or with
+// "auto" the first of constants.Source_names whose columns are all in
+// cnames, or "" if none match.
+func DetectSource(source string, cnames []string) (string, error) {
+	switch source {
+	case "", "none":
+		return "", nil
+	case "auto":
+		for _, name := range constants.Source_names {
+			match := true
+			for _, c := range constants.Sources[name].Detect {
+				match = match && utils.InList(c, cnames)
+			}
+			if match {
+				return name, nil
+			}
+		}
+		return "", nil
+	}
+	if _, ok := constants.Sources[source]; !ok {
+		return "", fmt.Errorf("unknown --source %s, expected auto, none or one of %s", source, strings.Join(constants.Source_names, ", "))
+	}
+	return source, nil
+}
+
+// SourceCnames returns Default_cnames and Extended_cnames with the column
+// mappings of source, as detected by DetectSource, applied. The first of the
+// source's P columns in cnames is mapped to P.
+func SourceCnames(source string, cnames []string) (default_cnames map[string]string, extended_cnames map[string]string) {
+	default_cnames = map[string]string{}
+	extended_cnames = map[string]string{}
+	for key, value := range constants.Default_cnames {
+		default_cnames[key] = value
+	}
+	for key, value := range constants.Extended_cnames {
+		extended_cnames[key] = value
+	}
+	s, ok := constants.Sources[source]
+	if !ok {
+		return
+	}
+	for key, value := range s.Cnames {
+		delete(extended_cnames, key)
+		default_cnames[key] = value
+	}
+	for key, value := range s.Extended {
+		delete(default_cnames, key)
+		extended_cnames[key] = value
+	}
+	for _, p := range s.P {
+		if utils.InList(p, cnames) {
+			default_cnames[p] = "P"
+			break
+		}
+	}
+	return
+}
+
 func ParseFlagCnames(args map[string]string, cnames []string) map[string]string {
 	var cname_options = map[string]string{
 		CleanName(args["nstudy"]):  "NSTUDY",
@@ -78,8 +150,8 @@ func ParseFlagCnames(args map[string]string, cnames []string) map[string]string
 			cname_options[CleanName(info)] = "INFO"
 		}
 	}
-	if args["signedsumstats"] != "" {
-		ss := strings.Split(args["signedsumstats"], ",")
+	if args["signed-sumstats"] != "" {
+		ss := strings.Split(args["signed-sumstats"], ",")
 		cname := CleanName(ss[0])
 		cname_options["NULL_VALUE"] = ss[1]
 		cname_options[cname] = "SIGNED_SUMSTAT"
@@ -105,17 +177,57 @@ func GetCnameMap(flag map[string]string, dnames map[string]string, ignore []stri
 }
 
 func FilterP(p float64) bool {
-	if (p >= 1) || (p < 0) {
+	if (p > 1) || (p <= 0) || math.IsNaN(p) {
+		return true
+	}
+	return false
+}
+
+// FilterPString is FilterP for P read as text, which keeps P-values below
+// the float64 range.
+func FilterPString(p string) bool {
+	_, log10p, err := ParseP(p)
+	if err != nil {
+		return true
+	}
+	return FilterLog10P(log10p)
+}
+
+func FilterLog10P(log10p float64) bool {
+	if log10p < 0 || math.IsInf(log10p, 0) || math.IsNaN(log10p) {
 		return true
 	}
 	return false
 }
 
+// ParseP parses a P-value and its -log10. P-values too small for a float64,
+// such as 1e-400, parse to a P of 0 with -log10(P) taken from the mantissa
+// and exponent.
+func ParseP(s string) (p float64, log10p float64, err error) {
+	s = strings.TrimSpace(s)
+	p, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return
+	}
+	log10p = 0 - math.Log10(p) // avoid -0 for P = 1
+	// ParseFloat rounds values below the float64 range to 0
+	i := strings.IndexAny(s, "eE")
+	if p != 0 || i < 0 {
+		return
+	}
+	mantissa, merr := strconv.ParseFloat(s[:i], 64)
+	exponent, eerr := strconv.Atoi(s[i+1:])
+	if merr != nil || eerr != nil || mantissa == 0 {
+		return
+	}
+	return 0, -(math.Log10(mantissa) + float64(exponent)), nil
+}
+
 func FilterFRQ(frq float64, mafmin float64) bool {
 	if (frq > 1) || (frq < 0) {
 		return true
 	}
-	if frq < mafmin {
+	if frq <= mafmin {
 		return true
 	}
 	return false
@@ -125,7 +237,7 @@ func FilterINFO(info float64, infomin float64) bool {
 	if (info > 2) || (info < 0) {
 		return true
 	}
-	if info <= infomin {
+	if info < infomin {
 		return true
 	}
 	return false
@@ -137,3 +249,42 @@ func FilterAllele(allele string) bool {
 	}
 	return false
 }
+
+func FilterSE(se float64) bool {
+	if se <= 0 || math.IsInf(se, 0) || math.IsNaN(se) {
+		return true
+	}
+	return false
+}
+
+func FilterBP(bp float64) bool {
+	if bp <= 0 || bp != math.Trunc(bp) {
+		return true
+	}
+	return false
+}
+
+func FilterCHR(chr string) bool {
+	if !utils.InList(NormalizeCHR(chr), constants.Chromosomes) {
+		return true
+	}
+	return false
+}
+
+// NormalizeCHR strips a leading "chr" and maps the numeric codes PLINK uses
+// for the sex chromosomes and mitochondria to their names.
+func NormalizeCHR(chr string) string {
+	chr = strings.ToUpper(strings.TrimSpace(chr))
+	chr = strings.TrimPrefix(chr, "CHR")
+	switch chr {
+	case "23":
+		return "X"
+	case "24":
+		return "Y"
+	case "25":
+		return "XY"
+	case "26", "M":
+		return "MT"
+	}
+	return chr
+}
@@ -0,0 +1,307 @@
+// Package report renders the QC report of munged sumstats as a
+// self-contained HTML file with SVG plots.
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/ops"
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+const (
+	plot_width   = 900.0
+	plot_height  = 320.0
+	small_width  = 440.0
+	small_height = 300.0
+	// points at least this significant are always drawn
+	keep_log10p = 5.0
+	// genome-wide significance, 5e-8
+	gws_log10p = 7.30103
+)
+
+// WriteHTML writes the QC report of table to file: the summary and drop
+// counts of qc, Manhattan and QQ plots, P-value, N histograms and, given
+// the --merge-alleles reference, FRQ against the reference frequency.
+func WriteHTML(file string, table array.Table, qc *ops.Report, ref map[string]ops.MergeAllele) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>golink QC: %s</title>
+<style>body{font-family:sans-serif;margin:2em;color:#222}table{border-collapse:collapse;margin-bottom:1em}td,th{border:1px solid #ccc;padding:3px 10px;text-align:left}td.n{text-align:right}.row{display:flex;flex-wrap:wrap;gap:10px}</style>
+</head><body>
+<h1>QC report: %s</h1>
+`, html.EscapeString(qc.Sumstats), html.EscapeString(qc.Sumstats))
+	writeSummary(w, qc)
+
+	log10p := log10PColumn(table)
+	if log10p == nil {
+		fmt.Fprintln(w, "<p>No P-values to plot.</p>")
+	} else {
+		fmt.Fprintln(w, "<h2>Association</h2>")
+		if ops.ColumnIndex(table, "CHR") >= 0 && ops.ColumnIndex(table, "BP") >= 0 {
+			chr := ops.StringValues(table.Column(ops.ColumnIndex(table, "CHR")))
+			bp := ops.Float64Values(table.Column(ops.ColumnIndex(table, "BP")))
+			fmt.Fprintln(w, manhattan(chr, bp, log10p))
+		} else {
+			fmt.Fprintln(w, "<p>No CHR and BP columns for a Manhattan plot; munge with --keep-cols chr,bp.</p>")
+		}
+		fmt.Fprintln(w, `<div class="row">`)
+		fmt.Fprintln(w, qq(log10p))
+		fmt.Fprintln(w, pHistogram(log10p))
+		fmt.Fprintln(w, `</div>`)
+	}
+
+	fmt.Fprintln(w, `<div class="row">`)
+	if i := ops.ColumnIndex(table, "N"); i >= 0 {
+		fmt.Fprintln(w, histogram("Sample size", "N", ops.Float64Values(table.Column(i)), 30))
+	}
+	if ref != nil && ops.ColumnIndex(table, "FRQ") >= 0 && ops.ColumnIndex(table, "SNP") >= 0 {
+		fmt.Fprintln(w, frqScatter(table, ref))
+	}
+	fmt.Fprintln(w, `</div>`)
+	fmt.Fprintln(w, "</body></html>")
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeSummary(w *bufio.Writer, qc *ops.Report) {
+	fmt.Fprintln(w, "<h2>Summary</h2><table>")
+	row := func(name string, value string) {
+		fmt.Fprintf(w, "<tr><th>%s</th><td class=\"n\">%s</td></tr>\n", html.EscapeString(name), html.EscapeString(value))
+	}
+	optional := func(v *float64) string {
+		if v == nil {
+			return "NA"
+		}
+		return fmt.Sprintf("%.4g", *v)
+	}
+	if qc.InputRows > 0 {
+		row("Input rows", fmt.Sprint(qc.InputRows))
+	}
+	row("Output rows", fmt.Sprint(qc.OutputRows))
+	row("Mean chi²", optional(qc.MeanChi2))
+	row("Lambda GC", optional(qc.LambdaGC))
+	row("Max chi²", optional(qc.MaxChi2))
+	if qc.MeanChi2 != nil {
+		row("Genome-wide significant", fmt.Sprint(qc.GenomeWideSig))
+	}
+	if qc.SignedStat != "" {
+		row("Median "+qc.SignedStat, optional(qc.MedianSignedStat))
+	}
+	fmt.Fprintln(w, "</table>")
+	if qc.MeanChi2 != nil && *qc.MeanChi2 < 1.02 {
+		fmt.Fprintln(w, "<p><b>Warning:</b> mean chi² may be too small.</p>")
+	}
+
+	if len(qc.Chromosomes) > 0 {
+		fmt.Fprintln(w, "<h2>Chromosomes</h2><table><tr><th>CHR</th><th>SNPs</th><th>Mean chi²</th><th>Lambda GC</th><th>Max chi²</th><th>Genome-wide significant</th></tr>")
+		for _, c := range qc.Chromosomes {
+			fmt.Fprintf(w, "<tr><td>%s</td><td class=\"n\">%d</td><td class=\"n\">%.4g</td><td class=\"n\">%.4g</td><td class=\"n\">%.4g</td><td class=\"n\">%d</td></tr>\n",
+				html.EscapeString(c.CHR), c.SNPs, c.MeanChi2, c.LambdaGC, c.MaxChi2, c.GenomeWideSig)
+		}
+		fmt.Fprintln(w, "</table>")
+	}
+
+	if len(qc.Dropped) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(qc.Dropped))
+	for reason := range qc.Dropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return qc.Dropped[reasons[i]] > qc.Dropped[reasons[j]] })
+	fmt.Fprintln(w, "<h2>Dropped rows</h2><table><tr><th>Reason</th><th>Rows</th></tr>")
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "<tr><td>%s</td><td class=\"n\">%d</td></tr>\n", html.EscapeString(reason), qc.Dropped[reason])
+	}
+	fmt.Fprintln(w, "</table>")
+}
+
+// log10PColumn returns -log10(P) of each row, from LOG10P if there is one,
+// or nil without P-values.
+func log10PColumn(table array.Table) []float64 {
+	if i := ops.ColumnIndex(table, "LOG10P"); i >= 0 {
+		return ops.Float64Values(table.Column(i))
+	}
+	if i := ops.ColumnIndex(table, "P"); i >= 0 {
+		return ops.Log10PValues(table.Column(i))
+	}
+	return nil
+}
+
+func maxFinite(values []float64, floor float64) float64 {
+	max := floor
+	for _, v := range values {
+		if !math.IsInf(v, 0) && v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+func manhattan(chr []string, bp []float64, log10p []float64) string {
+	// chromosomes are laid end to end in the order of constants.Chromosomes
+	ends := map[string]float64{}
+	for i, c := range chr {
+		c = parse.NormalizeCHR(c)
+		chr[i] = c
+		ends[c] = math.Max(ends[c], bp[i])
+	}
+	order := []string{}
+	for _, c := range constants.Chromosomes {
+		if _, ok := ends[c]; ok {
+			order = append(order, c)
+		}
+	}
+	offset := map[string]float64{}
+	total := 0.0
+	ticks := map[float64]string{}
+	for _, c := range order {
+		offset[c] = total
+		ticks[total+ends[c]/2] = c
+		total += ends[c]
+	}
+
+	ymax := math.Ceil(maxFinite(log10p, gws_log10p) + 0.5)
+	p := newPlot("Manhattan plot", plot_width, plot_height, 0, total, 0, ymax)
+	p.axes("Chromosome", "-log10(P)", ticks)
+	p.line(0, gws_log10p, total, gws_log10p, "#c00", true)
+	xs, ys := map[string][]float64{}, map[string][]float64{}
+	for i, c := range chr {
+		if _, ok := offset[c]; ok {
+			xs[c] = append(xs[c], offset[c]+bp[i])
+			ys[c] = append(ys[c], math.Min(log10p[i], ymax))
+		}
+	}
+	for k, c := range order {
+		tx, ty := p.thin(xs[c], ys[c], func(i int) bool { return ys[c][i] >= keep_log10p })
+		color := "#2b5c8a"
+		if k%2 == 1 {
+			color = "#7fa7cc"
+		}
+		p.points(tx, ty, color)
+	}
+	return p.String()
+}
+
+func qq(log10p []float64) string {
+	observed := make([]float64, 0, len(log10p))
+	for _, v := range log10p {
+		if !math.IsNaN(v) {
+			observed = append(observed, v)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(observed)))
+	n := float64(len(observed))
+	expected := make([]float64, len(observed))
+	for i := range expected {
+		expected[i] = -math.Log10((float64(i) + 0.5) / n)
+	}
+	xmax := math.Ceil(maxFinite(expected, 1))
+	ymax := math.Ceil(maxFinite(observed, xmax))
+	for i := range observed {
+		observed[i] = math.Min(observed[i], ymax)
+	}
+	p := newPlot("QQ plot", small_width, small_height, 0, xmax, 0, ymax)
+	p.axes("Expected -log10(P)", "Observed -log10(P)", nil)
+	p.line(0, 0, xmax, xmax, "#c00", true)
+	xs, ys := p.thin(expected, observed, func(i int) bool { return observed[i] >= keep_log10p })
+	p.points(xs, ys, "#2b5c8a")
+	return p.String()
+}
+
+func pHistogram(log10p []float64) string {
+	const bins = 20
+	counts := make([]float64, bins)
+	for _, v := range log10p {
+		if math.IsNaN(v) {
+			continue
+		}
+		counts[int(math.Min(math.Pow(10, -v)*bins, bins-1))]++
+	}
+	return bars("P-value histogram", "P", counts, 0, 1)
+}
+
+// histogram plots the distribution of the values that are not NaN.
+func histogram(title string, xlabel string, values []float64, bins int) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return ""
+	}
+	if hi == lo {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	counts := make([]float64, bins)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			counts[int(math.Min((v-lo)/(hi-lo)*float64(bins), float64(bins-1)))]++
+		}
+	}
+	return bars(title, xlabel, counts, lo, hi)
+}
+
+func bars(title string, xlabel string, counts []float64, lo float64, hi float64) string {
+	ymax := 0.0
+	for _, c := range counts {
+		ymax = math.Max(ymax, c)
+	}
+	p := newPlot(title, small_width, small_height, lo, hi, 0, ymax*1.05)
+	p.axes(xlabel, "SNPs", nil)
+	width := (hi - lo) / float64(len(counts))
+	for i, c := range counts {
+		p.bar(lo+float64(i)*width, lo+float64(i+1)*width, c, "#2b5c8a")
+	}
+	return p.String()
+}
+
+func frqScatter(table array.Table, ref map[string]ops.MergeAllele) string {
+	snps := ops.StringValues(table.Column(ops.ColumnIndex(table, "SNP")))
+	frq := ops.Float64Values(table.Column(ops.ColumnIndex(table, "FRQ")))
+	var a1s []string
+	if i := ops.ColumnIndex(table, "A1"); i >= 0 {
+		a1s = ops.StringValues(table.Column(i))
+	}
+	xs, ys := []float64{}, []float64{}
+	for i, snp := range snps {
+		r, ok := ref[snp]
+		if !ok || math.IsNaN(r.FRQ) {
+			continue
+		}
+		ref_frq := r.FRQ
+		if a1s != nil && !strings.EqualFold(a1s[i], r.A1) {
+			ref_frq = 1 - ref_frq
+		}
+		xs = append(xs, ref_frq)
+		ys = append(ys, frq[i])
+	}
+	if len(xs) == 0 {
+		return ""
+	}
+	p := newPlot("Allele frequency", small_width, small_height, 0, 1, 0, 1)
+	p.axes("Reference FRQ of A1", "FRQ", nil)
+	p.line(0, 0, 1, 1, "#c00", true)
+	xs, ys = p.thin(xs, ys, func(i int) bool { return false })
+	p.points(xs, ys, "#2b5c8a")
+	return p.String()
+}
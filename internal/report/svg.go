@@ -0,0 +1,161 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	margin_left   = 60.0
+	margin_right  = 20.0
+	margin_top    = 30.0
+	margin_bottom = 45.0
+)
+
+// plot draws an SVG chart mapping the data range [x0, x1] x [y0, y1] onto
+// the area inside the margins.
+type plot struct {
+	b      strings.Builder
+	w, h   float64
+	x0, x1 float64
+	y0, y1 float64
+}
+
+func newPlot(title string, w float64, h float64, x0 float64, x1 float64, y0 float64, y1 float64) *plot {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	p := &plot{w: w, h: h, x0: x0, x1: x1, y0: y0, y1: y1}
+	fmt.Fprintf(&p.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="11">`, w, h, w, h)
+	fmt.Fprintf(&p.b, `<text x="%g" y="18" text-anchor="middle" font-size="13" font-weight="bold">%s</text>`, w/2, html.EscapeString(title))
+	return p
+}
+
+// X and Y return the pixel coordinates of data values.
+func (p *plot) X(v float64) float64 {
+	return margin_left + (v-p.x0)/(p.x1-p.x0)*(p.w-margin_left-margin_right)
+}
+
+func (p *plot) Y(v float64) float64 {
+	return p.h - margin_bottom - (v-p.y0)/(p.y1-p.y0)*(p.h-margin_top-margin_bottom)
+}
+
+// axes draws the axes with their labels, and tick marks at round values or,
+// on the x axis, at the labelled positions of xticks if given.
+func (p *plot) axes(xlabel string, ylabel string, xticks map[float64]string) {
+	left, right := margin_left, p.w-margin_right
+	top, bottom := margin_top, p.h-margin_bottom
+	fmt.Fprintf(&p.b, `<path d="M%g %gV%gH%g" fill="none" stroke="#333"/>`, left, top, bottom, right)
+	if xticks == nil {
+		xticks = map[float64]string{}
+		for _, t := range niceTicks(p.x0, p.x1, 6) {
+			xticks[t] = formatTick(t)
+		}
+	}
+	positions := make([]float64, 0, len(xticks))
+	for t := range xticks {
+		positions = append(positions, t)
+	}
+	sort.Float64s(positions)
+	for _, t := range positions {
+		label := xticks[t]
+		x := p.X(t)
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%g" x2="%.1f" y2="%g" stroke="#333"/>`, x, bottom, x, bottom+4)
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%g" text-anchor="middle">%s</text>`, x, bottom+16, html.EscapeString(label))
+	}
+	for _, t := range niceTicks(p.y0, p.y1, 5) {
+		y := p.Y(t)
+		fmt.Fprintf(&p.b, `<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="#333"/>`, left-4, y, left, y)
+		fmt.Fprintf(&p.b, `<text x="%g" y="%.1f" text-anchor="end">%s</text>`, left-6, y+4, formatTick(t))
+	}
+	fmt.Fprintf(&p.b, `<text x="%g" y="%g" text-anchor="middle">%s</text>`, (left+right)/2, p.h-8, html.EscapeString(xlabel))
+	fmt.Fprintf(&p.b, `<text transform="translate(14 %g) rotate(-90)" text-anchor="middle">%s</text>`, (top+bottom)/2, html.EscapeString(ylabel))
+}
+
+// points draws a circle at each (x, y) in data coordinates.
+func (p *plot) points(xs []float64, ys []float64, color string) {
+	if len(xs) == 0 {
+		return
+	}
+	fmt.Fprintf(&p.b, `<g fill="%s">`, color)
+	for i := range xs {
+		fmt.Fprintf(&p.b, `<circle cx="%.1f" cy="%.1f" r="1.6"/>`, p.X(xs[i]), p.Y(ys[i]))
+	}
+	p.b.WriteString(`</g>`)
+}
+
+// line draws a line between two points in data coordinates.
+func (p *plot) line(x0 float64, y0 float64, x1 float64, y1 float64, color string, dashed bool) {
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="4 3"`
+	}
+	fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"%s/>`, p.X(x0), p.Y(y0), p.X(x1), p.Y(y1), color, dash)
+}
+
+// bar draws a bar from y0 to y over [x0, x1) in data coordinates.
+func (p *plot) bar(x0 float64, x1 float64, y float64, color string) {
+	top, bottom := p.Y(y), p.Y(p.y0)
+	fmt.Fprintf(&p.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, p.X(x0), top, math.Max(p.X(x1)-p.X(x0)-1, 1), bottom-top, color)
+}
+
+func (p *plot) String() string {
+	return p.b.String() + `</svg>`
+}
+
+// thin keeps one point per pixel of the plot, and every point for which
+// keep is true, so plots of millions of points stay small.
+func (p *plot) thin(xs []float64, ys []float64, keep func(i int) bool) (tx []float64, ty []float64) {
+	seen := map[[2]int]bool{}
+	for i := range xs {
+		if math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+			continue
+		}
+		if !keep(i) {
+			pixel := [2]int{int(p.X(xs[i])), int(p.Y(ys[i]))}
+			if seen[pixel] {
+				continue
+			}
+			seen[pixel] = true
+		}
+		tx = append(tx, xs[i])
+		ty = append(ty, ys[i])
+	}
+	return
+}
+
+// niceTicks returns about n round values spanning [lo, hi].
+func niceTicks(lo float64, hi float64, n int) []float64 {
+	span := hi - lo
+	if span <= 0 || math.IsNaN(span) || math.IsInf(span, 0) {
+		return []float64{lo}
+	}
+	step := math.Pow(10, math.Floor(math.Log10(span/float64(n))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if span/(step*m) <= float64(n) {
+			step *= m
+			break
+		}
+	}
+	ticks := []float64{}
+	for t := math.Ceil(lo/step) * step; t <= hi+step*1e-9; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+func formatTick(v float64) string {
+	if math.Abs(v) < 1e-12 {
+		return "0"
+	}
+	if math.Abs(v) >= 1e6 {
+		return fmt.Sprintf("%.3g", v)
+	}
+	return fmt.Sprintf("%g", math.Round(v*1e6)/1e6)
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestThin(t *testing.T) {
+	p := newPlot("", 100, 100, 0, 1, 0, 1)
+	// every point falls within the same pixel
+	xs := []float64{0.5, 0.5001, 0.5002, math.NaN(), 0.5003}
+	ys := []float64{0.5, 0.5001, 0.5002, 0.5, math.NaN()}
+	tx, ty := p.thin(xs, ys, func(i int) bool { return false })
+	if len(tx) != 1 || tx[0] != 0.5 || ty[0] != 0.5 {
+		t.Errorf("thinned to %v, %v, want the first point", tx, ty)
+	}
+	// points picked by keep are kept whatever pixel they fall in
+	tx, _ = p.thin(xs, ys, func(i int) bool { return i == 1 || i == 2 })
+	if len(tx) != 3 {
+		t.Errorf("kept %d points, want 3", len(tx))
+	}
+}
+
+// countPoints returns the number of circles of an SVG plot, and of those
+// drawn at or above the pixel row ytop.
+func countPoints(t *testing.T, svg string, ytop float64) (n int, above int) {
+	for _, c := range strings.Split(svg, "<circle ")[1:] {
+		var cx, cy float64
+		if _, err := fmt.Sscanf(c, `cx="%f" cy="%f"`, &cx, &cy); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if cy <= ytop+0.05 {
+			above++
+		}
+	}
+	return
+}
+
+func TestManhattanQQThinning(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 200000
+	chr := make([]string, n)
+	bp := make([]float64, n)
+	log10p := make([]float64, n)
+	for i := range chr {
+		chr[i] = []string{"1", "2", "chr3"}[i%3]
+		bp[i] = float64(rng.Intn(1e8))
+		log10p[i] = -math.Log10(rng.Float64())
+	}
+	// hits past the keep_log10p threshold, some at the same position
+	hits := 0
+	for i := 0; i < 50; i++ {
+		log10p[i*1000] = keep_log10p + float64(i%5)
+		bp[i*1000] = 5e7
+		hits++
+	}
+
+	svg := manhattan(append([]string{}, chr...), bp, log10p)
+	pixels := int((plot_width - margin_left - margin_right + 1) * (plot_height - margin_top - margin_bottom + 1))
+	ymax := math.Ceil(maxFinite(log10p, gws_log10p) + 0.5)
+	total, above := countPoints(t, svg, newPlot("", plot_width, plot_height, 0, 1, 0, ymax).Y(keep_log10p))
+	if total >= n/4 || total > pixels {
+		t.Errorf("Manhattan plot of %d points draws %d", n, total)
+	}
+	if above < hits {
+		t.Errorf("Manhattan plot draws %d points at -log10(P) >= %g, want all %d", above, keep_log10p, hits)
+	}
+
+	svg = qq(log10p)
+	total, _ = countPoints(t, svg, 0)
+	if total >= n/10 {
+		t.Errorf("QQ plot of %d points draws %d", n, total)
+	}
+	// the top of the QQ plot is never thinned
+	top := 0
+	for _, v := range log10p {
+		if v >= keep_log10p {
+			top++
+		}
+	}
+	if total < top {
+		t.Errorf("QQ plot draws %d points, fewer than the %d with -log10(P) >= %g", total, top, keep_log10p)
+	}
+}
@@ -0,0 +1,452 @@
+package snpmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/parse"
+)
+
+// The index is the SNP map re-encoded as binary records in blocks of at most
+// blockSize records of one chromosome, followed by a table of the blocks
+// and a trailer holding the offset of that table.
+const (
+	blockSize = 1024
+	magic     = "GLSNPMAP1"
+)
+
+var map_cnames = map[string]string{
+	"CHR":    "CHR",
+	"CHROM":  "CHR",
+	"#CHROM": "CHR",
+	"POS":    "POS",
+	"BP":     "POS",
+	"RSID":   "RSID",
+	"RS":     "RSID",
+	"ID":     "RSID",
+	"SNP":    "RSID",
+	"REF":    "REF",
+	"ALT":    "ALT",
+}
+
+// Index is an on-disk SNP map sorted by chromosome and position. Only the
+// block table is held in memory.
+type Index struct {
+	file   *os.File
+	blocks map[string][]block
+}
+
+type block struct {
+	pos   uint32
+	start int64
+	end   int64
+}
+
+type record struct {
+	pos  uint32
+	rsid string
+	ref  string
+	alt  string
+}
+
+// Query is a variant to look up. A1 and A2 may be empty to match on position
+// alone.
+type Query struct {
+	Chr string
+	Pos int
+	A1  string
+	A2  string
+}
+
+// IndexPaths returns where the index of the SNP map in file may be kept:
+// next to the map, or in the user cache directory when the map is in a
+// directory that cannot be written, such as a shared reference directory.
+func IndexPaths(file string) []string {
+	paths := []string{file + ".idx"}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return paths
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return paths
+	}
+	h := fnv.New64a()
+	h.Write([]byte(abs))
+	name := fmt.Sprintf("%s-%016x.idx", filepath.Base(file), h.Sum64())
+	return append(paths, filepath.Join(cache, "golink", "snpmap", name))
+}
+
+// Open opens the index of the SNP map in file, building it first if it is
+// missing or older than the map.
+func Open(file string) (*Index, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	paths := IndexPaths(file)
+	for _, path := range paths {
+		if idx, err := os.Stat(path); err == nil && !idx.ModTime().Before(info.ModTime()) {
+			return OpenIndex(path)
+		}
+	}
+	for _, path := range paths {
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			continue
+		}
+		if err = Build(file, path); err == nil {
+			return OpenIndex(path)
+		}
+		// only a failure to create the index moves on to the next path
+		var perr *fs.PathError
+		if !errors.As(err, &perr) || perr.Path != path+".tmp" {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// OpenIndex opens an index written by Build.
+func OpenIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{file: f, blocks: map[string][]block{}}
+	if err := idx.readTable(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return idx, nil
+}
+
+func (idx *Index) Close() error {
+	return idx.file.Close()
+}
+
+// Build writes the index of the whitespace delimited SNP map in file to out.
+// The map has chromosome, position, rsID, reference and alternate allele
+// columns, named in a header or in that order, in any order of rows. Maps
+// too large to sort in memory are sorted in runs of runSize records, kept
+// in temporary files next to out.
+func Build(file string, out string) error {
+	in, err := parse.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	s := &sorter{dir: filepath.Dir(out)}
+	defer s.close()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	cols := map[string]int{"CHR": 0, "POS": 1, "RSID": 2, "REF": 3, "ALT": 4}
+	maxcol := 4
+	line := 0
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		line++
+		if len(fields) == 0 {
+			continue
+		}
+		if line == 1 && len(fields) > 1 {
+			if _, err := strconv.Atoi(fields[1]); err != nil {
+				cols = map[string]int{}
+				for i, h := range fields {
+					if name, ok := map_cnames[parse.CleanName(h)]; ok {
+						cols[name] = i
+					}
+				}
+				maxcol = 0
+				for _, name := range []string{"CHR", "POS", "RSID", "REF", "ALT"} {
+					i, ok := cols[name]
+					if !ok {
+						f.Close()
+						return fmt.Errorf("%s: missing %s column", file, name)
+					}
+					if i > maxcol {
+						maxcol = i
+					}
+				}
+				continue
+			}
+		}
+		if len(fields) <= maxcol {
+			f.Close()
+			return fmt.Errorf("%s line %d: expected at least %d columns", file, line, maxcol+1)
+		}
+		pos, err := strconv.ParseUint(fields[cols["POS"]], 10, 32)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%s line %d: %v", file, line, err)
+		}
+		err = s.add(entry{
+			chr: parse.NormalizeCHR(fields[cols["CHR"]]),
+			record: record{
+				pos:  uint32(pos),
+				rsid: fields[cols["RSID"]],
+				ref:  strings.ToUpper(fields[cols["REF"]]),
+				alt:  strings.ToUpper(fields[cols["ALT"]]),
+			},
+		})
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return err
+	}
+
+	w := &indexWriter{w: bufio.NewWriter(f)}
+	if err := s.each(w.add); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.finish(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, out)
+}
+
+// indexWriter writes sorted records as the blocks of an index, then the
+// block table and trailer.
+type indexWriter struct {
+	w      *bufio.Writer
+	blocks []block
+	chrs   []string
+	chr    string
+	n      int
+	offset int64
+}
+
+func (iw *indexWriter) add(e entry) error {
+	if e.chr != iw.chr || len(iw.blocks) == 0 {
+		iw.chr = e.chr
+		iw.n = 0
+	}
+	if iw.n%blockSize == 0 {
+		if len(iw.blocks) > 0 {
+			iw.blocks[len(iw.blocks)-1].end = iw.offset
+		}
+		iw.blocks = append(iw.blocks, block{pos: e.pos, start: iw.offset})
+		iw.chrs = append(iw.chrs, e.chr)
+	}
+	iw.n++
+	iw.offset += writeRecord(iw.w, e.record)
+	return nil
+}
+
+func (iw *indexWriter) finish() error {
+	if len(iw.blocks) > 0 {
+		iw.blocks[len(iw.blocks)-1].end = iw.offset
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		iw.w.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+	putUvarint(uint64(len(iw.blocks)))
+	for i, b := range iw.blocks {
+		putUvarint(uint64(len(iw.chrs[i])))
+		iw.w.WriteString(iw.chrs[i])
+		putUvarint(uint64(b.pos))
+		putUvarint(uint64(b.start))
+		putUvarint(uint64(b.end))
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(iw.offset))
+	iw.w.Write(buf[:8])
+	iw.w.WriteString(magic)
+	return iw.w.Flush()
+}
+
+func writeRecord(w *bufio.Writer, r record) int64 {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(r.pos))
+	w.Write(buf[:n])
+	size := n
+	for _, s := range []string{r.rsid, r.ref, r.alt} {
+		n = binary.PutUvarint(buf, uint64(len(s)))
+		w.Write(buf[:n])
+		w.WriteString(s)
+		size += n + len(s)
+	}
+	return int64(size)
+}
+
+// readRecord reads a record written by writeRecord, returning io.EOF at the
+// end of r.
+func readRecord(r *bufio.Reader) (rec record, err error) {
+	pos, err := binary.ReadUvarint(r)
+	if err != nil {
+		return
+	}
+	rec.pos = uint32(pos)
+	for _, s := range []*string{&rec.rsid, &rec.ref, &rec.alt} {
+		if *s, err = readString(r); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+	}
+	return
+}
+
+func (idx *Index) readTable() error {
+	info, err := idx.file.Stat()
+	if err != nil {
+		return err
+	}
+	trailer := int64(8 + len(magic))
+	if info.Size() < trailer {
+		return errors.New("not a SNP map index")
+	}
+	buf := make([]byte, trailer)
+	if _, err := idx.file.ReadAt(buf, info.Size()-trailer); err != nil {
+		return err
+	}
+	if string(buf[8:]) != magic {
+		return errors.New("not a SNP map index")
+	}
+	start := int64(binary.LittleEndian.Uint64(buf[:8]))
+	r := bufio.NewReader(io.NewSectionReader(idx.file, start, info.Size()-trailer-start))
+	nblocks, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < nblocks; i++ {
+		chr, err := readString(r)
+		if err != nil {
+			return err
+		}
+		var v [3]uint64
+		for j := range v {
+			if v[j], err = binary.ReadUvarint(r); err != nil {
+				return err
+			}
+		}
+		idx.blocks[chr] = append(idx.blocks[chr], block{pos: uint32(v[0]), start: int64(v[1]), end: int64(v[2])})
+	}
+	return nil
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (idx *Index) readBlock(b block) ([]record, error) {
+	r := bufio.NewReader(io.NewSectionReader(idx.file, b.start, b.end-b.start))
+	records := make([]record, 0, blockSize)
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// Lookup returns the rsID of each query, or "" if the map has no variant at
+// its position with matching alleles. Without alleles a query matches only
+// if one rsID is mapped to the position. Each block of the index is read at
+// most once.
+func (idx *Index) Lookup(queries []Query) ([]string, error) {
+	rsids := make([]string, len(queries))
+	by_chr := map[string][]int{}
+	for i, q := range queries {
+		c := parse.NormalizeCHR(q.Chr)
+		by_chr[c] = append(by_chr[c], i)
+	}
+	for chr, qs := range by_chr {
+		blocks := idx.blocks[chr]
+		if len(blocks) == 0 {
+			continue
+		}
+		sort.Slice(qs, func(a, b int) bool { return queries[qs[a]].Pos < queries[qs[b]].Pos })
+		j := 0
+		for i, b := range blocks {
+			// a position can run over the end of a block, so queries at the
+			// first position of the next block are tried against both
+			for j < len(qs) && queries[qs[j]].Pos < int(b.pos) {
+				j++
+			}
+			k := j
+			for k < len(qs) && (i == len(blocks)-1 || queries[qs[k]].Pos <= int(blocks[i+1].pos)) {
+				k++
+			}
+			if k == j {
+				continue
+			}
+			records, err := idx.readBlock(b)
+			if err != nil {
+				return nil, err
+			}
+			at := map[uint32][]record{}
+			for _, r := range records {
+				at[r.pos] = append(at[r.pos], r)
+			}
+			for _, qi := range qs[j:k] {
+				if rsids[qi] == "" {
+					rsids[qi] = match(queries[qi], at[uint32(queries[qi].Pos)])
+				}
+			}
+		}
+	}
+	return rsids, nil
+}
+
+func match(q Query, records []record) string {
+	a1 := strings.ToUpper(q.A1)
+	a2 := strings.ToUpper(q.A2)
+	if a1 == "" && a2 == "" {
+		rsid := ""
+		for _, r := range records {
+			if rsid != "" && r.rsid != rsid {
+				return ""
+			}
+			rsid = r.rsid
+		}
+		return rsid
+	}
+	for _, r := range records {
+		for _, alt := range strings.Split(r.alt, ",") {
+			if (a1 == r.ref && a2 == alt) || (a1 == alt && a2 == r.ref) {
+				return r.rsid
+			}
+		}
+	}
+	return ""
+}
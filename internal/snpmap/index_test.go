@@ -0,0 +1,86 @@
+package snpmap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An unsorted map, with chromosome 1 split around chromosome 2 and
+// positions out of order.
+const unsorted_map = `#CHROM	POS	ID	REF	ALT
+1	300	rs3	A	G
+2	100	rs20	C	T
+1	100	rs1	G	A
+1	200	rs2	T	C,G
+2	50	rs19	A	C
+1	200	rs2b	T	A
+`
+
+func TestBuildUnsorted(t *testing.T) {
+	defer func(n int) { runSize = n }(runSize)
+	for _, n := range []int{1 << 20, 2} {
+		runSize = n
+		dir := t.TempDir()
+		file := filepath.Join(dir, "map.tsv")
+		if err := os.WriteFile(file, []byte(unsorted_map), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		idx, err := Open(file)
+		if err != nil {
+			t.Fatalf("runSize %d: %v", n, err)
+		}
+		rsids, err := idx.Lookup([]Query{
+			{Chr: "1", Pos: 100, A1: "A", A2: "G"},
+			{Chr: "1", Pos: 200, A1: "g", A2: "t"},
+			{Chr: "1", Pos: 200, A1: "A", A2: "T"},
+			{Chr: "chr2", Pos: 50},
+			{Chr: "2", Pos: 100, A1: "A", A2: "G"},
+			{Chr: "1", Pos: 300},
+			{Chr: "1", Pos: 200},
+			{Chr: "3", Pos: 100},
+		})
+		idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(rsids, ","), "rs1,rs2,rs2b,rs19,,rs3,,"; got != want {
+			t.Errorf("runSize %d: rsIDs %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestBuildShortLine(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "map.tsv")
+	data := "CHR\tPOS\tREF\tALT\tQUAL\tRSID\n1\t100\tA\tG\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Build(file, filepath.Join(dir, "map.idx"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Build of a short line: got error %v", err)
+	}
+}
+
+func TestOpenCacheFallback(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	file := filepath.Join(dir, "map.tsv")
+	if err := os.WriteFile(file, []byte(unsorted_map), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// stands in for a directory the index cannot be written to
+	if err := os.Mkdir(file+".idx.tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	if _, err := os.Stat(IndexPaths(file)[1]); err != nil {
+		t.Errorf("index not built in the cache directory: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package snpmap
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+)
+
+// runSize is the number of records Build sorts in memory at a time.
+var runSize = 1 << 20
+
+type entry struct {
+	chr string
+	record
+}
+
+func less(a entry, b entry) bool {
+	if a.chr != b.chr {
+		return a.chr < b.chr
+	}
+	return a.pos < b.pos
+}
+
+// sorter sorts entries by chromosome and position, keeping the input order
+// of entries at the same position. Once more than runSize entries are added
+// they are written out in sorted runs, which each merges.
+type sorter struct {
+	dir  string
+	buf  []entry
+	runs []*os.File
+}
+
+func (s *sorter) add(e entry) error {
+	s.buf = append(s.buf, e)
+	if len(s.buf) >= runSize {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *sorter) sort() {
+	sort.SliceStable(s.buf, func(i, j int) bool { return less(s.buf[i], s.buf[j]) })
+}
+
+func (s *sorter) spill() error {
+	s.sort()
+	f, err := os.CreateTemp(s.dir, ".snpmap-run-*")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, e := range s.buf {
+		w.Write(buf[:binary.PutUvarint(buf, uint64(len(e.chr)))])
+		w.WriteString(e.chr)
+		writeRecord(w, e.record)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// each calls fn with every entry in sorted order.
+func (s *sorter) each(fn func(e entry) error) error {
+	if len(s.runs) == 0 {
+		s.sort()
+		for _, e := range s.buf {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(s.buf) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	h := &runHeap{}
+	for i, f := range s.runs {
+		r := bufio.NewReader(f)
+		e, err := readEntry(r)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.heads = append(h.heads, head{entry: e, run: i, r: r})
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		top := &h.heads[0]
+		if err := fn(top.entry); err != nil {
+			return err
+		}
+		e, err := readEntry(top.r)
+		if err == io.EOF {
+			heap.Pop(h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		top.entry = e
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+func (s *sorter) close() {
+	for _, f := range s.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func readEntry(r *bufio.Reader) (e entry, err error) {
+	if e.chr, err = readString(r); err != nil {
+		return
+	}
+	e.record, err = readRecord(r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// runHeap holds the next entry of each run. Ties go to the earlier run,
+// which holds the earlier input lines.
+type head struct {
+	entry
+	run int
+	r   *bufio.Reader
+}
+
+type runHeap struct {
+	heads []head
+}
+
+func (h *runHeap) Len() int { return len(h.heads) }
+func (h *runHeap) Less(i, j int) bool {
+	a, b := h.heads[i], h.heads[j]
+	if less(a.entry, b.entry) || less(b.entry, a.entry) {
+		return less(a.entry, b.entry)
+	}
+	return a.run < b.run
+}
+func (h *runHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *runHeap) Push(x interface{}) { h.heads = append(h.heads, x.(head)) }
+func (h *runHeap) Pop() interface{} {
+	x := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return x
+}
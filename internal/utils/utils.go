@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"io"
 	"log"
+	"os"
 	"reflect"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -56,9 +59,31 @@ func Filter[v comparable](arr []v, cond func(v) bool) []v {
 	return res
 }
 
+// Timing is the time a step tracked with TimeTrack took.
+type Timing struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+var (
+	timings   []Timing
+	timings_m sync.Mutex
+)
+
 func TimeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
 	log.Printf("%s took %s", name, elapsed)
+	timings_m.Lock()
+	timings = append(timings, Timing{Name: name, Seconds: elapsed.Seconds()})
+	timings_m.Unlock()
+}
+
+// Timings returns the steps tracked with TimeTrack so far, in the order they
+// finished.
+func Timings() []Timing {
+	timings_m.Lock()
+	defer timings_m.Unlock()
+	return append([]Timing{}, timings...)
 }
 
 func RemoveDuplicates[v comparable](list []v) (res []v) {
@@ -96,3 +121,13 @@ func Slices(l int, remove []int) (res [][]int) {
 	}
 	return
 }
+
+// SetupLog sends the standard logger to both stdout and out.log.
+func SetupLog(out string) (*os.File, error) {
+	logFile, err := os.OpenFile(out+".log", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(io.MultiWriter(os.Stdout, logFile))
+	return logFile, nil
+}
@@ -0,0 +1,434 @@
+package scripts
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/ldsc"
+	"github.com/awilliamson10/golink/internal/ops"
+	parse "github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func Estimate_h2(args map[string]string) {
+	out := args["out"]
+	logFile, err := utils.SetupLog(out)
+	if err != nil {
+		panic(err)
+	}
+	defer logFile.Close()
+
+	log.Printf("Estimating heritability of %s\n", args["sumstats"])
+	if args["ref-ld-chr"] == "" || args["w-ld-chr"] == "" {
+		log.Fatal("Error: --ref-ld-chr and --w-ld-chr are required.")
+	}
+	if args["overlap-annot"] != "false" && args["frqfile-chr"] == "" {
+		log.Fatal("Error: --overlap-annot requires --frqfile-chr to match the M_5_50 SNP counts.")
+	}
+	samp_prev, pop_prev, err := parsePrevalence(args["samp-prev"], args["pop-prev"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	intercept, err := parseIntercept(args["intercept-h2"], args["no-intercept"] != "false", 1)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	n_blocks, err := strconv.Atoi(args["n-blocks"])
+	if err != nil {
+		log.Fatal("Error: --n-blocks must be an integer.")
+	}
+
+	ref, err := readRefLD(args["ref-ld-chr"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	w_ld, err := readWLD(args["w-ld-chr"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	sumstats, err := readSumstats(args["sumstats"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+
+	merged := mergeLD(ref, w_ld, sumstats)
+	log.Println("After merging with reference panel and regression SNP LD,", len(merged.snps), "SNPs remain.")
+	if len(merged.snps) == 0 {
+		log.Fatal("Error: no SNPs remain after merging.")
+	}
+	n_annot := len(ref.names)
+	if args["two-step"] != "" && (n_annot > 1 || args["h2-cts"] != "") {
+		log.Fatal("Error: --two-step is not compatible with partitioned LD scores.")
+	}
+
+	chisq_max := math.Inf(1)
+	if args["chisq-max"] != "" {
+		chisq_max, err = strconv.ParseFloat(args["chisq-max"], 64)
+		if err != nil {
+			log.Fatal("Error: --chisq-max must be a number.")
+		}
+	} else if n_annot > 1 || args["h2-cts"] != "" {
+		chisq_max = math.Max(0.001*floats.Max(merged.n), 80)
+	}
+	if !math.IsInf(chisq_max, 1) {
+		before := len(merged.snps)
+		merged = merged.filter(func(i int) bool { return merged.chisq[i] < chisq_max })
+		log.Printf("Removed %d SNPs with chi^2 > %g (%d SNPs remain)", before-len(merged.snps), chisq_max, len(merged.snps))
+	}
+
+	opts := ldsc.HsqOptions{NBlocks: n_blocks, OldWeights: n_annot > 1, Intercept: intercept}
+	if len(merged.snps) < n_blocks {
+		opts.NBlocks = len(merged.snps)
+	}
+
+	if args["h2-cts"] != "" {
+		threads, err := strconv.Atoi(args["threads"])
+		if err != nil {
+			log.Fatal("Error: --threads must be an integer.")
+		}
+		opts.OldWeights = true
+		results, err := cellTypeSpecific(args["h2-cts"], merged, ref, opts, threads)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if err := writeCellTypeResults(out+".cell_type_results.txt", results); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("Results printed to", out+".cell_type_results.txt")
+		return
+	}
+	if n_annot == 1 && (intercept == nil || args["two-step"] != "") {
+		opts.TwoStep = 30
+		if args["two-step"] != "" {
+			opts.TwoStep, err = strconv.ParseFloat(args["two-step"], 64)
+			if err != nil {
+				log.Fatal("Error: --two-step must be a number.")
+			}
+		}
+		log.Printf("Using two-step estimator with cutoff at %g.", opts.TwoStep)
+	}
+
+	x := mat.NewDense(len(merged.snps), n_annot, merged.ld)
+	hsq, err := ldsc.NewHsq(merged.chisq, x, merged.w, merged.n, ref.M, opts)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	summary, err := hsq.Summary(ref.names, samp_prev, pop_prev)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	log.Println("\n" + summary)
+
+	if args["overlap-annot"] != "false" {
+		overlap, m_tot, err := ops.ReadAnnotOverlapChr(strings.Split(args["ref-ld-chr"], ","), args["frqfile-chr"], ref.chrs)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		overlap = keepAnnot(overlap, ref.keep)
+		results := hsq.OverlapResults(ref.names, overlap, ref.M, m_tot)
+		if err := writeResults(out+".results", results); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("Results printed to", out+".results")
+	}
+}
+
+type refLD struct {
+	snps  []string
+	names []string
+	// ld holds one row per SNP, one column per annotation
+	ld   [][]float64
+	M    []float64
+	chrs []int
+	// keep marks the LD score columns retained after dropping those with
+	// zero variance
+	keep []bool
+}
+
+func readRefLD(prefix string) (ref refLD, err error) {
+	snps, names, cols, M, chrs, err := readLDScores(strings.Split(prefix, ","))
+	if err != nil {
+		return
+	}
+	ref.snps = snps
+	ref.chrs = chrs
+
+	ref.keep = make([]bool, len(cols))
+	kept := [][]float64{}
+	for j, c := range cols {
+		if variance(c) == 0 {
+			log.Println("Removing LD score column", names[j], "with zero variance.")
+			continue
+		}
+		ref.keep[j] = true
+		ref.names = append(ref.names, names[j])
+		ref.M = append(ref.M, M[j])
+		kept = append(kept, c)
+	}
+	if len(kept) == 0 {
+		err = fmt.Errorf("all LD scores in %s have zero variance", prefix)
+		return
+	}
+	ref.ld = make([][]float64, len(ref.snps))
+	for i := range ref.snps {
+		ref.ld[i] = make([]float64, len(kept))
+		for j := range kept {
+			ref.ld[i][j] = kept[j][i]
+		}
+	}
+	return
+}
+
+// readLDScores reads the LD score columns and M_5_50 counts of one or more
+// chromosome split prefixes.
+func readLDScores(prefixes []string) (snps []string, names []string, cols [][]float64, M []float64, chrs []int, err error) {
+	table, M, err := ops.ReadLDScoreChrList(prefixes)
+	if err != nil {
+		return
+	}
+	chrs = ops.PresentChrs(prefixes[0], ".l2.ldscore")
+	for i, f := range table.Schema().Fields() {
+		if f.Name == "SNP" {
+			snps = ops.StringValues(table.Column(i))
+			continue
+		}
+		cols = append(cols, ops.Float64Values(table.Column(i)))
+		names = append(names, f.Name)
+	}
+	return
+}
+
+// readWLD reads the regression weights of a single chromosome split prefix.
+// Weight sets such as weights_hm3_no_hla have no M files, so none are read.
+func readWLD(prefix string) (w map[string]float64, err error) {
+	if strings.Contains(prefix, ",") {
+		err = fmt.Errorf("--w-ld-chr must be a single prefix, not a comma separated list")
+		return
+	}
+	table, schema, err := ops.ReadLDScoreChr(prefix)
+	if err != nil {
+		return
+	}
+	if len(schema.Fields()) != 2 {
+		err = fmt.Errorf("%s may only have one LD score column", prefix)
+		return
+	}
+	snp := schema.FieldIndices("SNP")[0]
+	snps := ops.StringValues(table.Column(snp))
+	ld := ops.Float64Values(table.Column(1 - snp))
+	w = make(map[string]float64, len(snps))
+	for i, s := range snps {
+		if _, ok := w[s]; !ok {
+			w[s] = ld[i]
+		}
+	}
+	return
+}
+
+type sumstatsRow struct {
+	z  float64
+	n  float64
+	a1 string
+	a2 string
+}
+
+func readSumstats(file string) (rows map[string]sumstatsRow, err error) {
+	header, err := parse.ReadHeader(file, "\t")
+	if err != nil {
+		return
+	}
+	ctypes := map[string]arrow.DataType{}
+	for _, value := range header {
+		if utils.InList(value, constants.Numeric_cols) {
+			ctypes[value] = arrow.PrimitiveTypes.Float64
+		} else {
+			ctypes[value] = arrow.BinaryTypes.String
+		}
+	}
+	for _, col := range []string{"SNP", "Z", "N"} {
+		if !utils.InList(col, header) {
+			err = fmt.Errorf("%s is missing required column %s", file, col)
+			return
+		}
+	}
+	table, _ := ops.ArrowCSV(file, header, '\t', ctypes)
+	if table == nil {
+		err = fmt.Errorf("could not read %s", file)
+		return
+	}
+	snps := ops.StringValues(table.Column(ops.ColumnIndex(table, "SNP")))
+	z := ops.Float64Values(table.Column(ops.ColumnIndex(table, "Z")))
+	n := ops.Float64Values(table.Column(ops.ColumnIndex(table, "N")))
+	var a1, a2 []string
+	if utils.InList("A1", header) && utils.InList("A2", header) {
+		a1 = ops.StringValues(table.Column(ops.ColumnIndex(table, "A1")))
+		a2 = ops.StringValues(table.Column(ops.ColumnIndex(table, "A2")))
+	}
+	rows = make(map[string]sumstatsRow, len(snps))
+	for i, snp := range snps {
+		if math.IsNaN(z[i]) || math.IsNaN(n[i]) {
+			continue
+		}
+		if _, ok := rows[snp]; !ok {
+			row := sumstatsRow{z: z[i], n: n[i]}
+			if a1 != nil {
+				row.a1, row.a2 = strings.ToUpper(a1[i]), strings.ToUpper(a2[i])
+			}
+			rows[snp] = row
+		}
+	}
+	log.Println("Read summary statistics for", len(rows), "SNPs.")
+	return
+}
+
+type regressionData struct {
+	snps  []string
+	z     []float64
+	chisq []float64
+	n     []float64
+	w     []float64
+	// ld is the row-major regression LD score matrix
+	ld []float64
+}
+
+// mergeLD joins the sumstats and regression weights onto the reference LD
+// scores, keeping the reference panel order so jackknife blocks are
+// contiguous in the genome.
+func mergeLD(ref refLD, w map[string]float64, sumstats map[string]sumstatsRow) (merged regressionData) {
+	seen := map[string]bool{}
+	for i, snp := range ref.snps {
+		s, ok := sumstats[snp]
+		if !ok || seen[snp] {
+			continue
+		}
+		wv, ok := w[snp]
+		if !ok {
+			continue
+		}
+		seen[snp] = true
+		merged.snps = append(merged.snps, snp)
+		merged.z = append(merged.z, s.z)
+		merged.chisq = append(merged.chisq, s.z*s.z)
+		merged.n = append(merged.n, s.n)
+		merged.w = append(merged.w, wv)
+		merged.ld = append(merged.ld, ref.ld[i]...)
+	}
+	return
+}
+
+func (d regressionData) filter(keep func(i int) bool) (filtered regressionData) {
+	k := len(d.ld) / len(d.snps)
+	for i := range d.snps {
+		if !keep(i) {
+			continue
+		}
+		filtered.snps = append(filtered.snps, d.snps[i])
+		filtered.z = append(filtered.z, d.z[i])
+		filtered.chisq = append(filtered.chisq, d.chisq[i])
+		filtered.n = append(filtered.n, d.n[i])
+		filtered.w = append(filtered.w, d.w[i])
+		filtered.ld = append(filtered.ld, d.ld[i*k:(i+1)*k]...)
+	}
+	return
+}
+
+func keepAnnot(overlap *mat.Dense, keep []bool) *mat.Dense {
+	idxs := []int{}
+	for i, k := range keep {
+		if k {
+			idxs = append(idxs, i)
+		}
+	}
+	kept := mat.NewDense(len(idxs), len(idxs), nil)
+	for a, i := range idxs {
+		for b, j := range idxs {
+			kept.Set(a, b, overlap.At(i, j))
+		}
+	}
+	return kept
+}
+
+func writeResults(file string, results []ldsc.OverlapResult) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := []string{"Category", "Prop._SNPs", "Prop._h2", "Prop._h2_std_error", "Enrichment", "Enrichment_std_error", "Enrichment_p", "Coefficient", "Coefficient_std_error", "Coefficient_z-score"}
+	fmt.Fprintln(f, strings.Join(header, "\t"))
+	for _, r := range results {
+		row := []string{r.Category}
+		for _, v := range []float64{r.PropSNPs, r.PropH2, r.PropH2SE, r.Enrichment, r.EnrichmentSE, r.EnrichmentP, r.Coefficient, r.CoefficientSE, r.CoefficientZ} {
+			row = append(row, formatFloat(v))
+		}
+		fmt.Fprintln(f, strings.Join(row, "\t"))
+	}
+	return nil
+}
+
+// parseIntercept reads a fixed regression intercept. noIntercept fixes it to
+// null, the value expected without confounding or sample overlap. A nil
+// result means the intercept is estimated.
+func parseIntercept(value string, noIntercept bool, null float64) (*float64, error) {
+	if noIntercept {
+		if value != "" {
+			return nil, fmt.Errorf("--no-intercept cannot be combined with a fixed intercept")
+		}
+		return &null, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("intercept must be a number, got %q", value)
+	}
+	return &v, nil
+}
+
+// parsePrevalence reads --samp-prev and --pop-prev, which must be given
+// together. Unset prevalences are returned as NaN.
+func parsePrevalence(samp string, pop string) (P float64, K float64, err error) {
+	P, K = math.NaN(), math.NaN()
+	if (samp == "") != (pop == "") {
+		err = fmt.Errorf("--samp-prev and --pop-prev must be set together")
+		return
+	}
+	if samp == "" {
+		return
+	}
+	if P, err = strconv.ParseFloat(samp, 64); err != nil {
+		err = fmt.Errorf("--samp-prev must be a number")
+		return
+	}
+	if K, err = strconv.ParseFloat(pop, 64); err != nil {
+		err = fmt.Errorf("--pop-prev must be a number")
+		return
+	}
+	_, err = ldsc.H2ObsToLiab(1, P, K)
+	return
+}
+
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return "NA"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func variance(x []float64) float64 {
+	mean := floats.Sum(x) / float64(len(x))
+	s := 0.0
+	for _, v := range x {
+		s += (v - mean) * (v - mean)
+	}
+	return s / float64(len(x))
+}
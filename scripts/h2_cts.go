@@ -0,0 +1,120 @@
+package scripts
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/ldsc"
+	"github.com/awilliamson10/golink/internal/ops"
+	parse "github.com/awilliamson10/golink/internal/parse"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+type cellTypeResult struct {
+	name   string
+	coef   float64
+	coefSE float64
+	p      float64
+}
+
+// cellTypeSpecific fits one partitioned regression per line of the .ldcts
+// file, each with the cell type LD scores added to the baseline model, and
+// reports the one-sided p-value of the first cell type coefficient.
+func cellTypeSpecific(ldcts string, merged regressionData, ref refLD, opts ldsc.HsqOptions, threads int) ([]cellTypeResult, error) {
+	cts, err := readLDCTS(ldcts)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Running regressions for", len(cts), "cell types.")
+
+	n_base := len(ref.names)
+	results := make([]cellTypeResult, len(cts))
+	err = ops.WorkerPool(len(cts), threads, func(i int) error {
+		name, prefixes := cts[i][0], strings.Split(cts[i][1], ",")
+		snps, _, cols, M_cts, _, err := readLDScores(prefixes)
+		if err != nil {
+			return err
+		}
+		idx := make(map[string]int, len(snps))
+		for r, snp := range snps {
+			if _, ok := idx[snp]; !ok {
+				idx[snp] = r
+			}
+		}
+		n_cts := len(cols)
+		k := n_cts + n_base
+		x := mat.NewDense(len(merged.snps), k, nil)
+		for r, snp := range merged.snps {
+			j, ok := idx[snp]
+			if !ok {
+				return fmt.Errorf("missing LD scores for %s in %s; all SNPs in --ref-ld-chr must be in the cell type files", snp, cts[i][1])
+			}
+			for c := 0; c < n_cts; c++ {
+				x.Set(r, c, cols[c][j])
+			}
+			for c := 0; c < n_base; c++ {
+				x.Set(r, n_cts+c, merged.ld[r*n_base+c])
+			}
+		}
+		M := append(append([]float64(nil), M_cts...), ref.M...)
+		hsq, err := ldsc.NewHsq(merged.chisq, x, merged.w, merged.n, M, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		results[i] = cellTypeResult{
+			name:   name,
+			coef:   hsq.Coef[0],
+			coefSE: hsq.CoefSE[0],
+			p:      distuv.UnitNormal.Survival(hsq.Coef[0] / hsq.CoefSE[0]),
+		}
+		log.Printf("Finished regression for %s.", name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(a, b int) bool { return results[a].p < results[b].p })
+	return results, nil
+}
+
+// readLDCTS reads the name and comma separated LD score prefixes on each
+// line of an .ldcts file.
+func readLDCTS(file string) (cts [][2]string, err error) {
+	f, err := parse.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			err = fmt.Errorf("%s: expected a name and LD score prefixes on each line, got %q", file, scanner.Text())
+			return
+		}
+		cts = append(cts, [2]string{fields[0], fields[1]})
+	}
+	err = scanner.Err()
+	return
+}
+
+func writeCellTypeResults(file string, results []cellTypeResult) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintln(f, "Name\tCoefficient\tCoefficient_std_error\tCoefficient_P_value")
+	for _, r := range results {
+		fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", r.name, formatFloat(r.coef), formatFloat(r.coefSE), formatFloat(r.p))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package scripts
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/ops"
+	parse "github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+func Harmonise(args map[string]string) {
+	out := args["out"]
+	logFile, err := utils.SetupLog(out)
+	if err != nil {
+		panic(err)
+	}
+	defer logFile.Close()
+
+	if args["ref"] == "" {
+		log.Fatal("Error: --ref is required.")
+	}
+	log.Printf("Harmonising %s to %s\n", args["sumstats"], args["ref"])
+
+	if !utils.InList(args["out-format"], []string{"tsv", "gwas-vcf", "gwas-ssf"}) {
+		log.Fatal("Error: unknown --out-format: " + args["out-format"])
+	}
+	if args["out-format"] == "gwas-ssf" && args["genome-assembly"] == "" {
+		log.Fatal("Error: --out-format gwas-ssf needs --genome-assembly.")
+	}
+	var qc_opts ops.QCOptions
+	if qc_opts.MAFMin, err = strconv.ParseFloat(args["mafmin"], 64); err != nil {
+		log.Fatal("Error: --mafmin must be a number.")
+	}
+	if qc_opts.INFOMin, err = strconv.ParseFloat(args["info-min"], 64); err != nil {
+		log.Fatal("Error: --info-min must be a number.")
+	}
+	data := readCanonical(args["sumstats"], args["sample"], args["source"])
+	log.Println("Read", data.NumRows(), "rows.")
+	input_rows := int(data.NumRows())
+	for _, col := range []string{"A1", "A2"} {
+		if ops.ColumnIndex(data, col) < 0 {
+			log.Fatal("Error: missing required column: " + col)
+		}
+	}
+	data, dropped, err := ops.Pipeline(ops.DefaultFilters(qc_opts)).Run(data, nil)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	log.Println("Dropped:", dropped)
+	by_id := ops.ColumnIndex(data, "CHR") < 0 || ops.ColumnIndex(data, "BP") < 0
+	if by_id {
+		if ops.ColumnIndex(data, "SNP") < 0 {
+			log.Fatal("Error: need CHR and BP, or SNP, to match variants to --ref.")
+		}
+		log.Println("No CHR/BP columns; matching variants to --ref by SNP ID.")
+	}
+
+	keys := map[string]bool{}
+	for _, key := range ops.HarmoniseKeys(data, by_id) {
+		if key != "" {
+			keys[key] = true
+		}
+	}
+	ref, err := ops.ReadVCFAlleles(args["ref"], keys, by_id)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	log.Println("Found", len(ref), "of", len(keys), "variants in", args["ref"])
+
+	harmonised, counts, err := ops.Harmonise(data, ref, by_id, args["drop-unaligned"] != "false")
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	for _, action := range []string{ops.ActionAligned, ops.ActionSwapped, ops.ActionFlipped, ops.ActionFlippedSwapped,
+		ops.ActionAmbiguous, ops.ActionMismatch, ops.ActionMissing} {
+		log.Printf("%-16s %d\n", action, counts[action])
+	}
+
+	writeSumstats(harmonised, args, ops.GWASVCFMeta{
+		VariantsNotRead:    input_rows - int(harmonised.NumRows()),
+		Harmonised:         true,
+		HarmonisedVariants: counts[ops.ActionAligned] + counts[ops.ActionSwapped] + counts[ops.ActionFlipped] + counts[ops.ActionFlippedSwapped],
+		SwitchedAlleles:    counts[ops.ActionSwapped] + counts[ops.ActionFlippedSwapped],
+	})
+}
+
+// readCanonical reads a tab separated sumstats file, renaming the columns
+// munging recognises to their canonical names, with the mappings of the
+// --source preset source. P is kept as text. A GWAS-VCF is read with
+// ops.ReadGWASVCF, taking sample from a multi-sample file.
+func readCanonical(file string, sample string, source string) array.Table {
+	if parse.IsVCF(file) {
+		data, err := ops.ReadGWASVCF(file, sample, nil)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		return data
+	}
+	header, err := parse.ReadHeader(file, "\t")
+	if err != nil {
+		log.Fatal("Error reading header: ", err)
+	}
+	source, err = parse.DetectSource(source, parse.CleanNames(header))
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	if source != "" {
+		log.Println("Reading sumstats as", source, "output.")
+	}
+	default_cnames, extended_cnames := parse.SourceCnames(source, parse.CleanNames(header))
+	ctypes := map[string]arrow.DataType{}
+	for i, name := range header {
+		cname, ok := default_cnames[parse.CleanName(name)]
+		if !ok {
+			cname, ok = extended_cnames[parse.CleanName(name)]
+		}
+		if ok {
+			if utils.InList(cname, header[:i]) {
+				log.Fatal("Error: column name " + cname + " occurs more than once")
+			}
+			header[i] = cname
+		}
+		if ok && cname != "P" && utils.InList(cname, constants.Numeric_cols) {
+			ctypes[header[i]] = arrow.PrimitiveTypes.Float64
+		} else {
+			ctypes[header[i]] = arrow.BinaryTypes.String
+		}
+	}
+	data, _ := ops.ArrowCSV(file, header, '\t', ctypes)
+	if source != "" {
+		var dropped int
+		data, dropped, err = ops.ApplySourceColumns(data, nil)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if dropped > 0 {
+			log.Println("Dropped", dropped, "rows for tests other than ADD.")
+		}
+	}
+	return data
+}
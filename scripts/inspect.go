@@ -0,0 +1,86 @@
+package scripts
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/ops"
+	parse "github.com/awilliamson10/golink/internal/parse"
+)
+
+func Inspect(args map[string]string) {
+	sumstats := args["sumstats"]
+	if sumstats == "" {
+		log.Fatal("Error: --sumstats is required.")
+	}
+	header, err := parse.ReadHeader(sumstats, "\t")
+	if err != nil {
+		log.Fatal("Error reading header: ", err)
+	}
+	source, err := parse.DetectSource("auto", parse.CleanNames(header))
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	default_cnames, extended_cnames := parse.SourceCnames(source, parse.CleanNames(header))
+	if source != "" {
+		fmt.Printf("Detected %s output.\n", source)
+	}
+	fmt.Printf("%s has %d columns:\n", sumstats, len(header))
+	for _, h := range header {
+		cname, ok := default_cnames[parse.CleanName(h)]
+		if !ok {
+			cname, ok = extended_cnames[parse.CleanName(h)]
+		}
+		if ok {
+			fmt.Printf("  %-20s %-8s %s\n", h, cname, constants.Describe_cname[cname])
+		} else {
+			fmt.Printf("  %-20s %-8s %s\n", h, "-", "not recognised")
+		}
+	}
+
+	if args["infer-build"] != "false" {
+		inferBuild(sumstats, args["build-ref"], args["n-sample"])
+	}
+}
+
+func inferBuild(sumstats string, build_ref string, n_sample string) {
+	if build_ref == "" {
+		log.Fatal("Error: --infer-build needs position tables, e.g. --build-ref GRCh37=pos37.tsv.gz,GRCh38=pos38.tsv.gz")
+	}
+	n, err := strconv.Atoi(n_sample)
+	if err != nil || n < 1 {
+		log.Fatal("Error: --n-sample must be a positive integer.")
+	}
+	sample, err := ops.SampleSNPPositions(sumstats, n, 1)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	if len(sample) == 0 {
+		log.Fatal("Error: no rsIDs with positions to compare.")
+	}
+	fmt.Printf("Sampled %d rsIDs with positions.\n", len(sample))
+
+	var best ops.BuildMatch
+	for _, ref := range strings.Split(build_ref, ",") {
+		build, file, ok := strings.Cut(ref, "=")
+		if !ok {
+			log.Fatal("Error: --build-ref entries must be BUILD=FILE: " + ref)
+		}
+		m, err := ops.MatchBuild(build, file, sample)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		fmt.Printf("  %-10s %d of %d found rsIDs at the same position (%.1f%%)\n", build, m.Matched, m.Found, 100*m.Rate())
+		if m.Rate() > best.Rate() {
+			best = m
+		}
+	}
+	if best.Matched == 0 {
+		fmt.Println("Could not infer the genome build: no sampled rsIDs matched.")
+		return
+	}
+	fmt.Printf("Best matching build: %s (%.1f%% match rate)\n", best.Build, 100*best.Rate())
+}
@@ -0,0 +1,83 @@
+package scripts
+
+import (
+	"log"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/awilliamson10/golink/internal/constants"
+	"github.com/awilliamson10/golink/internal/liftover"
+	"github.com/awilliamson10/golink/internal/ops"
+	parse "github.com/awilliamson10/golink/internal/parse"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+func Liftover(args map[string]string) {
+	out := args["out"]
+	logFile, err := utils.SetupLog(out)
+	if err != nil {
+		panic(err)
+	}
+	defer logFile.Close()
+
+	if args["chain"] == "" {
+		log.Fatal("Error: --chain is required.")
+	}
+	log.Printf("Lifting over %s with %s\n", args["sumstats"], args["chain"])
+
+	header, err := parse.ReadHeader(args["sumstats"], "\t")
+	if err != nil {
+		log.Fatal("Error reading header: ", err)
+	}
+	// other columns are passed through as text under their own names
+	ctypes := map[string]arrow.DataType{}
+	for i, name := range header {
+		switch constants.Extended_cnames[parse.CleanName(name)] {
+		case "CHR":
+			header[i] = "CHR"
+		case "BP":
+			header[i] = "BP"
+			ctypes[header[i]] = arrow.PrimitiveTypes.Float64
+			continue
+		}
+		ctypes[header[i]] = arrow.BinaryTypes.String
+	}
+	for _, col := range []string{"CHR", "BP"} {
+		if utils.CountOccurrences(col, header) != 1 {
+			log.Fatal("Error: need exactly one " + col + " column.")
+		}
+	}
+
+	chain := readChain(args["chain"])
+	data, _ := ops.ArrowCSV(args["sumstats"], header, '\t', ctypes)
+	log.Println("Read", data.NumRows(), "rows.")
+
+	lifted, _ := liftoverTable(data, chain, nil)
+	log.Println("Writing", lifted.NumRows(), "rows to", out+".sumstats.gz")
+	if err := ops.WriteTSV(lifted, out+".sumstats.gz"); err != nil {
+		log.Fatal("Error: ", err)
+	}
+}
+
+func readChain(file string) *liftover.Chain {
+	chain, err := liftover.ReadChain(file)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	log.Println("Read", chain.NumBlocks(), "alignment blocks from", file)
+	return chain
+}
+
+func liftoverTable(table array.Table, chain *liftover.Chain, rejects *ops.Rejects) (array.Table, ops.LiftoverCounts) {
+	lifted, counts, err := ops.Liftover(table, chain, rejects)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	log.Println("Lifted over", lifted.NumRows(), "of", table.NumRows(), "rows.")
+	log.Println("Dropped", counts.Unmapped, "unmapped,", counts.Multiple, "multiply mapped,",
+		counts.OtherChr, "moved to another chromosome and", counts.Strand, "reverse strand positions with alleles that cannot be complemented.")
+	if counts.Flipped > 0 {
+		log.Println("Complemented the alleles of", counts.Flipped, "positions lifted onto the reverse strand.")
+	}
+	return lifted, counts
+}
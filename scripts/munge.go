@@ -1,15 +1,17 @@
 package scripts
 
 import (
-	"io"
 	"log"
-	"os"
+	"strconv"
 	"strings"
 
 	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
 	"github.com/awilliamson10/golink/internal/constants"
 	"github.com/awilliamson10/golink/internal/ops"
 	parse "github.com/awilliamson10/golink/internal/parse"
+	report_html "github.com/awilliamson10/golink/internal/report"
+	"github.com/awilliamson10/golink/internal/snpmap"
 	"github.com/awilliamson10/golink/internal/utils"
 )
 
@@ -17,24 +19,147 @@ func Munge_sumstats(args map[string]string) {
 	// setup logger to out.log
 	// open out + ".log" for writing
 	out := args["out"]
-	logFile, err := os.OpenFile(out+".log", os.O_CREATE|os.O_WRONLY, 0666)
+	logFile, err := utils.SetupLog(out)
 	if err != nil {
 		panic(err)
 	}
-	mw := io.MultiWriter(os.Stdout, logFile)
-	log := log.New(mw, "", log.LstdFlags)
+	defer logFile.Close()
 
 	log.Printf("Munging sumstats of %s\n", args["sumstats"])
+	report := ops.Report{Sumstats: args["sumstats"], Options: args}
+
+	if args["out-format"] == "gwas-ssf" && args["genome-assembly"] == "" {
+		log.Fatal("Error: --out-format gwas-ssf needs --genome-assembly.")
+	}
+	switch args["out-format"] {
+	case "tsv":
+	case "gwas-vcf", "gwas-ssf":
+		// GWAS-VCF and GWAS-SSF records are keyed by position and carry the
+		// SE, effect size and allele frequency, and GWAS-SSF has a field for
+		// INFO
+		args["keep-cols"] = strings.Trim(args["keep-cols"]+",chr,bp,se,beta,frq,info", ",")
+	default:
+		log.Fatal("Error: unknown --out-format: " + args["out-format"])
+	}
+
+	// --extract and --exclude pick the SNPs as the file is read, so that the
+	// rows left out are never held in memory, and --write-dropped follows
+	// each row through the stages by the ROW the reader numbers it with
+	sel := &ops.RowSelect{}
+	if args["write-dropped"] != "false" {
+		sel.Rejects = ops.NewRejects()
+	}
+	for _, list := range []string{"extract", "exclude"} {
+		if args[list] == "" {
+			continue
+		}
+		snps, err := ops.ReadSNPList(args[list])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Printf("Read %d SNPs to %s from %s.", len(snps), list, args[list])
+		if list == "extract" {
+			sel.Extract = snps
+		} else {
+			sel.Exclude = snps
+		}
+	}
 
 	sumstats := args["sumstats"]
-	file_cnames, err := parse.ReadHeader(sumstats, "\t")
-	if err != nil {
-		log.Printf("Error reading header: %s\n", err)
-		return
+	var vcf_data array.Table
+	var file_cnames []string
+	if parse.IsVCF(sumstats) {
+		log.Println("Reading GWAS-VCF.")
+		sel.Column = "SNP"
+		vcf_data, err = ops.ReadGWASVCF(sumstats, args["sample"], sel)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		for _, field := range vcf_data.Schema().Fields() {
+			if field.Name != "ROW" {
+				file_cnames = append(file_cnames, field.Name)
+			}
+		}
+	} else {
+		file_cnames, err = parse.ReadHeader(sumstats, "\t")
+		if err != nil {
+			log.Printf("Error reading header: %s\n", err)
+			return
+		}
+	}
+
+	derive_opts := ops.DeriveOptions{BetaSE: args["derive-beta-se"] != "false"}
+	if args["signed-sumstats"] != "" {
+		ss := strings.Split(args["signed-sumstats"], ",")
+		if len(ss) != 2 {
+			log.Fatal("Error: --signed-sumstats must be a column name and its null value, e.g. Z,0 or OR,1.")
+		}
+		if derive_opts.SignedNull, err = strconv.ParseFloat(ss[1], 64); err != nil {
+			log.Fatal("Error: the null value of --signed-sumstats must be a number.")
+		}
 	}
 
 	cleaned_cnames := parse.CleanNames(file_cnames)
 	flag_cnames := parse.ParseFlagCnames(args, cleaned_cnames)
+	if parse.IsGWASSSF(cleaned_cnames) {
+		log.Println("Detected GWAS-SSF format.")
+		// rsid is optional in GWAS-SSF, variant_id identifies the variant
+		// when it is missing
+		if !utils.InList("RSID", cleaned_cnames) && utils.InList("VARIANT_ID", cleaned_cnames) {
+			flag_cnames["VARIANT_ID"] = "SNP"
+		}
+	}
+
+	// daner files from the PGC give the case and control counts in the
+	// FRQ_A_<ncas> and FRQ_U_<ncon> column names, or per SNP in Nca and Nco
+	// with --daner-n
+	daner := args["daner"] != "false" || args["daner-n"] != "false"
+	frq_u := ""
+	if daner {
+		frq_a := ""
+		for _, c := range cleaned_cnames {
+			if strings.HasPrefix(c, "FRQ_U_") {
+				frq_u = c
+			}
+			if strings.HasPrefix(c, "FRQ_A_") {
+				frq_a = c
+			}
+		}
+		if frq_u == "" {
+			log.Fatal("Error: could not find the FRQ_U_<ncon> column of a daner file.")
+		}
+		flag_cnames[frq_u] = "FRQ"
+		if args["daner-n"] != "false" {
+			for _, c := range []string{"NCA", "NCO"} {
+				if !utils.InList(c, cleaned_cnames) {
+					log.Fatal("Error: could not find the " + c[:1] + strings.ToLower(c[1:]) + " column expected for --daner-n.")
+				}
+			}
+			flag_cnames["NCA"] = "N_CAS"
+			flag_cnames["NCO"] = "N_CON"
+		} else {
+			if frq_a == "" {
+				log.Fatal("Error: could not find the FRQ_A_<ncas> column that gives N_cas for --daner; use --daner-n with per-SNP Nca and Nco columns instead.")
+			}
+			ncas, err_a := strconv.ParseFloat(strings.TrimPrefix(frq_a, "FRQ_A_"), 64)
+			ncon, err_u := strconv.ParseFloat(strings.TrimPrefix(frq_u, "FRQ_U_"), 64)
+			if err_a != nil || err_u != nil {
+				log.Fatal("Error: could not read N_cas and N_con from " + frq_a + " and " + frq_u + ".")
+			}
+			args["ncas"], args["ncon"] = strconv.FormatFloat(ncas, 'f', -1, 64), strconv.FormatFloat(ncon, 'f', -1, 64)
+			log.Println("Inferred that N_cas =", args["ncas"], "from the FRQ_A column.")
+			log.Println("Inferred that N_con =", args["ncon"], "from the FRQ_U column.")
+		}
+	}
+
+	source, err := parse.DetectSource(args["source"], cleaned_cnames)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	if source != "" {
+		log.Println("Reading sumstats as", source, "output.")
+	}
+	source_cnames, source_extended := parse.SourceCnames(source, cleaned_cnames)
 
 	ignore_cnames := []string{}
 	if args["ignore"] != "" {
@@ -45,14 +170,76 @@ func Munge_sumstats(args map[string]string) {
 	}
 
 	mod_default_cnames := map[string]string{}
-	if args["signedsumstats"] != "" || args["a1inc"] != "false" {
-		for key, value := range constants.Default_cnames {
+	if args["signed-sumstats"] != "" || args["a1inc"] != "false" {
+		for key, value := range source_cnames {
 			if !utils.InList(value, utils.GetKeys(constants.Null_values)) {
 				mod_default_cnames[key] = value
 			}
 		}
 	} else {
-		mod_default_cnames = constants.Default_cnames
+		mod_default_cnames = source_cnames
+	}
+
+	keep_cols := []string{}
+	if args["keep-cols"] != "" {
+		for _, k := range strings.Split(args["keep-cols"], ",") {
+			cols, ok := constants.Keep_cols[strings.ToLower(strings.TrimSpace(k))]
+			if !ok {
+				log.Fatal("Error: unknown --keep-cols column: " + k)
+			}
+			keep_cols = append(keep_cols, cols...)
+		}
+		extended_cnames := map[string]string{}
+		for key, value := range mod_default_cnames {
+			extended_cnames[key] = value
+		}
+		for _, names := range []map[string]string{source_cnames, source_extended} {
+			for key, value := range names {
+				if utils.InList(value, keep_cols) {
+					extended_cnames[key] = value
+				}
+			}
+		}
+		mod_default_cnames = extended_cnames
+	}
+
+	// --liftover-chain, --snp-map and --exclude-regions work on positions,
+	// and --html-report plots them, so CHR and BP are read even if they are
+	// not kept
+	kept_cols := utils.GetValues(mod_default_cnames)
+	use_pos := args["liftover-chain"] != "" || args["snp-map"] != "" || args["exclude-regions"] != ""
+	read_pos := use_pos || args["html-report"] != "false"
+	if read_pos {
+		with_pos := map[string]string{}
+		for key, value := range mod_default_cnames {
+			with_pos[key] = value
+		}
+		for key, value := range source_extended {
+			if value == "CHR" || value == "BP" {
+				with_pos[key] = value
+			}
+		}
+		mod_default_cnames = with_pos
+	}
+
+	// Without P, LOG10P or Z, Z has to be derived from the effect size and its SE
+	has_stat := false
+	for _, value := range cleaned_cnames {
+		if utils.InList(mod_default_cnames[value], []string{"P", "LOG10P", "Z"}) || utils.InList(flag_cnames[value], []string{"P", "LOG10P", "Z"}) {
+			has_stat = true
+		}
+	}
+	if !has_stat {
+		with_se := map[string]string{}
+		for key, value := range mod_default_cnames {
+			with_se[key] = value
+		}
+		for key, value := range source_extended {
+			if value == "SE" {
+				with_se[key] = value
+			}
+		}
+		mod_default_cnames = with_se
 	}
 
 	cname_map := parse.GetCnameMap(flag_cnames, mod_default_cnames, ignore_cnames)
@@ -63,6 +250,14 @@ func Munge_sumstats(args map[string]string) {
 			cname_translation[value] = cname_map[value]
 		}
 	}
+	if daner {
+		// only the daner columns give the allele frequency and sample size
+		for key, value := range cname_translation {
+			if key != frq_u && key != "NCA" && key != "NCO" && utils.InList(value, []string{"N", "N_CAS", "N_CON", "FRQ"}) {
+				delete(cname_translation, key)
+			}
+		}
+	}
 
 	cname_description := map[string]string{}
 	for key, value := range cname_translation {
@@ -70,7 +265,7 @@ func Munge_sumstats(args map[string]string) {
 	}
 
 	//sign_cname := "SIGNED_SUMSTATS"
-	if args["signedsumstats"] != "" && args["a1inc"] != "false" {
+	if args["signed-sumstats"] != "" && args["a1inc"] != "false" {
 		sign_cnames := []string{}
 		for key := range cname_translation {
 			if utils.InList(key, utils.GetKeys(constants.Null_values)) {
@@ -86,16 +281,34 @@ func Munge_sumstats(args map[string]string) {
 	}
 
 	// Check that we have all the required columns
-	req_cols := []string{"SNP", "P"}
+	req_cols := []string{"SNP"}
+	if use_pos {
+		req_cols = append(req_cols, "CHR", "BP")
+	}
+	if args["snp-map"] != "" {
+		req_cols = []string{"CHR", "BP"}
+	}
+	if args["out-format"] == "gwas-vcf" || args["out-format"] == "gwas-ssf" {
+		req_cols = append(req_cols, "CHR", "BP", "A1", "A2")
+	}
 	if args["a1inc"] != "false" {
 		req_cols = append(req_cols, "SIGNED_SUMSTAT")
 	}
 
+	translated := utils.GetValues(cname_translation)
+	if source != "" && utils.InList("REF", translated) && utils.InList("ALT", translated) {
+		// ApplySourceColumns takes A2 from REF and ALT
+		translated = append(translated, "A2")
+	}
 	for _, col := range req_cols {
-		if !utils.InList(col, utils.GetValues(cname_translation)) {
+		if !utils.InList(col, translated) {
 			log.Fatal("Error: missing required column: " + col)
 		}
 	}
+	has_effect := utils.InList("BETA", translated) || utils.InList("LOG_ODDS", translated) || utils.InList("OR", translated)
+	if !utils.InList("P", translated) && !utils.InList("LOG10P", translated) && !utils.InList("Z", translated) && !(has_effect && utils.InList("SE", translated)) {
+		log.Fatal("Error: missing required column: need P, LOG10P, Z, or an effect size (BETA, LOG_ODDS, OR) and SE")
+	}
 
 	for key, value := range cname_translation {
 		num_occ := utils.CountOccurrences(key, cleaned_cnames)
@@ -133,49 +346,323 @@ func Munge_sumstats(args map[string]string) {
 		log.Println(key + ": " + value)
 	}
 
-	/* 	var merged_alleles df.parse
-	   	if args["merge_alleles"] != "" {
-	   		log.Println("Reading list of SNPs for allele merge from " + args["merge_alleles"])
-	   		merge_alleles := ReadCSV(args["merge_alleles"], '\t', []string{}, []string{"."}, map[string]series.Type{})
+	var merge_alleles map[string]ops.MergeAllele
+	if args["merge-alleles"] != "" {
+		log.Println("Reading list of SNPs for allele merge from " + args["merge-alleles"])
+		merge_alleles, err = ops.ReadMergeAlleles(args["merge-alleles"])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Printf("Read %d SNPs for allele merge.", len(merge_alleles))
+		if !utils.InList("A1", translated) || !utils.InList("A2", translated) {
+			log.Fatal("Error: --merge-alleles needs A1 and A2 columns.")
+		}
+	}
+	merge_opts := ops.MergeOptions{PalindromicByFrq: args["palindromic-by-frq"] != "false"}
+	if merge_opts.PalindromicByFrq {
+		merge_opts.MaxMAF, err = strconv.ParseFloat(args["palindromic-maf"], 64)
+		if err != nil {
+			log.Fatal("Error: --palindromic-maf must be a number.")
+		}
+		if !utils.InList("FRQ", utils.GetValues(cname_translation)) {
+			log.Fatal("Error: --palindromic-by-frq needs a FRQ column.")
+		}
+		if cname_translation["MAF"] == "FRQ" {
+			log.Fatal("Error: --palindromic-by-frq needs the frequency of A1, not a MAF column.")
+		}
+	}
 
-	   		for _, col := range []string{"SNP", "A1", "A2"} {
-	   			if !utils.InList(col, merge_alleles.Names()) {
-	   				log.Fatal("Error: missing required column: " + col)
-	   			}
-	   		}
-	   		nrows, _ := merge_alleles.Dims()
-	   		log.Printf("Read %d SNPs for allele merge.", nrows)
+	qc_opts := ops.QCOptions{}
+	if qc_opts.MAFMin, err = strconv.ParseFloat(args["mafmin"], 64); err != nil {
+		log.Fatal("Error: --mafmin must be a number.")
+	}
+	if qc_opts.INFOMin, err = strconv.ParseFloat(args["info-min"], 64); err != nil {
+		log.Fatal("Error: --info-min must be a number.")
+	}
+	custom_filters, err := ops.ParseFilters(args["filters"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	for _, list := range []string{"extract", "exclude"} {
+		if args[list] == "" {
+			continue
+		}
+		for key, value := range cname_translation {
+			if value == "SNP" {
+				sel.Column = key
+			}
+		}
+		if sel.Column == "" {
+			log.Fatal("Error: --" + list + " needs a SNP column.")
+		}
+	}
+	pipeline := ops.Pipeline{}
+	pipeline = append(pipeline, ops.DefaultFilters(qc_opts)...)
+	pipeline = append(pipeline, custom_filters...)
+
+	var regions *ops.Regions
+	if args["exclude-regions"] != "" {
+		// regions are matched after --liftover-chain, in the build of the output
+		build := args["genome-assembly"]
+		if build == "" {
+			build = "GRCh37"
+		}
+		regions, err = ops.ReadRegions(args["exclude-regions"], build)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Printf("Read %d regions to exclude (%s).", regions.Len(), build)
+	}
 
-	   		merge_alleles_combined := merge_alleles.Mutate(
-	   			series.New(AppendSeries(merge_alleles.Col("A1").Records(), merge_alleles.Col("A2").Records()), series.String, "MA"),
-	   		)
+	dup_opts := ops.DuplicateOptions{Policy: args["duplicates"], ByAlleles: args["duplicates-by-alleles"] != "false"}
+	if !utils.InList(dup_opts.Policy, ops.Duplicate_policies) {
+		log.Fatal("Error: --duplicates must be one of " + strings.Join(ops.Duplicate_policies, ", ") + ".")
+	}
 
-	   		merged_alleles := merge_alleles_combined.Select([]string{"SNP", "MA"})
-	   	} */
+	n_opts := ops.NOptions{KeepCases: args["out-format"] == "gwas-vcf"}
+	for _, n := range []struct {
+		flag  string
+		value *float64
+	}{{"n", &n_opts.N}, {"ncas", &n_opts.NCas}, {"ncon", &n_opts.NCon}, {"n-min", &n_opts.NMin}, {"nstudy-min", &n_opts.NStudyMin}} {
+		if *n.value, err = strconv.ParseFloat(args[n.flag], 64); err != nil {
+			log.Fatal("Error: --" + n.flag + " must be a number.")
+		}
+	}
 
 	// Read the data
 	log.Println("Reading data.")
 	ctypes := map[string]arrow.DataType{}
 	for _, value := range cleaned_cnames {
-		if utils.InList(value, constants.Numeric_cols) {
+		// P is read as text so that values below the float64 range, such as
+		// 1e-400, are not lost
+		if cname_translation[value] != "P" && utils.InList(cname_translation[value], constants.Numeric_cols) {
 			ctypes[value] = arrow.PrimitiveTypes.Float64
 		} else {
 			ctypes[value] = arrow.BinaryTypes.String
 		}
 	}
 
-	data, schema := ops.ArrowCSV(args["sumstats"], cleaned_cnames, '\t', ctypes)
-	log.Println("Read", data.NumRows(), "rows.")
+	data := vcf_data
+	if data == nil {
+		data, _ = ops.ArrowCSVSelect(args["sumstats"], cleaned_cnames, '\t', ctypes, sel)
+	}
+	log.Println("Read", sel.Rows, "rows.")
+	report.InputRows = sel.Rows
+	if selected := sel.Dropped["extract"] + sel.Dropped["exclude"]; selected > 0 {
+		log.Println("Dropped", selected, "rows left out by --extract or --exclude.")
+	}
+	report.AddDropped(sel.Dropped)
+
+	parse_cnames := cname_translation
+	rejects := sel.Rejects
+	if rejects != nil {
+		parse_cnames = map[string]string{"ROW": "ROW"}
+		for key, value := range cname_translation {
+			parse_cnames[key] = value
+		}
+	}
+	for i, value := range cleaned_cnames {
+		if cname, ok := cname_translation[value]; ok {
+			report.Columns = append(report.Columns, ops.ReportColumn{Name: file_cnames[i], Cname: cname, Description: cname_description[value]})
+		}
+	}
 
-	parsed, schema := ops.ParseDataframe(data, schema, cname_translation)
+	// the source columns are resolved before QC, so that an A2 taken from
+	// REF and ALT is checked by the allele filter
+	if source != "" {
+		var test_dropped int
+		data, test_dropped, err = ops.ApplySourceColumns(ops.RenameColumns(data, parse_cnames), rejects)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if test_dropped > 0 {
+			log.Println("Dropped", test_dropped, "rows for tests other than ADD.")
+		}
+		report.AddDropped(map[string]int{"test": test_dropped})
+		parse_cnames = map[string]string{}
+		for _, f := range data.Schema().Fields() {
+			parse_cnames[f.Name] = f.Name
+		}
+	}
+
+	parsed, dropped, err := ops.ParseDataframe(data, parse_cnames, pipeline, rejects)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
 	log.Println("Parsed", parsed.NumRows(), "rows.")
+	report.AddDropped(dropped)
 
-	ops.ParseDataframe(data, schema, cname_translation)
-	ops.ParseDataframe(data, schema, cname_translation)
+	parsed, dropped, err = ops.ProcessN(parsed, n_opts, rejects)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	report.AddDropped(dropped)
 
-	//df := ops.RemoveDuplicateSNPS(parsed, schema)
-	//log.Println("Left with", df.NumRows(), "SNPs.")
+	if args["liftover-chain"] != "" {
+		var counts ops.LiftoverCounts
+		parsed, counts = liftoverTable(parsed, readChain(args["liftover-chain"]), rejects)
+		report.AddDropped(map[string]int{
+			"liftover_unmapped":  counts.Unmapped,
+			"liftover_multiple":  counts.Multiple,
+			"liftover_other_chr": counts.OtherChr,
+			"liftover_strand":    counts.Strand,
+		})
+	}
 
-	//ops.ProcessN(df, schema, []string{args["ncol"], args["ncas"], args["ncon"]})
+	if regions != nil {
+		nrows := parsed.NumRows()
+		parsed, dropped, err = ops.Pipeline{regions.Filter()}.Run(parsed, rejects)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("Dropped", nrows-parsed.NumRows(), "SNPs in excluded regions.")
+		report.AddDropped(dropped)
+	}
+
+	if args["snp-map"] != "" {
+		log.Println("Mapping SNPs to rsIDs with", args["snp-map"])
+		idx, err := snpmap.Open(args["snp-map"])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		nrows := parsed.NumRows()
+		var unmapped int
+		parsed, unmapped, err = ops.MapSNPs(parsed, idx, rejects)
+		idx.Close()
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println(unmapped, "of", nrows, "rows could not be mapped to an rsID.")
+		report.AddDropped(map[string]int{"snp_map_unmapped": int(nrows - parsed.NumRows())})
+	}
 
+	var duplicates int
+	parsed, duplicates, err = ops.RemoveDuplicateSNPS(parsed, dup_opts, rejects)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	report.AddDropped(map[string]int{"duplicate": duplicates})
+
+	if merge_alleles != nil {
+		nrows := parsed.NumRows()
+		var counts ops.MergeCounts
+		parsed, counts, err = ops.MergeAlleles(parsed, merge_alleles, merge_opts, rejects)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("Dropped", counts.NotInRef, "SNPs not in the --merge-alleles file,", counts.Mismatch,
+			"with mismatched alleles and", counts.Palindromic, "strand ambiguous SNPs.")
+		if merge_opts.PalindromicByFrq {
+			log.Println("Kept", counts.Resolved, "strand ambiguous SNPs by allele frequency,", counts.StrandFlip, "of them on the other strand.")
+		}
+		log.Println(parsed.NumRows(), "of", nrows, "SNPs remain after merging alleles.")
+		report.AddDropped(map[string]int{
+			"merge_not_in_ref":  counts.NotInRef,
+			"merge_mismatch":    counts.Mismatch,
+			"merge_palindromic": counts.Palindromic,
+		})
+	}
+
+	parsed, underflow := ops.ParsePColumn(parsed)
+	if underflow > 0 {
+		log.Println(underflow, "P-values are below the float64 range; their -log10(P) is kept in LOG10P.")
+	}
+
+	parsed, derived := ops.DeriveStats(parsed, derive_opts)
+	if len(derived) > 0 {
+		log.Println("Derived columns:", strings.Join(derived, ", "))
+	}
+
+	report.OutputRows = parsed.NumRows()
+	report.SetStats(parsed)
+	logChiSquare(report)
+	if args["html-report"] != "false" {
+		log.Println("Writing HTML QC report to", out+".report.html")
+		if err := report_html.WriteHTML(out+".report.html", parsed, &report, merge_alleles); err != nil {
+			log.Fatal("Error: ", err)
+		}
+	}
+
+	drop := []string{"ROW"}
+	if read_pos {
+		for _, col := range []string{"CHR", "BP"} {
+			if !utils.InList(col, kept_cols) {
+				drop = append(drop, col)
+			}
+		}
+	}
+	parsed = ops.DropUnkeptColumns(ops.DropColumns(parsed, drop), keep_cols, derived)
+
+	writeSumstats(parsed, args, ops.GWASVCFMeta{VariantsNotRead: int(report.InputRows - report.OutputRows)})
+
+	if rejects != nil {
+		log.Println("Writing", rejects.Len(), "dropped rows to", out+".dropped.tsv.gz")
+		// the SNP of rows is only added for input without a SNP column, such
+		// as input mapped with --snp-map
+		add_snp := !utils.InList("SNP", utils.GetValues(cname_translation))
+		if err := rejects.Write(out+".dropped.tsv.gz", args["sumstats"], add_snp); err != nil {
+			log.Fatal("Error: ", err)
+		}
+	}
+
+	report.Timings = utils.Timings()
+	log.Println("Writing QC report to", out+".report.json")
+	if err := report.Write(out + ".report.json"); err != nil {
+		log.Fatal("Error: ", err)
+	}
+}
+
+// writeSumstats writes table to <out>.sumstats.gz, to <out>.vcf.gz with
+// --out-format gwas-vcf, or to <out>.tsv.gz and its metadata YAML with
+// --out-format gwas-ssf. vcf_meta has the variant counts of the GWAS-VCF
+// header; its sample and trait are set from --study-id and --trait.
+func writeSumstats(table array.Table, args map[string]string, vcf_meta ops.GWASVCFMeta) {
+	out := args["out"]
+	switch args["out-format"] {
+	case "gwas-ssf":
+		log.Println("Writing GWAS-SSF for", table.NumRows(), "SNPs to", out+".tsv.gz")
+		meta := ops.GWASSSFMeta{GWASID: args["study-id"], Trait: args["trait"], GenomeAssembly: args["genome-assembly"]}
+		if err := ops.WriteGWASSSF(table, out+".tsv.gz", meta); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("Wrote GWAS-SSF metadata to", out+".tsv.gz-meta.yaml")
+		return
+	case "gwas-vcf":
+		log.Println("Writing GWAS-VCF for", table.NumRows(), "SNPs to", out+".vcf.gz")
+		vcf_meta.Sample, vcf_meta.Trait = args["study-id"], args["trait"]
+		if err := ops.WriteGWASVCF(table, out+".vcf.gz", vcf_meta); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		return
+	}
+	log.Println("Writing summary statistics for", table.NumRows(), "SNPs to", out+".sumstats.gz")
+	if err := ops.WriteTSV(table, out+".sumstats.gz"); err != nil {
+		log.Fatal("Error: ", err)
+	}
+}
+
+// logChiSquare logs the chi^2 diagnostics of report the way LDSC does after
+// munging, with a line per chromosome if CHR was read.
+func logChiSquare(report ops.Report) {
+	if report.MeanChi2 == nil {
+		log.Println("No Z column; skipping chi^2 diagnostics.")
+		return
+	}
+	log.Printf("Mean chi^2 = %.3f", *report.MeanChi2)
+	if *report.MeanChi2 < 1.02 {
+		log.Println("WARNING: mean chi^2 may be too small.")
+	}
+	if report.LambdaGC != nil {
+		log.Printf("Lambda GC = %.3f", *report.LambdaGC)
+	}
+	if report.MaxChi2 != nil {
+		log.Printf("Max chi^2 = %.3f", *report.MaxChi2)
+	}
+	log.Println(report.GenomeWideSig, "Genome-wide significant SNPs (some may have been removed by filtering).")
+	if len(report.Chromosomes) > 0 {
+		log.Printf("%-4s %10s %10s %10s %10s %6s", "CHR", "SNPs", "mean_chi2", "lambda_gc", "max_chi2", "GWS")
+		for _, c := range report.Chromosomes {
+			log.Printf("%-4s %10d %10.3f %10.3f %10.3f %6d", c.CHR, c.SNPs, c.MeanChi2, c.LambdaGC, c.MaxChi2, c.GenomeWideSig)
+		}
+	}
 }
@@ -0,0 +1,52 @@
+package scripts
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/awilliamson10/golink/internal/ops"
+	report_html "github.com/awilliamson10/golink/internal/report"
+	"github.com/awilliamson10/golink/internal/utils"
+)
+
+// Report writes the HTML QC report of munged sumstats, taking the input
+// rows and drop counts from the munge JSON report if given with --qc.
+func Report(args map[string]string) {
+	file := args["sumstats"]
+	if file == "" {
+		log.Fatal("Error: --sumstats is required.")
+	}
+	out := args["out"]
+	if out == "" {
+		out = file
+	}
+	utils.SetupLog(out)
+
+	report := ops.Report{Sumstats: file}
+	if args["qc"] != "" {
+		b, err := os.ReadFile(args["qc"])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if err := json.Unmarshal(b, &report); err != nil {
+			log.Fatal("Error: reading ", args["qc"], ": ", err)
+		}
+	}
+	data := readCanonical(file, args["sample"], "none")
+	report.OutputRows = data.NumRows()
+	report.SetStats(data)
+
+	var merge_alleles map[string]ops.MergeAllele
+	if args["merge-alleles"] != "" {
+		var err error
+		merge_alleles, err = ops.ReadMergeAlleles(args["merge-alleles"])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+	}
+	log.Println("Writing HTML QC report to", out+".report.html")
+	if err := report_html.WriteHTML(out+".report.html", data, &report, merge_alleles); err != nil {
+		log.Fatal("Error: ", err)
+	}
+}
@@ -0,0 +1,321 @@
+package scripts
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/awilliamson10/golink/internal/ldsc"
+	"github.com/awilliamson10/golink/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+var rg_complement = map[string]string{"A": "T", "T": "A", "C": "G", "G": "C"}
+
+// Estimate_rg estimates the genetic correlation of the first --sumstats
+// file with each of the others.
+func Estimate_rg(args map[string]string) {
+	out := args["out"]
+	logFile, err := utils.SetupLog(out)
+	if err != nil {
+		panic(err)
+	}
+	defer logFile.Close()
+
+	paths := strings.Split(args["sumstats"], ",")
+	if len(paths) < 2 {
+		log.Fatal("Error: --sumstats must list at least two files.")
+	}
+	if args["ref-ld-chr"] == "" || args["w-ld-chr"] == "" {
+		log.Fatal("Error: --ref-ld-chr and --w-ld-chr are required.")
+	}
+	n_pheno := len(paths)
+	no_intercept := args["no-intercept"] != "false"
+	intercept_h2, err := parseInterceptList("--intercept-h2", args["intercept-h2"], n_pheno, no_intercept, 1)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	intercept_gencov, err := parseInterceptList("--intercept-gencov", args["intercept-gencov"], n_pheno, no_intercept, 0)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	samp_prev, pop_prev, err := parsePrevalenceList(args["samp-prev"], args["pop-prev"], n_pheno)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	n_blocks, err := strconv.Atoi(args["n-blocks"])
+	if err != nil {
+		log.Fatal("Error: --n-blocks must be an integer.")
+	}
+	chisq_max := math.Inf(1)
+	if args["chisq-max"] != "" {
+		if chisq_max, err = strconv.ParseFloat(args["chisq-max"], 64); err != nil {
+			log.Fatal("Error: --chisq-max must be a number.")
+		}
+	}
+
+	ref, err := readRefLD(args["ref-ld-chr"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	w_ld, err := readWLD(args["w-ld-chr"])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	sumstats1, err := readSumstats(paths[0])
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	merged := mergeLD(ref, w_ld, sumstats1)
+	log.Println("After merging with reference panel and regression SNP LD,", len(merged.snps), "SNPs remain.")
+	if len(merged.snps) == 0 {
+		log.Fatal("Error: no SNPs remain after merging.")
+	}
+
+	n_annot := len(ref.names)
+	two_step := 0.0
+	if args["two-step"] != "" {
+		if two_step, err = strconv.ParseFloat(args["two-step"], 64); err != nil {
+			log.Fatal("Error: --two-step must be a number.")
+		}
+		if n_annot > 1 {
+			log.Fatal("Error: --two-step is not compatible with partitioned LD scores.")
+		}
+	} else if n_annot == 1 && !anyIntercept(intercept_h2) && !anyIntercept(intercept_gencov) {
+		two_step = 30
+	}
+	if two_step > 0 {
+		log.Printf("Using two-step estimator with cutoff at %g.", two_step)
+	}
+
+	results := []rgResult{}
+	for i, p2 := range paths[1:] {
+		log.Printf("Computing rg for phenotype %d/%d", i+2, n_pheno)
+		sumstats2, err := readSumstats(p2)
+		if err != nil {
+			log.Println("Error: ", err)
+			continue
+		}
+		pair, err := alignPair(merged, sumstats1, sumstats2)
+		if err != nil {
+			log.Println("Error: ", err)
+			continue
+		}
+		log.Println(len(pair.snps), "SNPs with valid alleles.")
+		if !math.IsInf(chisq_max, 1) {
+			pair = pair.filter(func(j int) bool {
+				return pair.z[j]*pair.z[j]*pair.z2[j]*pair.z2[j] < chisq_max*chisq_max
+			})
+		}
+		if len(pair.snps) == 0 {
+			log.Println("Error: no SNPs remain for", p2)
+			continue
+		}
+		opts := ldsc.RGOptions{
+			NBlocks:         n_blocks,
+			TwoStep:         two_step,
+			Intercept1:      intercept_h2[0],
+			Intercept2:      intercept_h2[i+1],
+			InterceptGencov: intercept_gencov[i+1],
+		}
+		if len(pair.snps) < n_blocks {
+			opts.NBlocks = len(pair.snps)
+		}
+		x := mat.NewDense(len(pair.snps), n_annot, pair.ld)
+		rg, err := ldsc.NewRG(pair.z, pair.z2, x, pair.w, pair.n, pair.n2, ref.M, opts)
+		if err != nil {
+			log.Println("Error: ", err)
+			continue
+		}
+		if i == 0 {
+			summary, err := rg.Hsq1.Summary(ref.names, samp_prev[0], pop_prev[0])
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			log.Println("\nHeritability of phenotype 1\n" + summary)
+		}
+		summary, err := rg.Hsq2.Summary(ref.names, samp_prev[i+1], pop_prev[i+1])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Printf("\nHeritability of phenotype %d/%d\n%s", i+2, n_pheno, summary)
+		summary, err = rg.Gencov.Summary(ref.names, [2]float64{samp_prev[0], samp_prev[i+1]}, [2]float64{pop_prev[0], pop_prev[i+1]})
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+		log.Println("\nGenetic Covariance\n" + summary)
+		log.Println("\nGenetic Correlation\n" + rg.Summary())
+		// the conversion factor was checked by the summaries
+		c, _ := ldsc.H2ObsToLiab(1, samp_prev[i+1], pop_prev[i+1])
+		results = append(results, rgResult{p1: paths[0], p2: p2, rg: rg, liab: c})
+	}
+	if len(results) > 0 {
+		log.Println("\nSummary of Genetic Correlation Results\n" + rgTable(results, args["samp-prev"] != ""))
+	}
+}
+
+type rgResult struct {
+	p1 string
+	p2 string
+	rg *ldsc.RG
+	// liab converts the h2 of p2 to the liability scale
+	liab float64
+}
+
+// rgTable formats the genetic correlations as LDSC's summary table. With
+// liab the h2 of the second trait is also given on the liability scale.
+func rgTable(results []rgResult, liab bool) string {
+	header := []string{"p1", "p2", "rg", "se", "z", "p", "h2_obs", "h2_obs_se"}
+	if liab {
+		header = append(header, "h2_liab", "h2_liab_se")
+	}
+	header = append(header, "h2_int", "h2_int_se", "gcov_int", "gcov_int_se")
+	rows := []string{strings.Join(header, "\t")}
+	for _, r := range results {
+		row := []string{filepath.Base(r.p1), filepath.Base(r.p2)}
+		values := []float64{r.rg.RG, r.rg.SE, r.rg.Z, r.rg.P, r.rg.Hsq2.Tot, r.rg.Hsq2.TotSE}
+		if liab {
+			values = append(values, r.liab*r.rg.Hsq2.Tot, r.liab*r.rg.Hsq2.TotSE)
+		}
+		values = append(values, r.rg.Hsq2.Intercept, r.rg.Hsq2.InterceptSE, r.rg.Gencov.Intercept, r.rg.Gencov.InterceptSE)
+		for _, v := range values {
+			row = append(row, formatFloat(v))
+		}
+		rows = append(rows, strings.Join(row, "\t"))
+	}
+	return strings.Join(rows, "\n")
+}
+
+type pairData struct {
+	regressionData
+	z2 []float64
+	n2 []float64
+}
+
+func (d pairData) filter(keep func(i int) bool) (filtered pairData) {
+	filtered.regressionData = d.regressionData.filter(keep)
+	for i := range d.snps {
+		if keep(i) {
+			filtered.z2 = append(filtered.z2, d.z2[i])
+			filtered.n2 = append(filtered.n2, d.n2[i])
+		}
+	}
+	return
+}
+
+// alignPair joins the second trait onto the SNPs merged for the first,
+// flipping the sign of its Z where its alleles are swapped. SNPs whose
+// alleles do not match, or are strand ambiguous, are dropped as in LDSC.
+func alignPair(merged regressionData, sumstats1 map[string]sumstatsRow, sumstats2 map[string]sumstatsRow) (pair pairData, err error) {
+	z2 := make([]float64, len(merged.snps))
+	n2 := make([]float64, len(merged.snps))
+	keep := make([]bool, len(merged.snps))
+	found := 0
+	for i, snp := range merged.snps {
+		s2, ok := sumstats2[snp]
+		if !ok {
+			continue
+		}
+		found++
+		s1 := sumstats1[snp]
+		if s1.a1 == "" || s2.a1 == "" {
+			err = fmt.Errorf("rg requires A1 and A2 columns in both sumstats files")
+			return
+		}
+		sign, ok := alleleSign(s1.a1, s1.a2, s2.a1, s2.a2)
+		if !ok {
+			continue
+		}
+		z2[i], n2[i], keep[i] = sign*s2.z, s2.n, true
+	}
+	log.Println(found, "SNPs remain after merging with the second phenotype.")
+	pair.regressionData = merged.filter(func(i int) bool { return keep[i] })
+	for i := range keep {
+		if keep[i] {
+			pair.z2 = append(pair.z2, z2[i])
+			pair.n2 = append(pair.n2, n2[i])
+		}
+	}
+	return
+}
+
+// alleleSign returns 1 if b1/b2 are the alleles a1/a2 on either strand, -1
+// if they are swapped, and false if they do not match or are strand
+// ambiguous.
+func alleleSign(a1 string, a2 string, b1 string, b2 string) (float64, bool) {
+	c1, ok1 := rg_complement[a1]
+	c2, ok2 := rg_complement[a2]
+	if !ok1 || !ok2 || c1 == a2 || a1 == a2 {
+		return 0, false
+	}
+	switch {
+	case (b1 == a1 && b2 == a2) || (b1 == c1 && b2 == c2):
+		return 1, true
+	case (b1 == a2 && b2 == a1) || (b1 == c2 && b2 == c1):
+		return -1, true
+	}
+	return 0, false
+}
+
+// parseInterceptList reads a comma separated intercept per phenotype.
+// noIntercept fixes every intercept to null.
+func parseInterceptList(flag string, value string, n int, noIntercept bool, null float64) ([]*float64, error) {
+	intercepts := make([]*float64, n)
+	if value == "" && !noIntercept {
+		return intercepts, nil
+	}
+	values := make([]string, n)
+	if value != "" {
+		values = strings.Split(value, ",")
+		if len(values) != n {
+			return nil, fmt.Errorf("%s must have one value per --sumstats file", flag)
+		}
+	}
+	for i, v := range values {
+		var err error
+		if intercepts[i], err = parseIntercept(v, noIntercept, null); err != nil {
+			return nil, fmt.Errorf("%s: %v", flag, err)
+		}
+	}
+	return intercepts, nil
+}
+
+// parsePrevalenceList reads a comma separated --samp-prev and --pop-prev
+// per phenotype. A phenotype given as nan, and every phenotype if the flags
+// are unset, stays on the observed scale.
+func parsePrevalenceList(samp string, pop string, n int) (P []float64, K []float64, err error) {
+	if (samp == "") != (pop == "") {
+		err = fmt.Errorf("--samp-prev and --pop-prev must be set together")
+		return
+	}
+	samps, pops := make([]string, n), make([]string, n)
+	if samp != "" {
+		samps, pops = strings.Split(samp, ","), strings.Split(pop, ",")
+		if len(samps) != n || len(pops) != n {
+			err = fmt.Errorf("--samp-prev and --pop-prev must have one value per --sumstats file")
+			return
+		}
+	}
+	P, K = make([]float64, n), make([]float64, n)
+	for i := range samps {
+		if strings.EqualFold(samps[i], "nan") && strings.EqualFold(pops[i], "nan") {
+			samps[i], pops[i] = "", ""
+		}
+		if P[i], K[i], err = parsePrevalence(samps[i], pops[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func anyIntercept(intercepts []*float64) bool {
+	for _, v := range intercepts {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}